@@ -21,11 +21,13 @@ require (
 	github.com/xdg-go/stringprep v1.0.4 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
 	go.mongodb.org/mongo-driver v1.17.1 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
 
 replace github.com/himdhiman/dashboard-backend/libs/cache => ../cache
+
 replace github.com/himdhiman/dashboard-backend/libs/logger => ../logger
+
 replace github.com/himdhiman/dashboard-backend/libs/mongo => ../mongo
@@ -0,0 +1,176 @@
+package aggregate
+
+import (
+	"github.com/himdhiman/dashboard-backend/libs/mongo/mappers"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Pipeline builds an aggregation pipeline one stage at a time
+type Pipeline struct {
+	stages mongo.Pipeline
+}
+
+// New returns an empty pipeline
+func New() *Pipeline {
+	return &Pipeline{}
+}
+
+// Stages returns the stages of the pipeline as sent to the server
+func (p *Pipeline) Stages() mongo.Pipeline {
+	return p.stages
+}
+
+// Stage appends a raw stage, for the stages without a dedicated method
+func (p *Pipeline) Stage(name string, value interface{}) *Pipeline {
+	p.stages = append(p.stages, bson.D{{Key: name, Value: value}})
+	return p
+}
+
+// Match keeps the documents matching filter, a map or a document built with the query package
+func (p *Pipeline) Match(filter interface{}) *Pipeline {
+	return p.Stage("$match", mappers.MapFilter(filter))
+}
+
+// Group groups documents by the id expression and computes fields with accumulators such as Sum
+func (p *Pipeline) Group(id interface{}, fields map[string]interface{}) *Pipeline {
+	group := bson.M{"_id": id}
+	for name, accumulator := range fields {
+		group[name] = accumulator
+	}
+	return p.Stage("$group", group)
+}
+
+// Project reshapes documents, fields map output fields to 0, 1 or an expression
+func (p *Pipeline) Project(fields map[string]interface{}) *Pipeline {
+	return p.Stage("$project", bson.M(fields))
+}
+
+// Lookup joins the documents of collection from whose foreignField equals localField into as
+func (p *Pipeline) Lookup(from, localField, foreignField, as string) *Pipeline {
+	return p.Stage("$lookup", bson.M{
+		"from":         from,
+		"localField":   localField,
+		"foreignField": foreignField,
+		"as":           as,
+	})
+}
+
+// Unwind outputs one document per element of the array at path, documents without elements are
+// kept when preserveEmpty is set
+func (p *Pipeline) Unwind(path string, preserveEmpty bool) *Pipeline {
+	return p.Stage("$unwind", bson.M{
+		"path":                       Field(path),
+		"preserveNullAndEmptyArrays": preserveEmpty,
+	})
+}
+
+// Sort orders documents by the given fields, in order
+func (p *Pipeline) Sort(fields ...SortField) *Pipeline {
+	sort := make(bson.D, 0, len(fields))
+	for _, field := range fields {
+		sort = append(sort, bson.E{Key: field.Name, Value: field.Order})
+	}
+	return p.Stage("$sort", sort)
+}
+
+// Facet runs several sub-pipelines on the same input, each output field holds the results of one
+func (p *Pipeline) Facet(facets map[string]*Pipeline) *Pipeline {
+	facet := bson.M{}
+	for name, pipeline := range facets {
+		facet[name] = pipeline.Stages()
+	}
+	return p.Stage("$facet", facet)
+}
+
+// Bucket groups documents into the ranges between consecutive boundaries of the groupBy expression.
+// Documents outside of the boundaries go to the defaultBucket, a nil defaultBucket rejects them.
+func (p *Pipeline) Bucket(groupBy interface{}, boundaries []interface{}, defaultBucket interface{}, output map[string]interface{}) *Pipeline {
+	bucket := bson.M{
+		"groupBy":    groupBy,
+		"boundaries": boundaries,
+	}
+	if defaultBucket != nil {
+		bucket["default"] = defaultBucket
+	}
+	if len(output) > 0 {
+		bucket["output"] = output
+	}
+	return p.Stage("$bucket", bucket)
+}
+
+// Limit keeps the first n documents
+func (p *Pipeline) Limit(n int64) *Pipeline {
+	return p.Stage("$limit", n)
+}
+
+// Skip drops the first n documents
+func (p *Pipeline) Skip(n int64) *Pipeline {
+	return p.Stage("$skip", n)
+}
+
+// Count outputs a single document holding the number of documents in field
+func (p *Pipeline) Count(field string) *Pipeline {
+	return p.Stage("$count", field)
+}
+
+// SortField is a field of a Sort stage
+type SortField struct {
+	Name  string
+	Order int
+}
+
+// Asc sorts by field in ascending order
+func Asc(field string) SortField {
+	return SortField{Name: field, Order: 1}
+}
+
+// Desc sorts by field in descending order
+func Desc(field string) SortField {
+	return SortField{Name: field, Order: -1}
+}
+
+// Field references the value of a field in an expression
+func Field(name string) string {
+	return "$" + name
+}
+
+// Sum accumulates the sum of an expression, Sum(1) counts documents
+func Sum(expr interface{}) bson.M {
+	return bson.M{"$sum": expr}
+}
+
+// Avg accumulates the average of an expression
+func Avg(expr interface{}) bson.M {
+	return bson.M{"$avg": expr}
+}
+
+// Min accumulates the minimum of an expression
+func Min(expr interface{}) bson.M {
+	return bson.M{"$min": expr}
+}
+
+// Max accumulates the maximum of an expression
+func Max(expr interface{}) bson.M {
+	return bson.M{"$max": expr}
+}
+
+// First accumulates the value of an expression for the first document of a group
+func First(expr interface{}) bson.M {
+	return bson.M{"$first": expr}
+}
+
+// Last accumulates the value of an expression for the last document of a group
+func Last(expr interface{}) bson.M {
+	return bson.M{"$last": expr}
+}
+
+// Push accumulates the values of an expression into an array
+func Push(expr interface{}) bson.M {
+	return bson.M{"$push": expr}
+}
+
+// AddToSet accumulates the distinct values of an expression into an array
+func AddToSet(expr interface{}) bson.M {
+	return bson.M{"$addToSet": expr}
+}
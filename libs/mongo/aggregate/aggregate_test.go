@@ -0,0 +1,112 @@
+package aggregate
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestStages(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	cases := []struct {
+		name     string
+		pipeline *Pipeline
+		expected mongo.Pipeline
+	}{
+		{"match", New().Match(map[string]interface{}{"_id": id.Hex()}), mongo.Pipeline{{{Key: "$match", Value: bson.M{"_id": id}}}}},
+		{"group", New().Group(Field("vendor"), map[string]interface{}{"orders": Sum(1)}), mongo.Pipeline{
+			{{Key: "$group", Value: bson.M{"_id": "$vendor", "orders": bson.M{"$sum": 1}}}},
+		}},
+		{"project", New().Project(map[string]interface{}{"_id": 0, "vendor": Field("_id")}), mongo.Pipeline{
+			{{Key: "$project", Value: bson.M{"_id": 0, "vendor": "$_id"}}},
+		}},
+		{"lookup", New().Lookup("products", "sku", "skuCode", "product"), mongo.Pipeline{
+			{{Key: "$lookup", Value: bson.M{"from": "products", "localField": "sku", "foreignField": "skuCode", "as": "product"}}},
+		}},
+		{"unwind", New().Unwind("product", true), mongo.Pipeline{
+			{{Key: "$unwind", Value: bson.M{"path": "$product", "preserveNullAndEmptyArrays": true}}},
+		}},
+		{"sort", New().Sort(Desc("year"), Asc("vendor")), mongo.Pipeline{
+			{{Key: "$sort", Value: bson.D{{Key: "year", Value: -1}, {Key: "vendor", Value: 1}}}},
+		}},
+		{"paging", New().Skip(20).Limit(10).Count("total"), mongo.Pipeline{
+			{{Key: "$skip", Value: int64(20)}},
+			{{Key: "$limit", Value: int64(10)}},
+			{{Key: "$count", Value: "total"}},
+		}},
+	}
+
+	for _, tc := range cases {
+		if got := tc.pipeline.Stages(); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestFacet(t *testing.T) {
+	pipeline := New().Facet(map[string]*Pipeline{
+		"items": New().Skip(0).Limit(10),
+		"total": New().Count("count"),
+	})
+
+	expected := mongo.Pipeline{{{Key: "$facet", Value: bson.M{
+		"items": mongo.Pipeline{{{Key: "$skip", Value: int64(0)}}, {{Key: "$limit", Value: int64(10)}}},
+		"total": mongo.Pipeline{{{Key: "$count", Value: "count"}}},
+	}}}}
+	if got := pipeline.Stages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	boundaries := []interface{}{0, 100, 1000}
+
+	cases := []struct {
+		name          string
+		defaultBucket interface{}
+		output        map[string]interface{}
+		expected      bson.M
+	}{
+		{"boundaries only", nil, nil, bson.M{"groupBy": "$totalAmount", "boundaries": boundaries}},
+		{"default bucket", "other", nil, bson.M{"groupBy": "$totalAmount", "boundaries": boundaries, "default": "other"}},
+		{"output", nil, map[string]interface{}{"orders": Sum(1)}, bson.M{
+			"groupBy":    "$totalAmount",
+			"boundaries": boundaries,
+			"output":     map[string]interface{}{"orders": bson.M{"$sum": 1}},
+		}},
+	}
+
+	for _, tc := range cases {
+		stages := New().Bucket(Field("totalAmount"), boundaries, tc.defaultBucket, tc.output).Stages()
+		expected := mongo.Pipeline{{{Key: "$bucket", Value: tc.expected}}}
+		if !reflect.DeepEqual(stages, expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, expected, stages)
+		}
+	}
+}
+
+func TestAccumulators(t *testing.T) {
+	cases := []struct {
+		got      bson.M
+		operator string
+	}{
+		{Sum(1), "$sum"},
+		{Avg("$a"), "$avg"},
+		{Min("$a"), "$min"},
+		{Max("$a"), "$max"},
+		{First("$a"), "$first"},
+		{Last("$a"), "$last"},
+		{Push("$a"), "$push"},
+		{AddToSet("$a"), "$addToSet"},
+	}
+
+	for _, tc := range cases {
+		if _, ok := tc.got[tc.operator]; !ok || len(tc.got) != 1 {
+			t.Errorf("expected a single %s accumulator, got %v", tc.operator, tc.got)
+		}
+	}
+}
@@ -10,4 +10,39 @@ var (
 	ErrDeleteFailed     = errors.New("failed to delete document")
 	ErrCountFailed      = errors.New("failed to count documents")
 	ErrInvalidObjectID  = errors.New("invalid object ID")
+	ErrBulkWriteFailed  = errors.New("failed to bulk write documents")
+	ErrInvalidCursor    = errors.New("invalid pagination cursor")
+	ErrValidationFailed = errors.New("document validation failed")
+	ErrWatchFailed      = errors.New("failed to watch collection")
+	// ErrUnsupportedUpdate is returned by hooks that cannot modify an update, such as a struct passed by value
+	ErrUnsupportedUpdate = errors.New("unsupported update type")
+	// ErrVersionConflict is returned when a document was changed since it was read
+	ErrVersionConflict = errors.New("document version conflict")
+	// ErrStopIteration is returned by an Iterate callback to stop iterating without an error
+	ErrStopIteration = errors.New("stop iteration")
 )
+
+// OperationError is returned when the driver or a hook fails an operation. It matches the repository
+// error of the operation with errors.Is and unwraps to the underlying error, which keeps the error
+// labels used to retry transactions.
+type OperationError struct {
+	Op  error
+	Err error
+}
+
+// Wrap returns an OperationError for the failed operation op
+func Wrap(op, err error) error {
+	return &OperationError{Op: op, Err: err}
+}
+
+func (e *OperationError) Error() string {
+	return e.Op.Error() + ": " + e.Err.Error()
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *OperationError) Is(target error) bool {
+	return target == e.Op
+}
@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestOperationError(t *testing.T) {
+	cause := mongo.CommandError{Code: 112, Message: "write conflict", Labels: []string{"TransientTransactionError"}}
+	err := Wrap(ErrUpdateFailed, cause)
+
+	cases := []struct {
+		name     string
+		target   error
+		expected bool
+	}{
+		{"its operation", ErrUpdateFailed, true},
+		{"another operation", ErrInsertFailed, false},
+		{"not found", ErrDocumentNotFound, false},
+	}
+	for _, tc := range cases {
+		if got := errors.Is(err, tc.target); got != tc.expected {
+			t.Errorf("%s: expected errors.Is to be %t, got %t", tc.name, tc.expected, got)
+		}
+	}
+
+	var labeled mongo.LabeledError
+	if !errors.As(err, &labeled) || !labeled.HasErrorLabel("TransientTransactionError") {
+		t.Errorf("expected the transaction error label to be kept, got %v", err)
+	}
+	if err.Error() != "failed to update document: write conflict" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
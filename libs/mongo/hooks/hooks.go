@@ -4,11 +4,43 @@ import (
 	"context"
 )
 
+// IHook is called by a repository around its operations. Before hooks may modify the document,
+// filter or update they are given, an error from any hook fails the operation. Filters and map
+// updates are copies, rewriting them leaves the values of the caller as they are.
+//
+// Soft deletes and restores call the update hooks. BulkWrite, Aggregate and Purge bypass hooks.
 type IHook interface {
 	BeforeCreate(ctx context.Context, doc interface{}) error
+	// AfterCreate is given the hex ID of the inserted document
+	AfterCreate(ctx context.Context, doc interface{}, id string) error
 	BeforeUpdate(ctx context.Context, filter, update interface{}) error
+	AfterUpdate(ctx context.Context, filter, update interface{}) error
+	BeforeDelete(ctx context.Context, filter interface{}) error
+	AfterDelete(ctx context.Context, filter interface{}) error
+	BeforeFind(ctx context.Context, filter interface{}) error
+	// AfterFind is called with every document that was found
+	AfterFind(ctx context.Context, doc interface{}) error
 }
 
+// BaseHook implements every method of IHook as a no-op, hooks embed it and override what they need
+type BaseHook struct{}
+
+func (BaseHook) BeforeCreate(ctx context.Context, doc interface{}) error { return nil }
+
+func (BaseHook) AfterCreate(ctx context.Context, doc interface{}, id string) error { return nil }
+
+func (BaseHook) BeforeUpdate(ctx context.Context, filter, update interface{}) error { return nil }
+
+func (BaseHook) AfterUpdate(ctx context.Context, filter, update interface{}) error { return nil }
+
+func (BaseHook) BeforeDelete(ctx context.Context, filter interface{}) error { return nil }
+
+func (BaseHook) AfterDelete(ctx context.Context, filter interface{}) error { return nil }
+
+func (BaseHook) BeforeFind(ctx context.Context, filter interface{}) error { return nil }
+
+func (BaseHook) AfterFind(ctx context.Context, doc interface{}) error { return nil }
+
 // MongoIDToStringHook converts primitive.ObjectID to string
 // func MongoIDToStringHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
 // 	if f == reflect.TypeOf(primitive.ObjectID{}) && t == reflect.TypeOf("") {
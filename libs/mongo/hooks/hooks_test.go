@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStringToMongoIDHook(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	cases := []struct {
+		name     string
+		filter   interface{}
+		expected interface{}
+	}{
+		{"map", map[string]interface{}{"_id": id.Hex(), "name": "a"}, map[string]interface{}{"_id": id, "name": "a"}},
+		{"id that is not an ObjectID", bson.M{"_id": "name"}, bson.M{"_id": "name"}},
+		{"document", bson.D{{Key: "_id", Value: id.Hex()}}, bson.D{{Key: "_id", Value: id}}},
+		{"$in", bson.M{"_id": bson.M{"$in": []string{id.Hex()}}}, bson.M{"_id": bson.M{"$in": bson.A{id}}}},
+		{"$ne", bson.M{"_id": bson.D{{Key: "$ne", Value: id.Hex()}}}, bson.M{"_id": bson.D{{Key: "$ne", Value: id}}}},
+		{"$and", bson.M{"$and": []bson.M{{"_id": id.Hex()}, {"name": "a"}}}, bson.M{"$and": []bson.M{{"_id": id}, {"name": "a"}}}},
+		{"$or", bson.D{{Key: "$or", Value: bson.A{bson.M{"_id": id.Hex()}}}}, bson.D{{Key: "$or", Value: bson.A{bson.M{"_id": id}}}}},
+		{"field other than _id", bson.M{"parentId": id.Hex()}, bson.M{"parentId": id.Hex()}},
+	}
+
+	hook := &StringToMongoIDHook{}
+	for _, tc := range cases {
+		if err := hook.BeforeFind(context.Background(), tc.filter); err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(tc.filter, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, tc.filter)
+		}
+	}
+}
+
+type stamped struct {
+	Name      string    `bson:"name" validate:"required"`
+	CreatedAt time.Time `bson:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func TestTimestampHook(t *testing.T) {
+	ctx := context.Background()
+	hook := NewTimestampHook("updatedAt")
+
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := &stamped{CreatedAt: createdAt}
+	if err := hook.BeforeCreate(ctx, doc); err != nil {
+		t.Fatalf("create: unexpected error %v", err)
+	}
+	if !doc.CreatedAt.Equal(createdAt) || doc.UpdatedAt.IsZero() {
+		t.Errorf("create: expected createdAt to be kept and updatedAt to be set, got %v and %v", doc.CreatedAt, doc.UpdatedAt)
+	}
+
+	update := bson.M{"name": "a"}
+	if err := hook.BeforeUpdate(ctx, nil, update); err != nil {
+		t.Fatalf("map update: unexpected error %v", err)
+	}
+	if _, ok := update["updatedAt"]; !ok {
+		t.Errorf("map update: expected updatedAt to be set, got %v", update)
+	}
+
+	pointer := &stamped{}
+	if err := hook.BeforeUpdate(ctx, nil, pointer); err != nil {
+		t.Fatalf("struct update: unexpected error %v", err)
+	}
+	if pointer.UpdatedAt.IsZero() {
+		t.Errorf("struct update: expected UpdatedAt to be set")
+	}
+
+	if err := hook.BeforeUpdate(ctx, nil, stamped{}); !errors.Is(err, mongo_errors.ErrUnsupportedUpdate) {
+		t.Errorf("struct update by value: expected %v, got %v", mongo_errors.ErrUnsupportedUpdate, err)
+	}
+}
+
+func TestValidationHook(t *testing.T) {
+	ctx := context.Background()
+	hook := NewValidationHook()
+
+	cases := []struct {
+		name     string
+		doc      interface{}
+		expected error
+	}{
+		{"valid", &stamped{Name: "a"}, nil},
+		{"invalid", &stamped{}, mongo_errors.ErrValidationFailed},
+		{"invalid by value", stamped{}, mongo_errors.ErrValidationFailed},
+		{"map update", bson.M{"name": ""}, nil},
+		{"nil pointer", (*stamped)(nil), nil},
+	}
+
+	for _, tc := range cases {
+		if err := hook.BeforeUpdate(ctx, nil, tc.doc); !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, err)
+		}
+	}
+}
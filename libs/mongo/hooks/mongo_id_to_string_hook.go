@@ -1,13 +1,51 @@
 package hooks
 
-// type MongoIDToStringHook struct {
-// 	IHook
-// }
+import (
+	"context"
+	"reflect"
+	"strings"
 
-// func (hook *MongoIDToStringHook) BeforeCreate(ctx context.Context, doc interface{}) error {
-// 	return convertMongoIDToString(doc)
-// }
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
-// func (hook *MongoIDToStringHook) BeforeUpdate(ctx context.Context, filter, update interface{}) error {
-// 	return convertMongoIDToString(update)
-// }
+// MongoIDToStringHook sets the _id field of a created document to the ID it was inserted with, the
+// field may be a string or an ObjectID
+type MongoIDToStringHook struct {
+	BaseHook
+}
+
+func (hook *MongoIDToStringHook) AfterCreate(ctx context.Context, doc interface{}, id string) error {
+	field, ok := idField(doc)
+	if !ok {
+		return nil
+	}
+
+	switch field.Type() {
+	case reflect.TypeOf(""):
+		field.SetString(id)
+	case reflect.TypeOf(primitive.ObjectID{}):
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(objID))
+	}
+	return nil
+}
+
+// idField returns the settable field of a struct pointer tagged as _id
+func idField(doc interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	v = v.Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("bson"), ",")
+		if name == "_id" && v.Field(i).CanSet() {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
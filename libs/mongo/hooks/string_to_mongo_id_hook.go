@@ -2,42 +2,111 @@ package hooks
 
 import (
 	"context"
-	"errors"
-	"reflect"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// StringToMongoIDHook converts hex strings matched against _id in filters to ObjectIDs, including
+// the values of operators such as $in and $ne
 type StringToMongoIDHook struct {
-	IHook
+	BaseHook
 }
 
-func (hook *StringToMongoIDHook) BeforeCreate(ctx context.Context, doc interface{}) error {
-	return convertStringToMongoID(doc)
+func (hook *StringToMongoIDHook) BeforeUpdate(ctx context.Context, filter, update interface{}) error {
+	convertStringToMongoID(filter)
+	return nil
 }
 
-func (hook *StringToMongoIDHook) BeforeUpdate(ctx context.Context, filter, update interface{}) error {
-	return convertStringToMongoID(update)
+func (hook *StringToMongoIDHook) BeforeDelete(ctx context.Context, filter interface{}) error {
+	convertStringToMongoID(filter)
+	return nil
 }
 
-func convertStringToMongoID(doc interface{}) error {
-	v := reflect.ValueOf(doc)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
+func (hook *StringToMongoIDHook) BeforeFind(ctx context.Context, filter interface{}) error {
+	convertStringToMongoID(filter)
+	return nil
+}
 
-	if v.Kind() != reflect.Struct {
-		return errors.New("expected a struct or pointer to struct")
+// convertStringToMongoID rewrites a filter in place, walking into $and, $or and $nor. The repository
+// gives hooks a copy of the filter of the caller.
+func convertStringToMongoID(filter interface{}) {
+	switch f := filter.(type) {
+	case map[string]interface{}:
+		for key, value := range f {
+			f[key] = convertKey(key, value)
+		}
+	case bson.M:
+		for key, value := range f {
+			f[key] = convertKey(key, value)
+		}
+	case bson.D:
+		for i, elem := range f {
+			f[i].Value = convertKey(elem.Key, elem.Value)
+		}
 	}
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if field.Kind() == reflect.String && field.Type().Name() == "string" {
-			if bsonID, err := primitive.ObjectIDFromHex(field.String()); err == nil {
-				field.Set(reflect.ValueOf(bsonID))
+func convertKey(key string, value interface{}) interface{} {
+	switch key {
+	case "_id":
+		return convertIDValue(value)
+	case "$and", "$or", "$nor":
+		switch filters := value.(type) {
+		case []interface{}:
+			for _, filter := range filters {
+				convertStringToMongoID(filter)
+			}
+		case bson.A:
+			for _, filter := range filters {
+				convertStringToMongoID(filter)
+			}
+		case []bson.D:
+			for _, filter := range filters {
+				convertStringToMongoID(filter)
+			}
+		case []bson.M:
+			for _, filter := range filters {
+				convertStringToMongoID(filter)
 			}
 		}
 	}
+	return value
+}
 
-	return nil
+// convertIDValue converts an ID, a list of IDs or the operands of an operator document
+func convertIDValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if objID, err := primitive.ObjectIDFromHex(v); err == nil {
+			return objID
+		}
+	case []string:
+		ids := make(bson.A, len(v))
+		for i, id := range v {
+			ids[i] = convertIDValue(id)
+		}
+		return ids
+	case []interface{}:
+		for i, id := range v {
+			v[i] = convertIDValue(id)
+		}
+	case bson.A:
+		for i, id := range v {
+			v[i] = convertIDValue(id)
+		}
+	case map[string]interface{}:
+		for operator, operand := range v {
+			v[operator] = convertIDValue(operand)
+		}
+	case bson.M:
+		for operator, operand := range v {
+			v[operator] = convertIDValue(operand)
+		}
+	case bson.D:
+		for i, elem := range v {
+			v[i].Value = convertIDValue(elem.Value)
+		}
+	}
+	return value
 }
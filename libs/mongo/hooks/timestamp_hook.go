@@ -0,0 +1,68 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TimestampHook maintains the CreatedAt and UpdatedAt time.Time fields of documents. Updates given
+// as a map have their UpdatedAtKey set instead. Struct updates must be passed by pointer, a struct
+// passed by value cannot be stamped and fails with ErrUnsupportedUpdate.
+type TimestampHook struct {
+	BaseHook
+	UpdatedAtKey string
+}
+
+// NewTimestampHook returns a TimestampHook setting updatedAtKey on map updates
+func NewTimestampHook(updatedAtKey string) *TimestampHook {
+	return &TimestampHook{UpdatedAtKey: updatedAtKey}
+}
+
+func (hook *TimestampHook) BeforeCreate(ctx context.Context, doc interface{}) error {
+	now := time.Now()
+	setTime(doc, "CreatedAt", now, true)
+	setTime(doc, "UpdatedAt", now, false)
+	return nil
+}
+
+func (hook *TimestampHook) BeforeUpdate(ctx context.Context, filter, update interface{}) error {
+	now := time.Now()
+	switch u := update.(type) {
+	case map[string]interface{}:
+		if hook.UpdatedAtKey != "" {
+			u[hook.UpdatedAtKey] = now
+		}
+	case bson.M:
+		if hook.UpdatedAtKey != "" {
+			u[hook.UpdatedAtKey] = now
+		}
+	default:
+		if v := reflect.ValueOf(update); v.Kind() == reflect.Struct {
+			return mongo_errors.Wrap(mongo_errors.ErrUnsupportedUpdate, fmt.Errorf("%s passed by value, pass a pointer", v.Type()))
+		}
+		setTime(update, "UpdatedAt", now, false)
+	}
+	return nil
+}
+
+// setTime sets a time.Time field of a struct pointer, onlyZero leaves a field that is already set
+func setTime(doc interface{}, name string, t time.Time, onlyZero bool) {
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+
+	field := v.Elem().FieldByName(name)
+	if !field.IsValid() || !field.CanSet() || field.Type() != reflect.TypeOf(time.Time{}) {
+		return
+	}
+	if onlyZero && !field.IsZero() {
+		return
+	}
+	field.Set(reflect.ValueOf(t))
+}
@@ -0,0 +1,47 @@
+package hooks
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+)
+
+// ValidationHook validates created documents and updates given as structs against their validate
+// tags. Updates given as a map only set some fields and are not validated.
+type ValidationHook struct {
+	BaseHook
+	validate *validator.Validate
+}
+
+// NewValidationHook returns a ValidationHook using the default validator
+func NewValidationHook() *ValidationHook {
+	return &ValidationHook{validate: validator.New()}
+}
+
+func (hook *ValidationHook) BeforeCreate(ctx context.Context, doc interface{}) error {
+	return hook.validateStruct(ctx, doc)
+}
+
+func (hook *ValidationHook) BeforeUpdate(ctx context.Context, filter, update interface{}) error {
+	return hook.validateStruct(ctx, update)
+}
+
+func (hook *ValidationHook) validateStruct(ctx context.Context, doc interface{}) error {
+	v := reflect.ValueOf(doc)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if err := hook.validate.StructCtx(ctx, doc); err != nil {
+		return mongo_errors.Wrap(mongo_errors.ErrValidationFailed, err)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package mappers
 
 import (
+	"maps"
+	"slices"
+
 	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
 	"github.com/mitchellh/mapstructure"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,9 +15,12 @@ import (
 // MapFindOptions maps custom FindOptions to mongo.FindOptions
 func MapFindOptions(opts ...*models.FindOptions) *options.FindOptions {
 	mongoFindOptions := options.Find()
-	if len(opts) > 0 {
+	if len(opts) > 0 && opts[0] != nil {
 		opt := opts[0]
 		mapstructure.Decode(opt, mongoFindOptions)
+		if opt.BatchSize <= 0 {
+			mongoFindOptions.BatchSize = nil
+		}
 	}
 	return mongoFindOptions
 }
@@ -28,6 +34,42 @@ func MapFindOneOptions(opts ...*models.FindOptions) *options.FindOneOptions {
 	return mongoFindOneOptions
 }
 
+// MapFindOneAndUpdateOptions maps custom FindOneAndUpdateOptions to mongo.FindOneAndUpdateOptions
+func MapFindOneAndUpdateOptions(opts ...*models.FindOneAndUpdateOptions) *options.FindOneAndUpdateOptions {
+	mongoOptions := options.FindOneAndUpdate()
+	if len(opts) > 0 && opts[0] != nil {
+		opt := opts[0]
+		if opt.Sort != nil {
+			mongoOptions.SetSort(opt.Sort)
+		}
+		if opt.Projection != nil {
+			mongoOptions.SetProjection(opt.Projection)
+		}
+		if opt.Upsert {
+			mongoOptions.SetUpsert(true)
+		}
+		if opt.ReturnAfter {
+			mongoOptions.SetReturnDocument(options.After)
+		}
+	}
+	return mongoOptions
+}
+
+// MapBulkWriteResult maps mongo.BulkWriteResult to custom BulkWriteResult
+func MapBulkWriteResult(mongoResult *mongo.BulkWriteResult) *models.BulkWriteResult {
+	if mongoResult == nil {
+		return &models.BulkWriteResult{}
+	}
+	return &models.BulkWriteResult{
+		InsertedCount: mongoResult.InsertedCount,
+		MatchedCount:  mongoResult.MatchedCount,
+		ModifiedCount: mongoResult.ModifiedCount,
+		DeletedCount:  mongoResult.DeletedCount,
+		UpsertedCount: mongoResult.UpsertedCount,
+		UpsertedIDs:   mongoResult.UpsertedIDs,
+	}
+}
+
 // MapUpdateResult maps mongo.UpdateResult to custom UpdateResult
 func MapUpdateResult(mongoResult *mongo.UpdateResult) *models.UpdateResult {
 	var updateResult models.UpdateResult
@@ -35,14 +77,107 @@ func MapUpdateResult(mongoResult *mongo.UpdateResult) *models.UpdateResult {
 	return &updateResult
 }
 
-// MapToBson converts a map[string]interface{} to bson.M
+// MapFilter converts a filter to a document the driver accepts. Filters are either maps, such as
+// map[string]interface{} and bson.M, or documents built with the query package. A string _id is
+// converted to an ObjectID, a nil filter matches every document.
+func MapFilter(filter interface{}) interface{} {
+	switch f := filter.(type) {
+	case nil:
+		return bson.D{}
+	case map[string]interface{}:
+		return MapToBson(f)
+	case bson.M:
+		return MapToBson(f)
+	case models.FilterOptions:
+		return MapToBson(f)
+	case bson.D:
+		// Filters built once may be reused, the converted _id goes to a copy
+		for i, elem := range f {
+			if oid, ok := elem.Value.(string); ok && elem.Key == "_id" {
+				if objID, err := primitive.ObjectIDFromHex(oid); err == nil {
+					mapped := slices.Clone(f)
+					mapped[i].Value = objID
+					return mapped
+				}
+			}
+		}
+		return f
+	default:
+		return filter
+	}
+}
+
+// MapToBson converts a map[string]interface{} to bson.M, the map is copied when its _id is converted
 func MapToBson(filter map[string]interface{}) bson.M {
 	if id, ok := filter["_id"]; ok {
 		if oid, ok := id.(string); ok {
 			if objID, err := primitive.ObjectIDFromHex(oid); err == nil {
-				filter["_id"] = objID
+				mapped := maps.Clone(filter)
+				mapped["_id"] = objID
+				return bson.M(mapped)
 			}
 		}
 	}
 	return bson.M(filter)
 }
+
+// CloneFilter deep copies the documents and lists of a filter, so that hooks can rewrite the copy
+// without changing the filter of the caller. Other values are shared.
+func CloneFilter(filter interface{}) interface{} {
+	switch f := filter.(type) {
+	case map[string]interface{}:
+		return cloneDocument(f)
+	case bson.M:
+		return cloneDocument(f)
+	case models.FilterOptions:
+		return cloneDocument(f)
+	case bson.D:
+		if f == nil {
+			return f
+		}
+		cloned := make(bson.D, len(f))
+		for i, elem := range f {
+			cloned[i] = bson.E{Key: elem.Key, Value: CloneFilter(elem.Value)}
+		}
+		return cloned
+	case []interface{}:
+		return cloneList(f)
+	case bson.A:
+		return cloneList(f)
+	case []bson.D:
+		return cloneList(f)
+	case []bson.M:
+		return cloneList(f)
+	case []map[string]interface{}:
+		return cloneList(f)
+	case []string:
+		return slices.Clone(f)
+	default:
+		return filter
+	}
+}
+
+func cloneDocument[M ~map[string]interface{}](document M) M {
+	if document == nil {
+		return document
+	}
+	cloned := make(M, len(document))
+	for key, value := range document {
+		cloned[key] = CloneFilter(value)
+	}
+	return cloned
+}
+
+func cloneList[S ~[]E, E any](list S) S {
+	if list == nil {
+		return list
+	}
+	cloned := make(S, len(list))
+	for i, value := range list {
+		// nil elements stay nil
+		if clonedValue, ok := CloneFilter(value).(E); ok {
+			cloned[i] = clonedValue
+		}
+	}
+	return cloned
+}
@@ -0,0 +1,104 @@
+package mappers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMapFindOneAndUpdateOptions(t *testing.T) {
+	sort := bson.D{{Key: "_id", Value: 1}}
+
+	cases := []struct {
+		name     string
+		opts     []*models.FindOneAndUpdateOptions
+		expected *options.FindOneAndUpdateOptions
+	}{
+		{"no options", nil, options.FindOneAndUpdate()},
+		{"nil options", []*models.FindOneAndUpdateOptions{nil}, options.FindOneAndUpdate()},
+		{"sort", []*models.FindOneAndUpdateOptions{{Sort: sort}}, options.FindOneAndUpdate().SetSort(sort)},
+		{
+			"upsert returning the updated document",
+			[]*models.FindOneAndUpdateOptions{{Upsert: true, ReturnAfter: true}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		},
+	}
+
+	for _, tc := range cases {
+		if got := MapFindOneAndUpdateOptions(tc.opts...); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestMapFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	cases := []struct {
+		name     string
+		filter   interface{}
+		expected interface{}
+	}{
+		{"nil", nil, bson.D{}},
+		{"map", map[string]interface{}{"_id": id.Hex(), "name": "a"}, bson.M{"_id": id, "name": "a"}},
+		{"bson.M", bson.M{"_id": id.Hex()}, bson.M{"_id": id}},
+		{"id that is not an ObjectID", map[string]interface{}{"_id": "name"}, bson.M{"_id": "name"}},
+		{"document", bson.D{{Key: "name", Value: "a"}, {Key: "_id", Value: id.Hex()}}, bson.D{{Key: "name", Value: "a"}, {Key: "_id", Value: id}}},
+		{"other filters", bson.A{"a"}, bson.A{"a"}},
+	}
+
+	for _, tc := range cases {
+		if got := MapFilter(tc.filter); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestMapFilter_KeepsCallerFilter(t *testing.T) {
+	id := primitive.NewObjectID().Hex()
+
+	document := bson.D{{Key: "_id", Value: id}}
+	MapFilter(document)
+	if document[0].Value != id {
+		t.Errorf("expected the document to keep its string _id, got %v", document[0].Value)
+	}
+
+	filter := map[string]interface{}{"_id": id}
+	MapFilter(filter)
+	if filter["_id"] != id {
+		t.Errorf("expected the map to keep its string _id, got %v", filter["_id"])
+	}
+}
+
+func TestCloneFilter(t *testing.T) {
+	filter := bson.M{
+		"$and":   []bson.M{{"name": "a"}, nil},
+		"_id":    bson.D{{Key: "$in", Value: bson.A{"a"}}},
+		"tags":   []string{"a"},
+		"nested": map[string]interface{}{"name": "a"},
+	}
+	cloned := CloneFilter(filter).(bson.M)
+	if !reflect.DeepEqual(cloned, filter) {
+		t.Fatalf("expected %v, got %v", filter, cloned)
+	}
+
+	cloned["$and"].([]bson.M)[0]["name"] = "b"
+	cloned["_id"].(bson.D)[0].Value.(bson.A)[0] = "b"
+	cloned["tags"].([]string)[0] = "b"
+	cloned["nested"].(map[string]interface{})["name"] = "b"
+	cloned["name"] = "b"
+
+	expected := bson.M{
+		"$and":   []bson.M{{"name": "a"}, nil},
+		"_id":    bson.D{{Key: "$in", Value: bson.A{"a"}}},
+		"tags":   []string{"a"},
+		"nested": map[string]interface{}{"name": "a"},
+	}
+	if !reflect.DeepEqual(filter, expected) {
+		t.Errorf("expected the original to be left as is, got %v", filter)
+	}
+}
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -20,13 +24,94 @@ type FindOptions struct {
 	Skip       int64
 	Sort       interface{}
 	Projection interface{}
+	// BatchSize is how many documents each round trip of a cursor fetches, zero uses the server default
+	BatchSize int32
+}
+
+type FindOneAndUpdateOptions struct {
+	Sort       interface{}
+	Projection interface{}
+	Upsert     bool
+	// ReturnAfter returns the document as it is after the update instead of before
+	ReturnAfter bool
 }
 
-// PaginationOptions represents pagination settings
+// BulkWriteResult aggregates the counts of every operation of a bulk write
+type BulkWriteResult struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	UpsertedCount int64
+	// UpsertedIDs maps the index of an upsert operation to the ID of the document it inserted
+	UpsertedIDs map[int64]interface{}
+}
+
+// PaginationOptions represents pagination settings. Pages are fetched by offset with Page, or by
+// keyset with a Cursor returned along with a previous page, which stays fast on deep pages.
 type PaginationOptions struct {
 	Page     int64
 	PageSize int64
+	// Cursor is the next or previous cursor of a Page, Page is ignored when it is set
+	Cursor string
+	// SortField orders the pages, documents with equal values are ordered by _id. Defaults to _id.
+	SortField  string
+	Descending bool
+	// IncludeTotal counts every document matching the filter
+	IncludeTotal bool
+}
+
+// Page is a page of documents returned by Paginate
+type Page[T any] struct {
+	Items []*T
+	// NextCursor and PrevCursor fetch the adjacent pages, they are empty on the last and first page
+	NextCursor string
+	PrevCursor string
+	// Total is only counted when IncludeTotal is set
+	Total int64
 }
 
 // FilterOptions represents a basic filter
 type FilterOptions map[string]interface{}
+
+// OperationType is the kind of change reported by a ChangeEvent
+type OperationType string
+
+const (
+	OperationInsert  OperationType = "insert"
+	OperationUpdate  OperationType = "update"
+	OperationReplace OperationType = "replace"
+	OperationDelete  OperationType = "delete"
+)
+
+// ChangeEvent is a change to a watched document
+type ChangeEvent[T any] struct {
+	Operation OperationType
+	// ID is the _id of the changed document, ObjectIDs are given as hex strings
+	ID string
+	// Document is the document after the change, nil for deletes
+	Document *T
+	At       time.Time
+	// Err reports a failure of the subscription, which keeps retrying. The other fields are empty.
+	Err error
+}
+
+// ResumeTokenStore persists how far a subscription has read, so it resumes there after a restart
+type ResumeTokenStore interface {
+	// Load returns the stored token of a subscription, nil when there is none
+	Load(ctx context.Context, name string) (bson.Raw, error)
+	Save(ctx context.Context, name string, token bson.Raw) error
+}
+
+// WatchOptions configures a subscription to changes
+type WatchOptions struct {
+	// Name identifies the subscription in TokenStore. Without both, the subscription starts from now.
+	Name       string
+	TokenStore ResumeTokenStore
+	// PollInterval is how often changes are polled when change streams are not supported
+	PollInterval time.Duration
+	// PollField is a time field set on every write, polling reads the documents it moved past.
+	// When empty every poll reads all documents and compares them with the previous poll, which
+	// only suits small collections.
+	PollField string
+}
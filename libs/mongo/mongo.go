@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,6 +19,7 @@ type IMongoClient interface {
 	Disconnect(ctx context.Context) error
 	Ping(ctx context.Context) error
 	GetCollection(ctx context.Context, name string) (*models.MongoCollection, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type MongoClient struct {
@@ -25,6 +27,9 @@ type MongoClient struct {
 	Client   *mongo.Client
 	Database *mongo.Database
 	Logger   logger.ILogger
+
+	transactionsOnce sync.Once
+	transactions     bool
 }
 
 func NewMongoConfig(mongoURL, databaseName string) *models.Config {
@@ -0,0 +1,80 @@
+package query
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Eq matches documents whose field equals value
+func Eq(field string, value interface{}) bson.D {
+	return bson.D{{Key: field, Value: value}}
+}
+
+// In matches documents whose field equals one of values
+func In(field string, values ...interface{}) bson.D {
+	return bson.D{{Key: field, Value: bson.D{{Key: "$in", Value: bson.A(values)}}}}
+}
+
+// Regex matches documents whose field contains text. text is escaped, so user input never runs as
+// a pattern. options are the regex options of MongoDB, such as "i" for a case insensitive match.
+// An empty text does not filter anything.
+func Regex(field, text, options string) bson.D {
+	if text == "" {
+		return bson.D{}
+	}
+	return bson.D{{Key: field, Value: bson.D{
+		{Key: "$regex", Value: regexp.QuoteMeta(text)},
+		{Key: "$options", Value: options},
+	}}}
+}
+
+// Range matches documents whose field is at least from and less than to, a nil bound leaves that
+// side of the range open
+func Range(field string, from, to interface{}) bson.D {
+	bounds := bson.D{}
+	if from != nil {
+		bounds = append(bounds, bson.E{Key: "$gte", Value: from})
+	}
+	if to != nil {
+		bounds = append(bounds, bson.E{Key: "$lt", Value: to})
+	}
+	if len(bounds) == 0 {
+		return bson.D{}
+	}
+	return bson.D{{Key: field, Value: bounds}}
+}
+
+// Exists matches documents that have field, or lack it when exists is false
+func Exists(field string, exists bool) bson.D {
+	return bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: exists}}}}
+}
+
+// And matches documents matching every filter, empty filters are ignored
+func And(filters ...bson.D) bson.D {
+	return combine("$and", filters)
+}
+
+// Or matches documents matching any filter, empty filters are ignored
+func Or(filters ...bson.D) bson.D {
+	return combine("$or", filters)
+}
+
+// combine joins the non-empty filters with a logical operator, a single filter is returned as is
+func combine(operator string, filters []bson.D) bson.D {
+	clauses := bson.A{}
+	for _, filter := range filters {
+		if len(filter) > 0 {
+			clauses = append(clauses, filter)
+		}
+	}
+
+	switch len(clauses) {
+	case 0:
+		return bson.D{}
+	case 1:
+		return clauses[0].(bson.D)
+	default:
+		return bson.D{{Key: operator, Value: clauses}}
+	}
+}
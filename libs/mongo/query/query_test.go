@@ -0,0 +1,54 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuilders(t *testing.T) {
+	cases := []struct {
+		name     string
+		got      bson.D
+		expected bson.D
+	}{
+		{"eq", Eq("sku", "A1"), bson.D{{Key: "sku", Value: "A1"}}},
+		{"in", In("status", "a", "b"), bson.D{{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{"a", "b"}}}}}},
+		{"regex escapes its text", Regex("name", "a.b*", "i"), bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: `a\.b\*`}, {Key: "$options", Value: "i"}}}}},
+		{"empty regex", Regex("name", "", "i"), bson.D{}},
+		{"range", Range("price", 1, 10), bson.D{{Key: "price", Value: bson.D{{Key: "$gte", Value: 1}, {Key: "$lt", Value: 10}}}}},
+		{"open range", Range("price", nil, 10), bson.D{{Key: "price", Value: bson.D{{Key: "$lt", Value: 10}}}}},
+		{"unbounded range", Range("price", nil, nil), bson.D{}},
+		{"exists", Exists("deletedAt", false), bson.D{{Key: "deletedAt", Value: bson.D{{Key: "$exists", Value: false}}}}},
+	}
+
+	for _, tc := range cases {
+		if !reflect.DeepEqual(tc.got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	a := Eq("a", 1)
+	b := Eq("b", 2)
+
+	cases := []struct {
+		name     string
+		got      bson.D
+		expected bson.D
+	}{
+		{"and", And(a, b), bson.D{{Key: "$and", Value: bson.A{a, b}}}},
+		{"or", Or(a, b), bson.D{{Key: "$or", Value: bson.A{a, b}}}},
+		{"empty filters are ignored", And(a, Regex("name", "", "i"), b), bson.D{{Key: "$and", Value: bson.A{a, b}}}},
+		{"single filter", Or(Regex("name", "", "i"), a), a},
+		{"no filter", And(), bson.D{}},
+	}
+
+	for _, tc := range cases {
+		if !reflect.DeepEqual(tc.got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+}
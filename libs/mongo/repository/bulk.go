@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/mappers"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BulkWrite collects insert, update, upsert and delete operations on documents of type T to run
+// them with Repository.BulkWrite. Operations run in order and stop at the first failure unless
+// Unordered is called.
+type BulkWrite[T any] struct {
+	ops     []bulkOp
+	ordered bool
+}
+
+type bulkOpKind int
+
+const (
+	bulkInsert bulkOpKind = iota
+	bulkUpdate
+	bulkUpsert
+	bulkDelete
+)
+
+// bulkOp is an operation of a bulk write, it is turned into a write model by the repository running
+// it so that its soft delete mode applies
+type bulkOp struct {
+	kind     bulkOpKind
+	document interface{}
+	filter   interface{}
+	update   interface{}
+	onInsert map[string]interface{}
+}
+
+// NewBulkWrite returns an empty, ordered bulk write
+func NewBulkWrite[T any]() *BulkWrite[T] {
+	return &BulkWrite[T]{ordered: true}
+}
+
+// Unordered lets the server run the operations in any order and go on after a failure
+func (b *BulkWrite[T]) Unordered() *BulkWrite[T] {
+	b.ordered = false
+	return b
+}
+
+// Insert adds a document
+func (b *BulkWrite[T]) Insert(data *T) *BulkWrite[T] {
+	b.ops = append(b.ops, bulkOp{kind: bulkInsert, document: data})
+	return b
+}
+
+// Update sets the fields of update on the first document matching the filter
+func (b *BulkWrite[T]) Update(filter interface{}, update interface{}) *BulkWrite[T] {
+	b.ops = append(b.ops, bulkOp{kind: bulkUpdate, filter: filter, update: update})
+	return b
+}
+
+// Upsert sets the fields of update on the first document matching the filter, or inserts a document
+// made of the equality fields of the filter, update and onInsert when none matches. onInsert may be nil.
+// In soft delete mode a deleted document matching the filter is restored.
+func (b *BulkWrite[T]) Upsert(filter interface{}, update interface{}, onInsert map[string]interface{}) *BulkWrite[T] {
+	b.ops = append(b.ops, bulkOp{kind: bulkUpsert, filter: filter, update: update, onInsert: onInsert})
+	return b
+}
+
+// Delete removes the documents matching the filter, or marks them as deleted in soft delete mode
+func (b *BulkWrite[T]) Delete(filter interface{}) *BulkWrite[T] {
+	b.ops = append(b.ops, bulkOp{kind: bulkDelete, filter: filter})
+	return b
+}
+
+// Len returns the number of operations collected so far
+func (b *BulkWrite[T]) Len() int {
+	return len(b.ops)
+}
+
+// writeModels turns the operations of bulk into write models, scoped like the other operations of
+// the repository
+func (r *Repository[T]) writeModels(bulk *BulkWrite[T], now time.Time) []mongo.WriteModel {
+	writes := make([]mongo.WriteModel, 0, len(bulk.ops))
+	for _, op := range bulk.ops {
+		switch op.kind {
+		case bulkInsert:
+			writes = append(writes, mongo.NewInsertOneModel().SetDocument(op.document))
+		case bulkUpdate:
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(r.scope(op.filter)).
+				SetUpdate(bson.M{"$set": op.update}))
+		case bulkUpsert:
+			filter, updateDoc := r.upsertQuery(op.filter, op.update)
+			if len(op.onInsert) > 0 {
+				updateDoc["$setOnInsert"] = op.onInsert
+			}
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(updateDoc).
+				SetUpsert(true))
+		case bulkDelete:
+			if r.SoftDelete {
+				writes = append(writes, mongo.NewUpdateManyModel().
+					SetFilter(r.scope(op.filter)).
+					SetUpdate(bson.M{"$set": bson.M{DeletedAtField: now}}))
+			} else {
+				writes = append(writes, mongo.NewDeleteManyModel().SetFilter(mappers.MapFilter(op.filter)))
+			}
+		}
+	}
+	return writes
+}
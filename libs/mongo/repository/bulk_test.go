@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type item struct {
+	ID   string `bson:"_id,omitempty"`
+	Name string `bson:"name"`
+}
+
+func TestWriteModels(t *testing.T) {
+	id := primitive.NewObjectID()
+	doc := &item{Name: "a"}
+	bulk := NewBulkWrite[item]().
+		Insert(doc).
+		Update(map[string]interface{}{"_id": id.Hex()}, map[string]interface{}{"name": "b"}).
+		Upsert(map[string]interface{}{"name": "c"}, map[string]interface{}{"name": "c"}, map[string]interface{}{"created": true}).
+		Delete(map[string]interface{}{"name": "d"})
+
+	if bulk.Len() != 4 {
+		t.Fatalf("expected 4 operations, got %d", bulk.Len())
+	}
+
+	repo := &Repository[item]{}
+	writes := repo.writeModels(bulk, time.Now())
+
+	cases := []struct {
+		name     string
+		got      mongo.WriteModel
+		expected mongo.WriteModel
+	}{
+		{"insert", writes[0], mongo.NewInsertOneModel().SetDocument(doc)},
+		{"update", writes[1], mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id}).
+			SetUpdate(bson.M{"$set": map[string]interface{}{"name": "b"}})},
+		{"upsert", writes[2], mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"name": "c"}).
+			SetUpdate(bson.M{"$set": map[string]interface{}{"name": "c"}, "$setOnInsert": map[string]interface{}{"created": true}}).
+			SetUpsert(true)},
+		{"delete", writes[3], mongo.NewDeleteManyModel().SetFilter(bson.M{"name": "d"})},
+	}
+
+	for _, tc := range cases {
+		if !reflect.DeepEqual(tc.got, tc.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.expected, tc.got)
+		}
+	}
+}
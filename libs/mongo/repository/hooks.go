@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/hooks"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/mappers"
+)
+
+// RegisterHooks adds hooks called around the operations of the repository, in registration order.
+// Hooks are registered before the repository is used, BulkWrite, Aggregate and Purge bypass them
+// while soft deletes and restores run the update hooks.
+func (r *Repository[T]) RegisterHooks(h ...hooks.IHook) {
+	r.Hooks = append(r.Hooks, h...)
+}
+
+// runHooks calls fn with every registered hook until one fails
+func (r *Repository[T]) runHooks(fn func(hook hooks.IHook) error) error {
+	for _, hook := range r.Hooks {
+		if err := fn(hook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeFind runs the BeforeFind hooks on a copy of filter and returns the copy to query with. Hooks
+// rewrite the filter they are given, the filter of the caller is left as is.
+func (r *Repository[T]) beforeFind(ctx context.Context, filter interface{}) (interface{}, error) {
+	if len(r.Hooks) == 0 {
+		return filter, nil
+	}
+	filter = mappers.CloneFilter(filter)
+	return filter, r.runHooks(func(hook hooks.IHook) error { return hook.BeforeFind(ctx, filter) })
+}
+
+func (r *Repository[T]) afterFind(ctx context.Context, doc *T) error {
+	return r.runHooks(func(hook hooks.IHook) error { return hook.AfterFind(ctx, doc) })
+}
+
+// beforeUpdate runs the BeforeUpdate hooks on copies of filter and update, like beforeFind. Struct
+// updates are not copied, hooks set their fields on the struct of the caller.
+func (r *Repository[T]) beforeUpdate(ctx context.Context, filter, update interface{}) (interface{}, interface{}, error) {
+	if len(r.Hooks) == 0 {
+		return filter, update, nil
+	}
+	filter, update = mappers.CloneFilter(filter), mappers.CloneFilter(update)
+	return filter, update, r.runHooks(func(hook hooks.IHook) error { return hook.BeforeUpdate(ctx, filter, update) })
+}
+
+// beforeDelete runs the BeforeDelete hooks on a copy of filter, like beforeFind
+func (r *Repository[T]) beforeDelete(ctx context.Context, filter interface{}) (interface{}, error) {
+	if len(r.Hooks) == 0 {
+		return filter, nil
+	}
+	filter = mappers.CloneFilter(filter)
+	return filter, r.runHooks(func(hook hooks.IHook) error { return hook.BeforeDelete(ctx, filter) })
+}
+
+func (r *Repository[T]) afterUpdate(ctx context.Context, filter, update interface{}) error {
+	return r.runHooks(func(hook hooks.IHook) error { return hook.AfterUpdate(ctx, filter, update) })
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/hooks"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBeforeHooks_LeaveCallerFilterAsIs(t *testing.T) {
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	repo := &Repository[item]{}
+	repo.RegisterHooks(&hooks.StringToMongoIDHook{}, hooks.NewTimestampHook("updatedAt"))
+
+	filter := bson.M{"$or": bson.A{bson.M{"_id": id.Hex()}, bson.M{"_id": bson.M{"$in": bson.A{id.Hex()}}}}}
+	original := bson.M{"$or": bson.A{bson.M{"_id": id.Hex()}, bson.M{"_id": bson.M{"$in": bson.A{id.Hex()}}}}}
+	expected := bson.M{"$or": bson.A{bson.M{"_id": id}, bson.M{"_id": bson.M{"$in": bson.A{id}}}}}
+
+	query, err := repo.beforeFind(ctx, filter)
+	if err != nil {
+		t.Fatalf("find: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(query, expected) {
+		t.Errorf("find: expected %v, got %v", expected, query)
+	}
+
+	query, err = repo.beforeDelete(ctx, filter)
+	if err != nil {
+		t.Fatalf("delete: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(query, expected) {
+		t.Errorf("delete: expected %v, got %v", expected, query)
+	}
+
+	update := map[string]interface{}{"name": "a"}
+	query, updated, err := repo.beforeUpdate(ctx, filter, update)
+	if err != nil {
+		t.Fatalf("update: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(query, expected) {
+		t.Errorf("update: expected %v, got %v", expected, query)
+	}
+	if _, ok := updated.(map[string]interface{})["updatedAt"]; !ok {
+		t.Errorf("update: expected updatedAt to be set, got %v", updated)
+	}
+
+	if !reflect.DeepEqual(filter, original) {
+		t.Errorf("expected the filter of the caller to be left as is, got %v", filter)
+	}
+	if len(update) != 1 {
+		t.Errorf("expected the update of the caller to be left as is, got %v", update)
+	}
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"strings"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultPageSize is the size of a page when PaginationOptions does not set one
+const DefaultPageSize = 20
+
+// pageCursor is the position of a document in the sort order, encoded as an opaque string
+type pageCursor struct {
+	Field string        `bson:"f"`
+	Value bson.RawValue `bson:"v"`
+	ID    bson.RawValue `bson:"i"`
+	// Before fetches the page before the document instead of the page after it
+	Before bool `bson:"b"`
+}
+
+// Paginate returns a page of the documents matching a filter in the order of opts.SortField. Every
+// page holds cursors to its adjacent pages, including pages fetched by offset.
+func (r *Repository[T]) Paginate(ctx context.Context, filter interface{}, opts models.PaginationOptions) (*models.Page[T], error) {
+	pageSize := opts.PageSize
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	sortField := opts.SortField
+	if sortField == "" {
+		sortField = "_id"
+	}
+	order := 1
+	if opts.Descending {
+		order = -1
+	}
+
+	filter, err := r.beforeFind(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.scope(filter)
+	findOptions := options.Find().SetLimit(pageSize + 1)
+
+	backward := false
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor)
+		if err != nil || cursor.Field != sortField {
+			return nil, errors.ErrInvalidCursor
+		}
+		backward = cursor.Before
+		query = bson.D{{Key: "$and", Value: bson.A{query, keysetFilter(cursor, order)}}}
+	} else if opts.Page > 1 {
+		findOptions.SetSkip((opts.Page - 1) * pageSize)
+	}
+
+	// The page before a cursor is fetched in reverse order, then put back in order
+	fetchOrder := order
+	if backward {
+		fetchOrder = -order
+	}
+	sort := bson.D{{Key: sortField, Value: fetchOrder}}
+	if sortField != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: fetchOrder})
+	}
+	findOptions.SetSort(sort)
+
+	cursor, err := r.Collection.Collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var documents []bson.Raw
+	for cursor.Next(ctx) {
+		documents = append(documents, slices.Clone(cursor.Current))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	// One extra document tells whether there is a page beyond this one
+	hasMore := int64(len(documents)) > pageSize
+	if hasMore {
+		documents = documents[:pageSize]
+	}
+	if backward {
+		slices.Reverse(documents)
+	}
+
+	page := &models.Page[T]{Items: make([]*T, 0, len(documents))}
+	for _, document := range documents {
+		var item T
+		if err := bson.Unmarshal(document, &item); err != nil {
+			return nil, err
+		}
+		if err := r.afterFind(ctx, &item); err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, &item)
+	}
+
+	if len(documents) > 0 {
+		first, last := documents[0], documents[len(documents)-1]
+		if backward {
+			page.NextCursor = encodeCursor(sortField, last, false)
+			if hasMore {
+				page.PrevCursor = encodeCursor(sortField, first, true)
+			}
+		} else {
+			if hasMore {
+				page.NextCursor = encodeCursor(sortField, last, false)
+			}
+			if opts.Cursor != "" || opts.Page > 1 {
+				page.PrevCursor = encodeCursor(sortField, first, true)
+			}
+		}
+	}
+
+	if opts.IncludeTotal {
+		page.Total, err = r.Count(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// keysetFilter matches the documents after the cursor in the sort order, or before it
+func keysetFilter(cursor *pageCursor, order int) bson.D {
+	operator := "$gt"
+	if (order < 0) != cursor.Before {
+		operator = "$lt"
+	}
+
+	if cursor.Field == "_id" {
+		return bson.D{{Key: "_id", Value: bson.D{{Key: operator, Value: cursor.ID}}}}
+	}
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: cursor.Field, Value: bson.D{{Key: operator, Value: cursor.Value}}}},
+		bson.D{{Key: cursor.Field, Value: cursor.Value}, {Key: "_id", Value: bson.D{{Key: operator, Value: cursor.ID}}}},
+	}}}
+}
+
+// encodeCursor returns the cursor of a document for the given sort field
+func encodeCursor(field string, document bson.Raw, before bool) string {
+	value, err := document.LookupErr(strings.Split(field, ".")...)
+	if err != nil {
+		value = bson.RawValue{Type: bson.TypeNull}
+	}
+
+	encoded, err := bson.Marshal(pageCursor{
+		Field:  field,
+		Value:  value,
+		ID:     document.Lookup("_id"),
+		Before: before,
+	})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(encoded string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor pageCursor
+	if err := bson.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	document, err := bson.Marshal(bson.D{{Key: "_id", Value: id}, {Key: "price", Value: bson.D{{Key: "amount", Value: 42}}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		field  string
+		before bool
+		null   bool
+	}{
+		{"id", "_id", false, false},
+		{"nested field", "price.amount", true, false},
+		{"missing field", "name", false, true},
+	}
+
+	for _, tc := range cases {
+		cursor, err := decodeCursor(encodeCursor(tc.field, document, tc.before))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if cursor.Field != tc.field || cursor.Before != tc.before || cursor.ID.ObjectID() != id {
+			t.Errorf("%s: unexpected cursor %+v", tc.name, cursor)
+		}
+		if (cursor.Value.Type == bson.TypeNull) != tc.null {
+			t.Errorf("%s: unexpected cursor value %v", tc.name, cursor.Value)
+		}
+	}
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, encoded := range []string{"not base64!", "AAAA"} {
+		if _, err := decodeCursor(encoded); err == nil {
+			t.Errorf("%q: expected an error", encoded)
+		}
+	}
+}
+
+func TestKeysetFilter_Operator(t *testing.T) {
+	cases := []struct {
+		name     string
+		order    int
+		before   bool
+		expected string
+	}{
+		{"ascending, next page", 1, false, "$gt"},
+		{"ascending, previous page", 1, true, "$lt"},
+		{"descending, next page", -1, false, "$lt"},
+		{"descending, previous page", -1, true, "$gt"},
+	}
+
+	for _, tc := range cases {
+		filter := keysetFilter(&pageCursor{Field: "_id", Before: tc.before}, tc.order)
+		operator := filter[0].Value.(bson.D)[0].Key
+		if operator != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, operator)
+		}
+	}
+}
@@ -2,8 +2,13 @@ package repository
 
 import (
 	"context"
+	stderrors "errors"
+	"iter"
+	"time"
 
+	"github.com/himdhiman/dashboard-backend/libs/mongo/aggregate"
 	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/hooks"
 	"github.com/himdhiman/dashboard-backend/libs/mongo/mappers"
 	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,21 +17,42 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// IRepository is the data access of a collection of documents of type T. Filters are maps, such as
+// map[string]interface{}, or documents built with the query package.
 type IRepository[T any] interface {
 	CreateIndex(ctx context.Context, keys bson.D, unique bool) error
+	CreatePartialIndex(ctx context.Context, keys bson.D, filter interface{}) error
 
 	Create(ctx context.Context, data *T) (string, error)
 	FindByID(ctx context.Context, id string) (*T, error)
-	Find(ctx context.Context, filter map[string]interface{}, opts ...*models.FindOptions) ([]*T, error)
-	FindOne(ctx context.Context, filter map[string]interface{}, opts ...*models.FindOptions) (*T, error)
-	Update(ctx context.Context, filter map[string]interface{}, update interface{}) (*models.UpdateResult, error)
-	Delete(ctx context.Context, filter map[string]interface{}) (int64, error)
-	Count(ctx context.Context, filter map[string]interface{}) (int64, error)
+	Find(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*T, error)
+	Iterate(ctx context.Context, filter interface{}, opts *models.FindOptions, fn func(*T) error) error
+	All(ctx context.Context, filter interface{}, opts ...*models.FindOptions) iter.Seq2[*T, error]
+	FindOne(ctx context.Context, filter interface{}, opts ...*models.FindOptions) (*T, error)
+	FindWithDeleted(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*T, error)
+	Update(ctx context.Context, filter interface{}, update interface{}) (*models.UpdateResult, error)
+	Upsert(ctx context.Context, filter interface{}, data interface{}) (*models.UpdateResult, error)
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*models.FindOneAndUpdateOptions) (*T, error)
+	UpdateVersion(ctx context.Context, filter interface{}, version int64, update interface{}) (*T, error)
+	BulkWrite(ctx context.Context, bulk *BulkWrite[T]) (*models.BulkWriteResult, error)
+	Delete(ctx context.Context, filter interface{}) (int64, error)
+	Restore(ctx context.Context, filter interface{}) (int64, error)
+	Purge(ctx context.Context, retention time.Duration) (int64, error)
+	Count(ctx context.Context, filter interface{}) (int64, error)
+	Paginate(ctx context.Context, filter interface{}, opts models.PaginationOptions) (*models.Page[T], error)
+	Watch(ctx context.Context, filter interface{}, opts *models.WatchOptions) (<-chan models.ChangeEvent[T], error)
+
+	RegisterHooks(hooks ...hooks.IHook)
 }
 
+// Repository implements IRepository on a collection. In SoftDelete mode Delete sets DeletedAtField
+// instead of removing documents and every other operation except BulkWrite and Aggregate leaves the
+// deleted documents out.
 type Repository[T any] struct {
 	IRepository[T]
 	Collection *models.MongoCollection
+	Hooks      []hooks.IHook
+	SoftDelete bool
 }
 
 // NewRepository initializes a new repository
@@ -44,26 +70,47 @@ func (r *Repository[T]) CreateIndex(ctx context.Context, keys bson.D, unique boo
 	return err
 }
 
+// CreatePartialIndex creates a compound index unique among the documents matching the filter
+func (r *Repository[T]) CreatePartialIndex(ctx context.Context, keys bson.D, filter interface{}) error {
+	index := mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(mappers.MapFilter(filter)),
+	}
+	_, err := r.Collection.Collection.Indexes().CreateOne(ctx, index)
+	return err
+}
+
 // Create adds a document to the collection
 func (r *Repository[T]) Create(ctx context.Context, data *T) (string, error) {
+	if err := r.runHooks(func(hook hooks.IHook) error { return hook.BeforeCreate(ctx, data) }); err != nil {
+		return "", err
+	}
+
 	result, err := r.Collection.Collection.InsertOne(ctx, data)
 	if err != nil {
-		return "", errors.ErrInsertFailed
+		return "", errors.Wrap(errors.ErrInsertFailed, err)
 	}
 
 	id, ok := result.InsertedID.(primitive.ObjectID)
 	if !ok {
 		return "", errors.ErrParseInsertedID
 	}
+	if err := r.runHooks(func(hook hooks.IHook) error { return hook.AfterCreate(ctx, data, id.Hex()) }); err != nil {
+		return "", err
+	}
 	return id.Hex(), nil
 }
 
 // Count returns the number of documents matching the filter
-func (r *Repository[T]) Count(ctx context.Context, filter map[string]interface{}) (int64, error) {
-	bsonFilters := mappers.MapToBson(filter)
+func (r *Repository[T]) Count(ctx context.Context, filter interface{}) (int64, error) {
+	filter, err := r.beforeFind(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	bsonFilters := r.scope(filter)
 	count, err := r.Collection.Collection.CountDocuments(ctx, bsonFilters)
 	if err != nil {
-		return 0, errors.ErrCountFailed
+		return 0, errors.Wrap(errors.ErrCountFailed, err)
 	}
 	return count, nil
 }
@@ -75,38 +122,60 @@ func (r *Repository[T]) FindByID(ctx context.Context, id string) (*T, error) {
 		return nil, errors.ErrInvalidObjectID
 	}
 
-	filter := bson.M{"_id": objID}
+	filter, err := r.beforeFind(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return nil, err
+	}
+
 	var result T
-	if err := r.Collection.Collection.FindOne(ctx, filter).Decode(&result); err != nil {
+	if err := r.Collection.Collection.FindOne(ctx, r.scope(filter)).Decode(&result); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, errors.ErrDocumentNotFound
 		}
 		return nil, err
 	}
+	if err := r.afterFind(ctx, &result); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
 // FindOne retrieves a single document matching the filter with optional find options
-func (r *Repository[T]) FindOne(ctx context.Context, filter map[string]interface{}, opts ...*models.FindOptions) (*T, error) {
-	bsonFilters := mappers.MapToBson(filter)
+func (r *Repository[T]) FindOne(ctx context.Context, filter interface{}, opts ...*models.FindOptions) (*T, error) {
+	filter, err := r.beforeFind(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	bsonFilters := r.scope(filter)
 	mongoFindOptions := mappers.MapFindOneOptions(opts...)
 
 	var result T
-	err := r.Collection.Collection.FindOne(ctx, bsonFilters, mongoFindOptions).Decode(&result)
+	err = r.Collection.Collection.FindOne(ctx, bsonFilters, mongoFindOptions).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, errors.ErrDocumentNotFound
 		}
 		return nil, err
 	}
+	if err := r.afterFind(ctx, &result); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
 // Find retrieves documents matching a filter
-func (r *Repository[T]) Find(ctx context.Context, filter map[string]interface{}, opts ...*models.FindOptions) ([]*T, error) {
-	bsonFilters := mappers.MapToBson(filter)
+func (r *Repository[T]) Find(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*T, error) {
+	return r.find(ctx, filter, r.scope, opts...)
+}
+
+// find runs the find hooks on filter and retrieves the documents matching the query mapped from it
+func (r *Repository[T]) find(ctx context.Context, filter interface{}, mapQuery func(filter interface{}) interface{}, opts ...*models.FindOptions) ([]*T, error) {
+	filter, err := r.beforeFind(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
 	mongoFindOptions := mappers.MapFindOptions(opts...)
-	cursor, err := r.Collection.Collection.Find(ctx, bsonFilters, mongoFindOptions)
+	cursor, err := r.Collection.Collection.Find(ctx, mapQuery(filter), mongoFindOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -118,28 +187,200 @@ func (r *Repository[T]) Find(ctx context.Context, filter map[string]interface{},
 		if err := cursor.Decode(&item); err != nil {
 			return nil, err
 		}
+		if err := r.afterFind(ctx, &item); err != nil {
+			return nil, err
+		}
 		results = append(results, &item)
 	}
 	return results, nil
 }
 
+// Iterate streams the documents matching a filter to fn one at a time, only the current batch of
+// the cursor is held in memory. Iteration ends at the first error returned by fn, returning
+// ErrStopIteration ends it without an error.
+func (r *Repository[T]) Iterate(ctx context.Context, filter interface{}, opts *models.FindOptions, fn func(*T) error) error {
+	filter, err := r.beforeFind(ctx, filter)
+	if err != nil {
+		return err
+	}
+	bsonFilters := r.scope(filter)
+	mongoFindOptions := mappers.MapFindOptions(opts)
+	cursor, err := r.Collection.Collection.Find(ctx, bsonFilters, mongoFindOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		if err := r.afterFind(ctx, &item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			if stderrors.Is(err, errors.ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// All returns an iterator over the documents matching a filter for use with range. A failure is
+// yielded as the last element, breaking out of the loop closes the cursor.
+func (r *Repository[T]) All(ctx context.Context, filter interface{}, opts ...*models.FindOptions) iter.Seq2[*T, error] {
+	var findOptions *models.FindOptions
+	if len(opts) > 0 {
+		findOptions = opts[0]
+	}
+
+	return func(yield func(*T, error) bool) {
+		err := r.Iterate(ctx, filter, findOptions, func(item *T) error {
+			if !yield(item, nil) {
+				return errors.ErrStopIteration
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Aggregate runs pipeline on the collection of r and decodes every result into R. In soft delete mode
+// the pipeline starts from the documents that are not deleted.
+func Aggregate[R, T any](ctx context.Context, r *Repository[T], pipeline *aggregate.Pipeline) ([]R, error) {
+	return aggregateStages[R](ctx, r, r.scopePipeline(pipeline.Stages()))
+}
+
+func aggregateStages[R, T any](ctx context.Context, r *Repository[T], stages mongo.Pipeline) ([]R, error) {
+	cursor, err := r.Collection.Collection.Aggregate(ctx, stages)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []R{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 // Update updates documents matching the filter
-func (r *Repository[T]) Update(ctx context.Context, filter map[string]interface{}, update interface{}) (*models.UpdateResult, error) {
-	bsonFilters := mappers.MapToBson(filter)
+func (r *Repository[T]) Update(ctx context.Context, filter interface{}, update interface{}) (*models.UpdateResult, error) {
+	filter, update, err := r.beforeUpdate(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	bsonFilters := r.scope(filter)
 	updateDoc := bson.M{"$set": update}
 	result, err := r.Collection.Collection.UpdateMany(ctx, bsonFilters, updateDoc)
 	if err != nil {
-		return nil, errors.ErrUpdateFailed
+		return nil, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+	if err := r.afterUpdate(ctx, filter, update); err != nil {
+		return nil, err
 	}
 	return mappers.MapUpdateResult(result), nil
 }
 
-// Delete removes documents matching the filter
-func (r *Repository[T]) Delete(ctx context.Context, filter map[string]interface{}) (int64, error) {
-	bsonFilters := mappers.MapToBson(filter)
-	result, err := r.Collection.Collection.DeleteMany(ctx, bsonFilters)
+// Upsert sets the fields of data on the document matching the filter, or inserts it when none matches.
+// In soft delete mode a deleted document matching the filter is restored.
+func (r *Repository[T]) Upsert(ctx context.Context, filter interface{}, data interface{}) (*models.UpdateResult, error) {
+	filter, data, err := r.beforeUpdate(ctx, filter, data)
 	if err != nil {
-		return 0, errors.ErrDeleteFailed
+		return nil, err
+	}
+
+	bsonFilters, updateDoc := r.upsertQuery(filter, data)
+	result, err := r.Collection.Collection.UpdateOne(ctx, bsonFilters, updateDoc, options.Update().SetUpsert(true))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+	if err := r.afterUpdate(ctx, filter, data); err != nil {
+		return nil, err
+	}
+	return mappers.MapUpdateResult(result), nil
+}
+
+// FindOneAndUpdate atomically sets the fields of update on a single document matching the filter
+// and returns it, as it was before the update unless ReturnAfter is set
+func (r *Repository[T]) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*models.FindOneAndUpdateOptions) (*T, error) {
+	filter, update, err := r.beforeUpdate(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	bsonFilters := r.scope(filter)
+	updateDoc := bson.M{"$set": update}
+	mongoOptions := mappers.MapFindOneAndUpdateOptions(opts...)
+
+	var result T
+	err = r.Collection.Collection.FindOneAndUpdate(ctx, bsonFilters, updateDoc, mongoOptions).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrDocumentNotFound
+		}
+		return nil, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+	if err := r.afterUpdate(ctx, filter, update); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BulkWrite runs the operations of bulk in a single batch of round trips. In soft delete mode updates
+// skip deleted documents and deletes mark documents as deleted, counted as modified.
+func (r *Repository[T]) BulkWrite(ctx context.Context, bulk *BulkWrite[T]) (*models.BulkWriteResult, error) {
+	if bulk.Len() == 0 {
+		return &models.BulkWriteResult{}, nil
+	}
+
+	result, err := r.Collection.Collection.BulkWrite(ctx, r.writeModels(bulk, time.Now()), options.BulkWrite().SetOrdered(bulk.ordered))
+	if err != nil {
+		// Unordered writes go on after a failure, the result still counts what was written
+		return mappers.MapBulkWriteResult(result), errors.Wrap(errors.ErrBulkWriteFailed, err)
+	}
+	return mappers.MapBulkWriteResult(result), nil
+}
+
+// Delete removes documents matching the filter, or marks them as deleted in soft delete mode
+func (r *Repository[T]) Delete(ctx context.Context, filter interface{}) (int64, error) {
+	filter, err := r.beforeDelete(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	if r.SoftDelete {
+		set, err := r.deleteUpdate(ctx, filter, time.Now())
+		if err != nil {
+			return 0, err
+		}
+
+		result, err := r.Collection.Collection.UpdateMany(ctx, r.scope(filter), bson.M{"$set": set})
+		if err != nil {
+			return 0, errors.Wrap(errors.ErrDeleteFailed, err)
+		}
+		if err := r.afterUpdate(ctx, filter, set); err != nil {
+			return 0, err
+		}
+		deleted = result.ModifiedCount
+	} else {
+		result, err := r.Collection.Collection.DeleteMany(ctx, mappers.MapFilter(filter))
+		if err != nil {
+			return 0, errors.Wrap(errors.ErrDeleteFailed, err)
+		}
+		deleted = result.DeletedCount
+	}
+
+	if err := r.runHooks(func(hook hooks.IHook) error { return hook.AfterDelete(ctx, filter) }); err != nil {
+		return 0, err
 	}
-	return result.DeletedCount, nil
+	return deleted, nil
 }
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sequence allocates increasing numbers, such as order numbers, from a counter document of a
+// collection. Concurrent callers never get the same number, a number is not reused when the
+// document it was allocated for is not saved.
+type Sequence struct {
+	Collection *models.MongoCollection
+	Name       string
+}
+
+// NewSequence initializes the sequence stored under name in a collection
+func NewSequence(collection *models.MongoCollection, name string) *Sequence {
+	return &Sequence{Collection: collection, Name: name}
+}
+
+// Next allocates the next number of the sequence, the first number of a new sequence is 1
+func (s *Sequence) Next(ctx context.Context) (int64, error) {
+	var counter struct {
+		Value int64 `bson:"value"`
+	}
+	err := s.Collection.Collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": s.Name},
+		bson.M{"$inc": bson.M{"value": int64(1)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+	return counter.Value, nil
+}
+
+// AtLeast moves the sequence forward so that the next number is above value. It never moves the
+// sequence back, which makes it safe to seed a sequence from existing documents on every start.
+func (s *Sequence) AtLeast(ctx context.Context, value int64) error {
+	_, err := s.Collection.Collection.UpdateOne(ctx,
+		bson.M{"_id": s.Name},
+		bson.M{"$max": bson.M{"value": value}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+	return nil
+}
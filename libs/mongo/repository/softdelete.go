@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/aggregate"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/mappers"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DeletedAtField is set to the deletion time of soft deleted documents
+const DeletedAtField = "deletedAt"
+
+// scope maps a filter and, in soft delete mode, excludes the deleted documents from it
+func (r *Repository[T]) scope(filter interface{}) interface{} {
+	query := mappers.MapFilter(filter)
+	if !r.SoftDelete {
+		return query
+	}
+	return bson.D{{Key: "$and", Value: bson.A{query, bson.D{{Key: DeletedAtField, Value: nil}}}}}
+}
+
+// scopePipeline prepends, in soft delete mode, a stage excluding the deleted documents to stages
+func (r *Repository[T]) scopePipeline(stages mongo.Pipeline) mongo.Pipeline {
+	if !r.SoftDelete {
+		return stages
+	}
+	match := bson.D{{Key: "$match", Value: bson.D{{Key: DeletedAtField, Value: nil}}}}
+	return append(mongo.Pipeline{match}, stages...)
+}
+
+// deleteUpdate returns the $set of a soft delete. It runs the update hooks, so that a soft delete is
+// stamped like any other update and seen by readers polling the update time.
+func (r *Repository[T]) deleteUpdate(ctx context.Context, filter interface{}, now time.Time) (bson.M, error) {
+	_, set, err := r.beforeUpdate(ctx, filter, bson.M{DeletedAtField: now})
+	if err != nil {
+		return nil, err
+	}
+	return set.(bson.M), nil
+}
+
+// restoreUpdate returns the update document of a restore, running the update hooks like deleteUpdate
+func (r *Repository[T]) restoreUpdate(ctx context.Context, filter interface{}) (bson.M, error) {
+	_, update, err := r.beforeUpdate(ctx, filter, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	updateDoc := bson.M{"$unset": bson.M{DeletedAtField: ""}}
+	if set := update.(bson.M); len(set) > 0 {
+		updateDoc["$set"] = set
+	}
+	return updateDoc, nil
+}
+
+// upsertQuery returns the filter and update document of an upsert. Upserts match deleted documents
+// too and restore them, rather than inserting a live duplicate next to a deleted document.
+func (r *Repository[T]) upsertQuery(filter, data interface{}) (interface{}, bson.M) {
+	updateDoc := bson.M{"$set": data}
+	if r.SoftDelete {
+		updateDoc["$unset"] = bson.M{DeletedAtField: ""}
+	}
+	return mappers.MapFilter(filter), updateDoc
+}
+
+// AggregateWithDeleted runs pipeline like Aggregate, including the soft deleted documents
+func AggregateWithDeleted[R, T any](ctx context.Context, r *Repository[T], pipeline *aggregate.Pipeline) ([]R, error) {
+	return aggregateStages[R](ctx, r, pipeline.Stages())
+}
+
+// FindWithDeleted retrieves documents matching a filter, including soft deleted ones
+func (r *Repository[T]) FindWithDeleted(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*T, error) {
+	return r.find(ctx, filter, mappers.MapFilter, opts...)
+}
+
+// Restore undoes the soft delete of the documents matching the filter and returns how many were restored
+func (r *Repository[T]) Restore(ctx context.Context, filter interface{}) (int64, error) {
+	query := bson.D{{Key: "$and", Value: bson.A{
+		mappers.MapFilter(filter),
+		bson.D{{Key: DeletedAtField, Value: bson.D{{Key: "$ne", Value: nil}}}},
+	}}}
+
+	updateDoc, err := r.restoreUpdate(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.Collection.Collection.UpdateMany(ctx, query, updateDoc)
+	if err != nil {
+		return 0, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+
+	if err := r.afterUpdate(ctx, filter, updateDoc["$set"]); err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// Purge permanently removes the documents soft deleted more than retention ago and returns how many
+// were removed
+func (r *Repository[T]) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	filter := bson.D{{Key: DeletedAtField, Value: bson.D{{Key: "$lt", Value: time.Now().Add(-retention)}}}}
+
+	result, err := r.Collection.Collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, errors.Wrap(errors.ErrDeleteFailed, err)
+	}
+	return result.DeletedCount, nil
+}
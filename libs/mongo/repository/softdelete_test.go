@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/hooks"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestScope(t *testing.T) {
+	filter := map[string]interface{}{"name": "a"}
+
+	cases := []struct {
+		name       string
+		softDelete bool
+		expected   interface{}
+	}{
+		{"hard delete", false, bson.M{"name": "a"}},
+		{"soft delete", true, bson.D{{Key: "$and", Value: bson.A{bson.M{"name": "a"}, bson.D{{Key: DeletedAtField, Value: nil}}}}}},
+	}
+
+	for _, tc := range cases {
+		repo := &Repository[item]{SoftDelete: tc.softDelete}
+		if got := repo.scope(filter); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestUpsertQuery_RestoresDeleted(t *testing.T) {
+	data := map[string]interface{}{"name": "b"}
+
+	cases := []struct {
+		name       string
+		softDelete bool
+		expected   bson.M
+	}{
+		{"hard delete", false, bson.M{"$set": data}},
+		{"soft delete", true, bson.M{"$set": data, "$unset": bson.M{DeletedAtField: ""}}},
+	}
+
+	for _, tc := range cases {
+		repo := &Repository[item]{SoftDelete: tc.softDelete}
+		filter, update := repo.upsertQuery(map[string]interface{}{"name": "a"}, data)
+		// Deleted documents match the filter so that they are restored instead of duplicated
+		if !reflect.DeepEqual(filter, bson.M{"name": "a"}) {
+			t.Errorf("%s: expected an unscoped filter, got %v", tc.name, filter)
+		}
+		if !reflect.DeepEqual(update, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, update)
+		}
+	}
+}
+
+func TestWriteModels_SoftDelete(t *testing.T) {
+	now := time.Now()
+	filter := map[string]interface{}{"name": "a"}
+	bulk := NewBulkWrite[item]().
+		Update(filter, map[string]interface{}{"name": "b"}).
+		Delete(filter)
+
+	repo := &Repository[item]{SoftDelete: true}
+	writes := repo.writeModels(bulk, now)
+
+	expected := []mongo.WriteModel{
+		mongo.NewUpdateOneModel().
+			SetFilter(repo.scope(filter)).
+			SetUpdate(bson.M{"$set": map[string]interface{}{"name": "b"}}),
+		mongo.NewUpdateManyModel().
+			SetFilter(repo.scope(filter)).
+			SetUpdate(bson.M{"$set": bson.M{DeletedAtField: now}}),
+	}
+	if !reflect.DeepEqual(writes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, writes)
+	}
+}
+
+func TestScopePipeline(t *testing.T) {
+	group := bson.D{{Key: "$group", Value: bson.M{"_id": "$name"}}}
+
+	cases := []struct {
+		name       string
+		softDelete bool
+		expected   mongo.Pipeline
+	}{
+		{"hard delete", false, mongo.Pipeline{group}},
+		{"soft delete", true, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.D{{Key: DeletedAtField, Value: nil}}}},
+			group,
+		}},
+	}
+
+	for _, tc := range cases {
+		repo := &Repository[item]{SoftDelete: tc.softDelete}
+		if got := repo.scopePipeline(mongo.Pipeline{group}); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestSoftDeleteUpdates_RunUpdateHooks(t *testing.T) {
+	now := time.Now()
+	filter := map[string]interface{}{"name": "a"}
+	repo := &Repository[item]{SoftDelete: true}
+	repo.RegisterHooks(hooks.NewTimestampHook("updatedAt"))
+
+	set, err := repo.deleteUpdate(context.Background(), filter, now)
+	if err != nil {
+		t.Fatalf("delete: unexpected error %v", err)
+	}
+	if set[DeletedAtField] != now {
+		t.Errorf("delete: expected %s %v, got %v", DeletedAtField, now, set[DeletedAtField])
+	}
+	if _, ok := set["updatedAt"]; !ok {
+		t.Errorf("delete: expected updatedAt to be set, got %v", set)
+	}
+
+	updateDoc, err := repo.restoreUpdate(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("restore: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(updateDoc["$unset"], bson.M{DeletedAtField: ""}) {
+		t.Errorf("restore: expected %s to be unset, got %v", DeletedAtField, updateDoc)
+	}
+	if restored, _ := updateDoc["$set"].(bson.M); restored["updatedAt"] == nil {
+		t.Errorf("restore: expected updatedAt to be set, got %v", updateDoc)
+	}
+
+	// Without hooks a restore only unsets the deletion time
+	bare := &Repository[item]{SoftDelete: true}
+	updateDoc, err = bare.restoreUpdate(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("restore without hooks: unexpected error %v", err)
+	}
+	if expected := (bson.M{"$unset": bson.M{DeletedAtField: ""}}); !reflect.DeepEqual(updateDoc, expected) {
+		t.Errorf("restore without hooks: expected %v, got %v", expected, updateDoc)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenStore is a ResumeTokenStore keeping the token of every subscription in a collection,
+// under the name of the subscription
+type TokenStore struct {
+	Collection *models.MongoCollection
+}
+
+// NewTokenStore initializes a token store on a collection
+func NewTokenStore(collection *models.MongoCollection) *TokenStore {
+	return &TokenStore{Collection: collection}
+}
+
+// Load returns the token stored for a subscription, nil when there is none
+func (s *TokenStore) Load(ctx context.Context, name string) (bson.Raw, error) {
+	var stored struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := s.Collection.Collection.FindOne(ctx, bson.M{"_id": name}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stored.Token, nil
+}
+
+// Save stores the token of a subscription
+func (s *TokenStore) Save(ctx context.Context, name string, token bson.Raw) error {
+	_, err := s.Collection.Collection.UpdateOne(ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{"token": token, "updated_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// VersionField holds the version of documents updated with UpdateVersion. Documents without it are
+// at version zero.
+const VersionField = "version"
+
+// UpdateVersion sets the fields of update on the single document matching the filter if it is
+// still at the expected version, increments its version and returns it as updated. It returns
+// ErrVersionConflict when the document was changed since it was read at that version.
+func (r *Repository[T]) UpdateVersion(ctx context.Context, filter interface{}, version int64, update interface{}) (*T, error) {
+	filter, update, err := r.beforeUpdate(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := withoutVersion(update)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+
+	var expected interface{} = version
+	if version == 0 {
+		expected = bson.D{{Key: "$in", Value: bson.A{0, nil}}}
+	}
+	query := bson.D{{Key: "$and", Value: bson.A{
+		r.scope(filter),
+		bson.D{{Key: VersionField, Value: expected}},
+	}}}
+	updateDoc := bson.M{"$inc": bson.M{VersionField: 1}}
+	if len(fields) > 0 {
+		updateDoc["$set"] = fields
+	}
+
+	var result T
+	err = r.Collection.Collection.FindOneAndUpdate(ctx, query, updateDoc, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		// Tell a document changed by someone else from one that does not exist
+		count, err := r.Collection.Collection.CountDocuments(ctx, r.scope(filter), options.Count().SetLimit(1))
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrCountFailed, err)
+		}
+		if count > 0 {
+			return nil, errors.ErrVersionConflict
+		}
+		return nil, errors.ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrUpdateFailed, err)
+	}
+
+	if err := r.afterUpdate(ctx, filter, update); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// withoutVersion converts an update to a document without the version field, which is only ever
+// incremented
+func withoutVersion(update interface{}) (bson.M, error) {
+	raw, err := bson.Marshal(update)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields bson.M
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, VersionField)
+	return fields, nil
+}
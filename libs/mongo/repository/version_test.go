@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWithoutVersion(t *testing.T) {
+	type versioned struct {
+		Name    string `bson:"name"`
+		Version int64  `bson:"version"`
+	}
+
+	cases := []struct {
+		name     string
+		update   interface{}
+		expected bson.M
+	}{
+		{"struct", &versioned{Name: "a", Version: 3}, bson.M{"name": "a"}},
+		{"map", map[string]interface{}{"name": "a", VersionField: 3}, bson.M{"name": "a"}},
+		{"without version", bson.M{"name": "a"}, bson.M{"name": "a"}},
+	}
+
+	for _, tc := range cases {
+		fields, err := withoutVersion(tc.update)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(fields, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, fields)
+		}
+	}
+
+	if _, err := withoutVersion("name"); err == nil {
+		t.Error("expected an error for an update that is not a document")
+	}
+}
@@ -0,0 +1,464 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/mappers"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultPollInterval is how often changes are polled when WatchOptions does not set an interval
+const DefaultPollInterval = 5 * time.Second
+
+// watchRetryInterval is how long a failed change stream waits before it is opened again
+const watchRetryInterval = 5 * time.Second
+
+// Server error codes of change streams
+const (
+	codeInvalidResumeToken      = 260
+	codeChangeStreamFatal       = 280
+	codeChangeStreamHistoryLost = 286
+	codeChangeStreamUnsupported = 40573
+)
+
+// watchedOperations are the change stream events reported as a ChangeEvent
+var watchedOperations = bson.A{
+	string(models.OperationInsert),
+	string(models.OperationUpdate),
+	string(models.OperationReplace),
+	string(models.OperationDelete),
+}
+
+// watcher is a single subscription of Watch
+type watcher[T any] struct {
+	repo   *Repository[T]
+	filter interface{}
+	opts   models.WatchOptions
+	events chan models.ChangeEvent[T]
+}
+
+// Watch subscribes to the changes of the documents matching a filter until ctx is done, the
+// returned channel is closed then. Deletes carry no document and are reported whatever the filter,
+// in soft delete mode a soft delete is reported as a delete.
+//
+// Change streams need a replica set or a sharded cluster, on a standalone server the changes are
+// polled instead. Polling reports inserts and updates as OperationUpdate and only sees deletes when
+// no PollField is set.
+func (r *Repository[T]) Watch(ctx context.Context, filter interface{}, opts *models.WatchOptions) (<-chan models.ChangeEvent[T], error) {
+	w := &watcher[T]{repo: r, filter: filter, events: make(chan models.ChangeEvent[T])}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.PollInterval <= 0 {
+		w.opts.PollInterval = DefaultPollInterval
+	}
+
+	token, err := w.loadToken(ctx)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrWatchFailed, err)
+	}
+
+	stream, err := w.open(ctx, token)
+	if hasErrorCode(err, codeInvalidResumeToken, codeChangeStreamFatal, codeChangeStreamHistoryLost) {
+		// The stored position is no longer available, start from now
+		stream, err = w.open(ctx, nil)
+	}
+	switch {
+	case hasErrorCode(err, codeChangeStreamUnsupported):
+		go w.poll(ctx, token)
+	case err != nil:
+		return nil, errors.Wrap(errors.ErrWatchFailed, err)
+	default:
+		go w.stream(ctx, stream)
+	}
+	return w.events, nil
+}
+
+// open starts a change stream after token, or from now when token is not a change stream token
+func (w *watcher[T]) open(ctx context.Context, token bson.Raw) (*mongo.ChangeStream, error) {
+	match := bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: watchedOperations}}}}
+	if query := mappers.MapFilter(w.filter); !isEmptyFilter(query) {
+		match = bson.D{{Key: "$and", Value: bson.A{match, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "operationType", Value: string(models.OperationDelete)}},
+			prefixFilter(query, "fullDocument."),
+		}}}}}}
+	}
+
+	streamOptions := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if _, err := token.LookupErr("_data"); token != nil && err == nil {
+		streamOptions.SetResumeAfter(token)
+	}
+	return w.repo.Collection.Collection.Watch(ctx, mongo.Pipeline{{{Key: "$match", Value: match}}}, streamOptions)
+}
+
+// stream delivers the events of a change stream and opens it again after failures
+func (w *watcher[T]) stream(ctx context.Context, stream *mongo.ChangeStream) {
+	defer close(w.events)
+
+	// A token is saved once the next event is taken, so the event being handled when the process
+	// stops is delivered again after a restart
+	var pending bson.Raw
+	for {
+		for stream.Next(ctx) {
+			event, err := w.decode(ctx, stream.Current)
+			if err != nil {
+				event = models.ChangeEvent[T]{Err: err}
+			}
+			if !w.send(ctx, event) {
+				break
+			}
+
+			if pending != nil {
+				w.saveToken(ctx, pending)
+			}
+			pending = slices.Clone(stream.ResumeToken())
+		}
+
+		err := stream.Err()
+		token := stream.ResumeToken()
+		stream.Close(context.Background())
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.send(ctx, models.ChangeEvent[T]{Err: errors.Wrap(errors.ErrWatchFailed, err)})
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryInterval):
+			}
+
+			stream, err = w.open(ctx, token)
+			if hasErrorCode(err, codeInvalidResumeToken, codeChangeStreamFatal, codeChangeStreamHistoryLost) {
+				token = nil
+				stream, err = w.open(ctx, nil)
+			}
+			if err == nil {
+				break
+			}
+			if !w.send(ctx, models.ChangeEvent[T]{Err: errors.Wrap(errors.ErrWatchFailed, err)}) {
+				return
+			}
+		}
+	}
+}
+
+// decode converts a change stream event
+func (w *watcher[T]) decode(ctx context.Context, raw bson.Raw) (models.ChangeEvent[T], error) {
+	var change struct {
+		OperationType models.OperationType `bson:"operationType"`
+		DocumentKey   bson.Raw             `bson:"documentKey"`
+		FullDocument  bson.Raw             `bson:"fullDocument"`
+		ClusterTime   primitive.Timestamp  `bson:"clusterTime"`
+	}
+	if err := bson.Unmarshal(raw, &change); err != nil {
+		return models.ChangeEvent[T]{}, err
+	}
+
+	event := models.ChangeEvent[T]{
+		Operation: change.OperationType,
+		ID:        documentID(change.DocumentKey.Lookup("_id")),
+		At:        time.Unix(int64(change.ClusterTime.T), 0),
+	}
+	// The document may already be gone when an update is looked up
+	if change.OperationType != models.OperationDelete && len(change.FullDocument) > 0 {
+		if err := w.document(ctx, &event, change.FullDocument); err != nil {
+			return models.ChangeEvent[T]{}, err
+		}
+	}
+	return event, nil
+}
+
+// document sets the document of an event, reporting soft deleted documents as deletes
+func (w *watcher[T]) document(ctx context.Context, event *models.ChangeEvent[T], raw bson.Raw) error {
+	if w.repo.SoftDelete {
+		if deletedAt, err := raw.LookupErr(DeletedAtField); err == nil && deletedAt.Type != bson.TypeNull {
+			event.Operation = models.OperationDelete
+			return nil
+		}
+	}
+
+	var document T
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return err
+	}
+	if err := w.repo.afterFind(ctx, &document); err != nil {
+		return err
+	}
+	event.Document = &document
+	return nil
+}
+
+// poll delivers the changes found by polling the collection
+func (w *watcher[T]) poll(ctx context.Context, token bson.Raw) {
+	defer close(w.events)
+
+	since := time.Now()
+	if polledAt, ok := token.Lookup("polledAt").TimeOK(); ok {
+		since = polledAt
+	}
+	// Documents written at since that were already reported
+	reported := map[string]bool{}
+	// The documents of the previous poll when no PollField is set
+	var previous map[string]bson.Raw
+	saved := since
+
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		var err error
+		if w.opts.PollField != "" {
+			// Every event of the previous poll was taken, its position is safe to resume from
+			if !since.Equal(saved) {
+				if token, err := bson.Marshal(bson.M{"polledAt": since}); err == nil {
+					w.saveToken(ctx, token)
+				}
+				saved = since
+			}
+			since, err = w.pollSince(ctx, since, reported)
+		} else {
+			previous, err = w.pollSnapshot(ctx, previous)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.send(ctx, models.ChangeEvent[T]{Err: errors.Wrap(errors.ErrWatchFailed, err)})
+		}
+	}
+}
+
+// pollSince reports the documents whose PollField is at or after since, and returns the latest
+// PollField seen
+func (w *watcher[T]) pollSince(ctx context.Context, since time.Time, reported map[string]bool) (time.Time, error) {
+	query := bson.D{{Key: "$and", Value: bson.A{
+		mappers.MapFilter(w.filter),
+		bson.D{{Key: w.opts.PollField, Value: bson.D{{Key: "$gte", Value: since}}}},
+	}}}
+	findOptions := options.Find().SetSort(bson.D{{Key: w.opts.PollField, Value: 1}, {Key: "_id", Value: 1}})
+
+	documents, err := w.find(ctx, query, findOptions)
+	if err != nil {
+		return since, err
+	}
+
+	for _, raw := range documents {
+		at, ok := raw.Lookup(strings.Split(w.opts.PollField, ".")...).TimeOK()
+		if !ok {
+			continue
+		}
+		id := documentID(raw.Lookup("_id"))
+		if at.Equal(since) && reported[id] {
+			continue
+		}
+		if at.After(since) {
+			since = at
+			clear(reported)
+		}
+		reported[id] = true
+
+		event := models.ChangeEvent[T]{Operation: models.OperationUpdate, ID: id, At: at}
+		if err := w.document(ctx, &event, raw); err != nil {
+			event = models.ChangeEvent[T]{Err: err}
+		}
+		if !w.send(ctx, event) {
+			break
+		}
+	}
+	return since, nil
+}
+
+// pollSnapshot compares the documents with those of the previous poll and reports the differences,
+// the first poll only takes the snapshot
+func (w *watcher[T]) pollSnapshot(ctx context.Context, previous map[string]bson.Raw) (map[string]bson.Raw, error) {
+	documents, err := w.find(ctx, mappers.MapFilter(w.filter), options.Find())
+	if err != nil {
+		return previous, err
+	}
+
+	current := make(map[string]bson.Raw, len(documents))
+	for _, raw := range documents {
+		current[documentID(raw.Lookup("_id"))] = raw
+	}
+	if previous == nil {
+		return current, nil
+	}
+
+	now := time.Now()
+	for id, raw := range current {
+		event := models.ChangeEvent[T]{Operation: models.OperationInsert, ID: id, At: now}
+		if old, exists := previous[id]; exists {
+			if bytes.Equal(old, raw) {
+				continue
+			}
+			event.Operation = models.OperationUpdate
+		}
+		if err := w.document(ctx, &event, raw); err != nil {
+			event = models.ChangeEvent[T]{Err: err}
+		}
+		if !w.send(ctx, event) {
+			return current, nil
+		}
+	}
+	for id := range previous {
+		if _, exists := current[id]; !exists {
+			if !w.send(ctx, models.ChangeEvent[T]{Operation: models.OperationDelete, ID: id, At: now}) {
+				return current, nil
+			}
+		}
+	}
+	return current, nil
+}
+
+// find reads the raw documents matching query
+func (w *watcher[T]) find(ctx context.Context, query interface{}, findOptions *options.FindOptions) ([]bson.Raw, error) {
+	cursor, err := w.repo.Collection.Collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var documents []bson.Raw
+	for cursor.Next(ctx) {
+		documents = append(documents, slices.Clone(cursor.Current))
+	}
+	return documents, cursor.Err()
+}
+
+// send delivers an event unless ctx is done first
+func (w *watcher[T]) send(ctx context.Context, event models.ChangeEvent[T]) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (w *watcher[T]) loadToken(ctx context.Context) (bson.Raw, error) {
+	if w.opts.TokenStore == nil || w.opts.Name == "" {
+		return nil, nil
+	}
+	return w.opts.TokenStore.Load(ctx, w.opts.Name)
+}
+
+// saveToken stores the position of the subscription, a failure is reported as an event
+func (w *watcher[T]) saveToken(ctx context.Context, token bson.Raw) {
+	if w.opts.TokenStore == nil || w.opts.Name == "" {
+		return
+	}
+	if err := w.opts.TokenStore.Save(ctx, w.opts.Name, token); err != nil && ctx.Err() == nil {
+		w.send(ctx, models.ChangeEvent[T]{Err: err})
+	}
+}
+
+// documentID formats an _id, ObjectIDs as hex strings
+func documentID(value bson.RawValue) string {
+	if objID, ok := value.ObjectIDOK(); ok {
+		return objID.Hex()
+	}
+	if id, ok := value.StringValueOK(); ok {
+		return id
+	}
+	return value.String()
+}
+
+// hasErrorCode reports whether err is a server error with one of the given codes
+func hasErrorCode(err error, codes ...int) bool {
+	var serverErr mongo.ServerError
+	if !stderrors.As(err, &serverErr) {
+		return false
+	}
+	for _, code := range codes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyFilter(filter interface{}) bool {
+	switch f := filter.(type) {
+	case bson.M:
+		return len(f) == 0
+	case bson.D:
+		return len(f) == 0
+	case map[string]interface{}:
+		return len(f) == 0
+	}
+	return false
+}
+
+// prefixFilter returns a copy of filter with every field prefixed, going into $and, $or and $nor
+func prefixFilter(filter interface{}, prefix string) interface{} {
+	switch f := filter.(type) {
+	case bson.M:
+		prefixed := bson.D{}
+		for key, value := range f {
+			prefixed = append(prefixed, prefixElem(key, value, prefix))
+		}
+		return prefixed
+	case map[string]interface{}:
+		return prefixFilter(bson.M(f), prefix)
+	case bson.D:
+		prefixed := make(bson.D, 0, len(f))
+		for _, elem := range f {
+			prefixed = append(prefixed, prefixElem(elem.Key, elem.Value, prefix))
+		}
+		return prefixed
+	}
+	return filter
+}
+
+func prefixElem(key string, value interface{}, prefix string) bson.E {
+	if !strings.HasPrefix(key, "$") {
+		return bson.E{Key: prefix + key, Value: value}
+	}
+
+	var filters []interface{}
+	switch list := value.(type) {
+	case bson.A:
+		filters = list
+	case []interface{}:
+		filters = list
+	case []bson.D:
+		for _, filter := range list {
+			filters = append(filters, filter)
+		}
+	case []bson.M:
+		for _, filter := range list {
+			filters = append(filters, filter)
+		}
+	}
+	if filters == nil || (key != "$and" && key != "$or" && key != "$nor") {
+		return bson.E{Key: key, Value: value}
+	}
+
+	prefixed := make(bson.A, len(filters))
+	for i, filter := range filters {
+		prefixed[i] = prefixFilter(filter, prefix)
+	}
+	return bson.E{Key: key, Value: prefixed}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPrefixFilter(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   interface{}
+		expected interface{}
+	}{
+		{"map", map[string]interface{}{"name": "a"}, bson.D{{Key: "fullDocument.name", Value: "a"}}},
+		{"document", bson.D{{Key: "name", Value: "a"}, {Key: "price", Value: bson.D{{Key: "$gt", Value: 1}}}}, bson.D{
+			{Key: "fullDocument.name", Value: "a"},
+			{Key: "fullDocument.price", Value: bson.D{{Key: "$gt", Value: 1}}},
+		}},
+		{"logical operator", bson.D{{Key: "$or", Value: bson.A{bson.D{{Key: "a", Value: 1}}, bson.M{"b": 2}}}}, bson.D{
+			{Key: "$or", Value: bson.A{bson.D{{Key: "fullDocument.a", Value: 1}}, bson.D{{Key: "fullDocument.b", Value: 2}}}},
+		}},
+		{"other operator", bson.D{{Key: "$expr", Value: bson.A{"a"}}}, bson.D{{Key: "$expr", Value: bson.A{"a"}}}},
+	}
+
+	for _, tc := range cases {
+		if got := prefixFilter(tc.filter, "fullDocument."); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestDecodeChange(t *testing.T) {
+	id := primitive.NewObjectID()
+	at := time.Unix(1700000000, 0)
+
+	change := func(operation models.OperationType, document bson.D) bson.Raw {
+		raw, err := bson.Marshal(bson.D{
+			{Key: "operationType", Value: operation},
+			{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+			{Key: "fullDocument", Value: document},
+			{Key: "clusterTime", Value: primitive.Timestamp{T: uint32(at.Unix())}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return raw
+	}
+	live := bson.D{{Key: "_id", Value: id.Hex()}, {Key: "name", Value: "a"}}
+	deleted := append(bson.D{{Key: DeletedAtField, Value: at}}, live...)
+
+	cases := []struct {
+		name         string
+		raw          bson.Raw
+		expectedOp   models.OperationType
+		withDocument bool
+	}{
+		{"insert", change(models.OperationInsert, live), models.OperationInsert, true},
+		{"update", change(models.OperationUpdate, live), models.OperationUpdate, true},
+		{"delete", change(models.OperationDelete, nil), models.OperationDelete, false},
+		{"soft delete", change(models.OperationUpdate, deleted), models.OperationDelete, false},
+	}
+
+	w := &watcher[item]{repo: &Repository[item]{SoftDelete: true}}
+	for _, tc := range cases {
+		event, err := w.decode(context.Background(), tc.raw)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if event.Operation != tc.expectedOp || event.ID != id.Hex() || !event.At.Equal(at) {
+			t.Errorf("%s: unexpected event %+v", tc.name, event)
+		}
+		if (event.Document != nil) != tc.withDocument {
+			t.Errorf("%s: expected a document: %t, got %+v", tc.name, tc.withDocument, event.Document)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// WithTransaction runs fn in a multi-document transaction and commits it when fn returns nil, or
+// aborts it otherwise. Repository methods called with the context passed to fn take part in the
+// transaction. fn and the commit are retried on transient transaction errors, so fn must be safe
+// to run again.
+//
+// Standalone servers do not support transactions, fn then runs without one.
+func (m *MongoClient) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.supportsTransactions(ctx) {
+		return fn(ctx)
+	}
+
+	session, err := m.Client.StartSession()
+	if err != nil {
+		m.Logger.Error("Failed to start MongoDB session", "error", err)
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// supportsTransactions reports whether the server is a replica set member or a mongos, the answer
+// is cached after the first check
+func (m *MongoClient) supportsTransactions(ctx context.Context) bool {
+	m.transactionsOnce.Do(func() {
+		var hello struct {
+			SetName string `bson:"setName"`
+			Msg     string `bson:"msg"`
+		}
+		if err := m.Client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+			m.Logger.Error("Failed to check MongoDB topology, assuming transactions are supported", "error", err)
+			m.transactions = true
+			return
+		}
+
+		m.transactions = hello.SetName != "" || hello.Msg == "isdbgrid"
+		if !m.transactions {
+			m.Logger.Warn("MongoDB is a standalone server, transactions run without atomicity")
+		}
+	})
+	return m.transactions
+}
@@ -0,0 +1,35 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithTransaction_Standalone(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	cases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"fn succeeds", nil, nil},
+		{"fn fails", errFailed, errFailed},
+	}
+
+	for _, tc := range cases {
+		// A standalone server runs fn without a session
+		client := &MongoClient{}
+		client.transactionsOnce.Do(func() {})
+
+		calls := 0
+		err := client.WithTransaction(context.Background(), func(ctx context.Context) error {
+			calls++
+			return tc.err
+		})
+		if !errors.Is(err, tc.expected) || calls != 1 {
+			t.Errorf("%s: expected error %v after 1 call, got %v after %d", tc.name, tc.expected, err, calls)
+		}
+	}
+}
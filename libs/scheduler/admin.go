@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+)
+
+// registeredByName returns the job registered under name in this process
+func (s *Scheduler) registeredByName(name string) (*registeredJob, error) {
+	registered, exists := s.jobs[name]
+	if !exists {
+		return nil, &SchedulerError{Code: ErrCodeJobNotFound, Message: "job " + name + " is not registered"}
+	}
+	return registered, nil
+}
+
+// GetJob returns the persisted job with the given name
+func (s *Scheduler) GetJob(ctx context.Context, name string) (*Job, error) {
+	job, err := s.jobRepo.FindOne(ctx, map[string]interface{}{"name": name}, nil)
+	if err != nil {
+		if err == mongo_errors.ErrDocumentNotFound {
+			return nil, &SchedulerError{Code: ErrCodeJobNotFound, Message: "job " + name + " not found", Err: err}
+		}
+		return nil, err
+	}
+
+	s.fillNextRunAt(job)
+	return job, nil
+}
+
+// lookupRegistered returns the ID and configuration of the job registered under name, so that
+// callers do not hold the lock across database calls
+func (s *Scheduler) lookupRegistered(name string) (string, JobConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	registered, err := s.registeredByName(name)
+	if err != nil {
+		return "", JobConfig{}, err
+	}
+	return registered.id, registered.config, nil
+}
+
+// PauseJob stops triggering a job until it is resumed, running executions are not interrupted.
+// The paused state is persisted and survives restarts.
+func (s *Scheduler) PauseJob(ctx context.Context, name string) error {
+	id, _, err := s.lookupRegistered(name)
+	if err != nil {
+		return err
+	}
+
+	// The lock is only held to swap the cron entry, not across the database write
+	_, err = s.jobRepo.Update(ctx, map[string]interface{}{"_id": id}, map[string]interface{}{
+		"paused":      true,
+		"next_run_at": time.Time{},
+		"updated_at":  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	registered, err := s.registeredByName(name)
+	if err != nil {
+		return err
+	}
+	s.cron.Remove(registered.entryID)
+	registered.entryID = 0
+	s.logger.Info("Paused job", "job", name)
+	return nil
+}
+
+// ResumeJob starts triggering a paused job again
+func (s *Scheduler) ResumeJob(ctx context.Context, name string) error {
+	id, _, err := s.lookupRegistered(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.jobRepo.Update(ctx, map[string]interface{}{"_id": id}, map[string]interface{}{
+		"paused":     false,
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	registered, err := s.registeredByName(name)
+	if err != nil {
+		return err
+	}
+	if registered.entryID == 0 {
+		entryID, err := s.addCronEntry(name, registered.config)
+		if err != nil {
+			return err
+		}
+		registered.entryID = entryID
+	}
+
+	s.logger.Info("Resumed job", "job", name)
+	return nil
+}
+
+// TriggerJob runs a job immediately in the background, regardless of its schedule or paused state.
+// The job's concurrency policy still applies.
+func (s *Scheduler) TriggerJob(name string) error {
+	s.mu.RLock()
+	_, err := s.registeredByName(name)
+	stopping := s.stopping
+	s.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+	if stopping {
+		return &SchedulerError{Code: ErrCodeShutdown, Message: "scheduler is shutting down"}
+	}
+
+	s.logger.Info("Triggering job on demand", "job", name)
+	go s.dispatch(name)
+	return nil
+}
+
+// RescheduleJob changes the cron expression and timezone of a job at runtime and persists them.
+// Jobs registered by code get the expression from code back on their next registration.
+func (s *Scheduler) RescheduleJob(ctx context.Context, name, cronExpr, timezone string) error {
+	if _, err := ParseSchedule(cronExpr, timezone); err != nil {
+		return err
+	}
+
+	id, current, err := s.lookupRegistered(name)
+	if err != nil {
+		return err
+	}
+	if !current.RunAt.IsZero() {
+		return &SchedulerError{Code: ErrCodeInvalidConfig, Message: "job " + name + " runs once and has no cron expression"}
+	}
+
+	_, err = s.jobRepo.Update(ctx, map[string]interface{}{"_id": id}, map[string]interface{}{
+		"cron_expr":  cronExpr,
+		"timezone":   timezone,
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	registered, err := s.registeredByName(name)
+	if err != nil {
+		return err
+	}
+
+	config := registered.config
+	config.CronExpr = cronExpr
+	config.Timezone = timezone
+
+	// Paused jobs only pick up the new expression once resumed
+	if registered.entryID != 0 {
+		entryID, err := s.addCronEntry(name, config)
+		if err != nil {
+			return err
+		}
+		s.cron.Remove(registered.entryID)
+		registered.entryID = entryID
+	}
+
+	s.logger.Info("Rescheduled job", "job", name, "from", registered.config.CronExpr, "to", cronExpr, "timezone", timezone)
+	registered.config = config
+	return nil
+}
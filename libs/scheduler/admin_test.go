@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func scheduleTestJob(t *testing.T, s *Scheduler, config JobConfig) {
+	t.Helper()
+	if err := s.Schedule(context.Background(), config, func(ctx context.Context, params map[string]interface{}) error { return nil }); err != nil {
+		t.Fatalf("unexpected error scheduling %s: %v", config.Name, err)
+	}
+}
+
+func TestPauseResumeJob(t *testing.T) {
+	ctx := context.Background()
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+	scheduleTestJob(t, s, JobConfig{Name: "report", CronExpr: "0 0 * * * *"})
+
+	// Pausing is idempotent, a paused job stays paused
+	for i := 0; i < 2; i++ {
+		if err := s.PauseJob(ctx, "report"); err != nil {
+			t.Fatalf("pause %d: unexpected error: %v", i, err)
+		}
+		if s.jobs["report"].entryID != 0 || !repo.get("report").Paused {
+			t.Errorf("pause %d: expected the job to be paused", i)
+		}
+	}
+
+	if err := s.ResumeJob(ctx, "report"); err != nil {
+		t.Fatalf("resume: unexpected error: %v", err)
+	}
+	if s.jobs["report"].entryID == 0 || repo.get("report").Paused {
+		t.Error("resume: expected the job to be triggered again")
+	}
+}
+
+func TestPauseJob_WriteFails(t *testing.T) {
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+	scheduleTestJob(t, s, JobConfig{Name: "report", CronExpr: "0 0 * * * *"})
+
+	repo.failing = errors.New("write failed")
+	if err := s.PauseJob(context.Background(), "report"); err == nil {
+		t.Fatal("expected the write error")
+	}
+	if s.jobs["report"].entryID == 0 {
+		t.Error("expected the job to keep triggering when the pause was not persisted")
+	}
+}
+
+func TestAdmin_UnknownJob(t *testing.T) {
+	ctx := context.Background()
+	s := newTestScheduler(&memJobRepo{})
+
+	cases := []struct {
+		name string
+		call func() error
+	}{
+		{"pause", func() error { return s.PauseJob(ctx, "missing") }},
+		{"resume", func() error { return s.ResumeJob(ctx, "missing") }},
+		{"reschedule", func() error { return s.RescheduleJob(ctx, "missing", "0 0 * * * *", "") }},
+		{"trigger", func() error { return s.TriggerJob("missing") }},
+	}
+
+	for _, tc := range cases {
+		var schedulerErr *SchedulerError
+		if err := tc.call(); !errors.As(err, &schedulerErr) || schedulerErr.Code != ErrCodeJobNotFound {
+			t.Errorf("%s: expected a job not found error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestRescheduleJob(t *testing.T) {
+	ctx := context.Background()
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+	scheduleTestJob(t, s, JobConfig{Name: "report", CronExpr: "0 0 * * * *"})
+
+	cases := []struct {
+		name     string
+		cronExpr string
+		timezone string
+		valid    bool
+	}{
+		{"invalid cron expression", "not a cron", "", false},
+		{"invalid timezone", "0 30 * * * *", "Mars/Olympus", false},
+		{"valid", "0 30 * * * *", "Europe/Paris", true},
+	}
+
+	for _, tc := range cases {
+		err := s.RescheduleJob(ctx, "report", tc.cronExpr, tc.timezone)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+
+		stored := repo.get("report")
+		expected := "0 0 * * * *"
+		if tc.valid {
+			expected = tc.cronExpr
+		}
+		if stored.CronExpr != expected || s.jobs["report"].config.CronExpr != expected {
+			t.Errorf("%s: expected cron expression %q, got %q stored and %q registered", tc.name, expected, stored.CronExpr, s.jobs["report"].config.CronExpr)
+		}
+	}
+
+	scheduleTestJob(t, s, JobConfig{Name: "once", RunAt: repo.get("report").CreatedAt.AddDate(1, 0, 0)})
+	if err := s.RescheduleJob(ctx, "once", "0 30 * * * *", ""); err == nil {
+		t.Error("once: expected a job running once not to be rescheduled")
+	}
+}
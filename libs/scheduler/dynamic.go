@@ -0,0 +1,270 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+)
+
+// DefaultSyncInterval is how often database-defined jobs are polled when change streams are not
+// supported and no interval is configured
+const DefaultSyncInterval = 30 * time.Second
+
+// DefineJob stores a database-defined job that runs the handler named by config.Handler, unless a
+// job with the same name already exists. The stored definition wins afterwards, so cadence changes
+// made in the database survive deploys.
+func (s *Scheduler) DefineJob(ctx context.Context, config JobConfig) error {
+	if config.Name == "" || config.Handler == "" {
+		return &SchedulerError{Code: ErrCodeInvalidConfig, Message: "job name and handler are required"}
+	}
+	if _, err := scheduleOf(config); err != nil {
+		return err
+	}
+
+	s.ensureIndexes(ctx)
+
+	existing, err := s.jobRepo.FindOne(ctx, map[string]interface{}{"name": config.Name}, nil)
+	if err == mongo_errors.ErrDocumentNotFound {
+		if _, err := s.jobRepo.Create(ctx, newJob(config)); err == nil {
+			s.logger.Info("Defined new job", "job", config.Name, "handler", config.Handler)
+			return s.syncIfStarted(ctx)
+		}
+
+		// Another instance may have defined the same name concurrently
+		existing, err = s.jobRepo.FindOne(ctx, map[string]interface{}{"name": config.Name}, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Adopt a job previously registered by code
+	if existing.Handler == "" {
+		_, err = s.jobRepo.Update(ctx, map[string]interface{}{"_id": existing.ID}, map[string]interface{}{
+			"handler":    config.Handler,
+			"updated_at": time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		s.logger.Info("Job is now defined in the database", "job", config.Name, "handler", config.Handler)
+	}
+	return nil
+}
+
+// SyncDefinitions reconciles the jobs defined in the database with the cron entries of this process:
+// new definitions are scheduled, removed ones are unscheduled and changed cron expressions or paused
+// states are applied. Jobs registered through Schedule are left untouched.
+func (s *Scheduler) SyncDefinitions(ctx context.Context) error {
+	jobs, err := s.jobRepo.Find(ctx, definitionFilter(), nil)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return nil
+	}
+
+	defined := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		defined[job.Name] = true
+
+		registered, exists := s.jobs[job.Name]
+		if exists && !registered.dynamic {
+			continue
+		}
+
+		jobFunc, ok := LookupHandler(job.Handler)
+		if !ok {
+			if !s.missingHandlers[job.Handler] {
+				s.missingHandlers[job.Handler] = true
+				s.logger.Warn("No handler registered for database-defined job", "job", job.Name, "handler", job.Handler)
+			}
+			if exists {
+				s.unregister(job.Name)
+			}
+			continue
+		}
+
+		config := configFromJob(job)
+		if !exists {
+			s.registerDefinition(job, config, jobFunc)
+			continue
+		}
+
+		retimed := registered.config.CronExpr != config.CronExpr ||
+			registered.config.Timezone != config.Timezone ||
+			!registered.config.RunAt.Equal(config.RunAt)
+		registered.config = config
+		registered.jobFunc = jobFunc
+
+		switch {
+		case job.Paused && registered.entryID != 0:
+			s.cron.Remove(registered.entryID)
+			registered.entryID = 0
+			s.logger.Info("Database-defined job was paused", "job", job.Name)
+		case !job.Paused && (registered.entryID == 0 || retimed):
+			entryID, err := s.addCronEntry(job.Name, config)
+			if err != nil {
+				s.logger.Error("Failed to reschedule database-defined job", "job", job.Name, "error", err)
+				continue
+			}
+			s.cron.Remove(registered.entryID)
+			registered.entryID = entryID
+			s.logger.Info("Database-defined job was rescheduled", "job", job.Name, "cronExpr", config.CronExpr, "timezone", config.Timezone)
+		}
+	}
+
+	for name, registered := range s.jobs {
+		if registered.dynamic && !defined[name] {
+			s.unregister(name)
+			s.logger.Info("Database-defined job was removed", "job", name)
+		}
+	}
+	return nil
+}
+
+// syncIfStarted picks up new definitions right away instead of on the next sync
+func (s *Scheduler) syncIfStarted(ctx context.Context) error {
+	s.mu.RLock()
+	started := s.started
+	s.mu.RUnlock()
+
+	if !started {
+		return nil
+	}
+	return s.SyncDefinitions(ctx)
+}
+
+// registerDefinition schedules a database-defined job, the caller must hold s.mu
+func (s *Scheduler) registerDefinition(job *Job, config JobConfig, jobFunc JobFunc) {
+	registered := &registeredJob{
+		concurrency: s.concurrencyOf(job.Name),
+		id:          job.ID,
+		config:      config,
+		jobFunc:     jobFunc,
+		dynamic:     true,
+	}
+
+	if !job.Paused {
+		entryID, err := s.addCronEntry(job.Name, config)
+		if err != nil {
+			s.logger.Error("Failed to schedule database-defined job", "job", job.Name, "error", err)
+			return
+		}
+		registered.entryID = entryID
+		registered.misfires = s.misfiresFor(job, config)
+	}
+
+	s.jobs[job.Name] = registered
+	s.logger.Info("Scheduled database-defined job", "job", job.Name, "handler", job.Handler, "cronExpr", config.CronExpr)
+
+	if s.started && registered.misfires > 0 {
+		go s.catchUp(job.Name, registered.misfires)
+		registered.misfires = 0
+	}
+}
+
+// unregister removes the cron entry and registration of a job, the caller must hold s.mu
+func (s *Scheduler) unregister(name string) {
+	if registered, exists := s.jobs[name]; exists {
+		s.cron.Remove(registered.entryID)
+		delete(s.jobs, name)
+	}
+}
+
+// watchDefinitions reloads database-defined jobs whenever a definition changes, until the scheduler
+// shuts down. Where change streams are not supported the definitions are polled every syncInterval.
+func (s *Scheduler) watchDefinitions() {
+	events, err := s.jobRepo.Watch(s.baseCtx, definitionFilter(), &models.WatchOptions{PollInterval: s.syncInterval})
+	if err != nil {
+		s.logger.Error("Failed to watch job definitions, polling them instead", "error", err)
+		s.pollDefinitions()
+		return
+	}
+
+	for event := range events {
+		if event.Err != nil {
+			s.logger.Warn("Error watching job definitions", "error", event.Err)
+			continue
+		}
+
+		// Runs update their job too, the changes received meanwhile are picked up by a single sync
+	drain:
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+			default:
+				break drain
+			}
+		}
+
+		if s.isStopping() {
+			return
+		}
+		if err := s.SyncDefinitions(s.baseCtx); err != nil {
+			s.logger.Error("Failed to sync job definitions", "error", err)
+		}
+	}
+}
+
+// pollDefinitions reloads database-defined jobs every syncInterval until the scheduler shuts down
+func (s *Scheduler) pollDefinitions() {
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.baseCtx.Done():
+			return
+		case <-ticker.C:
+			if s.isStopping() {
+				return
+			}
+			if err := s.SyncDefinitions(s.baseCtx); err != nil {
+				s.logger.Error("Failed to sync job definitions", "error", err)
+			}
+		}
+	}
+}
+
+// isStopping reports whether Shutdown was called
+func (s *Scheduler) isStopping() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stopping
+}
+
+// definitionFilter matches the jobs defined in the database
+func definitionFilter() map[string]interface{} {
+	return map[string]interface{}{
+		"handler": map[string]interface{}{"$exists": true, "$ne": ""},
+	}
+}
+
+// configFromJob rebuilds the configuration of a job from its stored document
+func configFromJob(job *Job) JobConfig {
+	retryPolicy := job.RetryPolicy
+	return JobConfig{
+		Name:                job.Name,
+		Handler:             job.Handler,
+		CronExpr:            job.CronExpr,
+		Timezone:            job.Timezone,
+		RunAt:               job.RunAt,
+		Params:              job.Params,
+		MaxRetries:          job.MaxRetries,
+		RetryPolicy:         &retryPolicy,
+		Timeout:             job.Timeout,
+		IsRecurring:         job.IsRecurring,
+		ConcurrencyPolicy:   job.ConcurrencyPolicy,
+		MisfirePolicy:       job.MisfirePolicy,
+		MisfireCatchUpLimit: job.MisfireCatchUpLimit,
+	}
+}
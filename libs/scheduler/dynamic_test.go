@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/robfig/cron/v3"
+)
+
+func noopJob(ctx context.Context, params map[string]interface{}) error { return nil }
+
+// definedEntry returns the cron entry of a database-defined job and whether it is registered
+func (s *Scheduler) definedEntry(name string) (cron.EntryID, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	registered, exists := s.jobs[name]
+	if !exists || !registered.dynamic {
+		return 0, false
+	}
+	return registered.entryID, true
+}
+
+func TestDefineJob(t *testing.T) {
+	ctx := context.Background()
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+
+	invalid := []JobConfig{
+		{Handler: "define-test"},
+		{Name: "report", CronExpr: "0 0 * * * *"},
+		{Name: "report", Handler: "define-test", CronExpr: "not a cron"},
+	}
+	for _, config := range invalid {
+		if err := s.DefineJob(ctx, config); !HasErrorCode(err, ErrCodeInvalidConfig) {
+			t.Errorf("%+v: expected %s, got %v", config, ErrCodeInvalidConfig, err)
+		}
+	}
+
+	if err := s.DefineJob(ctx, JobConfig{Name: "report", Handler: "define-test", CronExpr: "0 0 * * * *"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The stored definition wins over the one of a later deploy
+	if err := s.DefineJob(ctx, JobConfig{Name: "report", Handler: "other", CronExpr: "0 30 * * * *"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job := repo.get("report"); job.Handler != "define-test" || job.CronExpr != "0 0 * * * *" {
+		t.Errorf("expected the first definition to be kept, got handler %q and cron %q", job.Handler, job.CronExpr)
+	}
+
+	// A job registered by code is adopted
+	scheduleTestJob(t, s, JobConfig{Name: "cleanup", CronExpr: "0 0 * * * *"})
+	if err := s.DefineJob(ctx, JobConfig{Name: "cleanup", Handler: "define-test", CronExpr: "0 0 * * * *"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job := repo.get("cleanup"); job.Handler != "define-test" {
+		t.Errorf("expected the registered job to be adopted, got handler %q", job.Handler)
+	}
+}
+
+func TestSyncDefinitions(t *testing.T) {
+	ctx := context.Background()
+	RegisterHandler("sync-test", noopJob)
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+
+	scheduleTestJob(t, s, JobConfig{Name: "cleanup", CronExpr: "0 0 * * * *"})
+	baseEntries := len(s.cron.Entries())
+	for _, config := range []JobConfig{
+		{Name: "report", Handler: "sync-test", CronExpr: "0 0 * * * *"},
+		{Name: "orphan", Handler: "sync-missing", CronExpr: "0 0 * * * *"},
+	} {
+		if err := s.DefineJob(ctx, config); err != nil {
+			t.Fatalf("%s: unexpected error: %v", config.Name, err)
+		}
+	}
+
+	if err := s.SyncDefinitions(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entryID, defined := s.definedEntry("report")
+	if !defined || entryID == 0 {
+		t.Fatalf("expected the definition to be scheduled")
+	}
+	if _, defined := s.definedEntry("orphan"); defined {
+		t.Errorf("expected a definition without a handler not to be scheduled")
+	}
+
+	report := repo.get("report")
+	steps := []struct {
+		name      string
+		update    map[string]interface{}
+		scheduled bool
+	}{
+		{"rescheduled", map[string]interface{}{"cron_expr": "0 30 * * * *"}, true},
+		{"paused", map[string]interface{}{"paused": true}, false},
+		{"resumed", map[string]interface{}{"paused": false}, true},
+	}
+	for _, step := range steps {
+		if _, err := repo.Update(ctx, map[string]interface{}{"_id": report.ID}, step.update); err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if err := s.SyncDefinitions(ctx); err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+
+		previous := entryID
+		entryID, defined = s.definedEntry("report")
+		if !defined || (entryID != 0) != step.scheduled || (step.scheduled && entryID == previous) {
+			t.Errorf("%s: expected scheduled %t on a new entry, got entry %d after %d", step.name, step.scheduled, entryID, previous)
+		}
+	}
+	if cronExpr := s.jobs["report"].config.CronExpr; cronExpr != "0 30 * * * *" {
+		t.Errorf("expected the cron expression to be applied, got %q", cronExpr)
+	}
+
+	// Removed definitions are unscheduled, jobs registered by code are left alone
+	repo.remove("report")
+	if err := s.SyncDefinitions(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, exists := s.jobs["report"]; exists {
+		t.Errorf("expected the removed definition to be unscheduled")
+	}
+	if entries := len(s.cron.Entries()); entries != baseEntries {
+		t.Errorf("expected the cron entries of the definition to be removed, got %d entries instead of %d", entries, baseEntries)
+	}
+	if _, exists := s.jobs["cleanup"]; !exists {
+		t.Errorf("expected the registered job to be kept")
+	}
+}
+
+func TestWatchDefinitions_SyncsOnChange(t *testing.T) {
+	ctx := context.Background()
+	RegisterHandler("watch-test", noopJob)
+	repo := &memJobRepo{events: make(chan models.ChangeEvent[Job])}
+	s := newTestScheduler(repo)
+
+	done := make(chan struct{})
+	go func() {
+		s.watchDefinitions()
+		close(done)
+	}()
+
+	if err := s.DefineJob(ctx, JobConfig{Name: "report", Handler: "watch-test", CronExpr: "0 0 * * * *"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo.events <- models.ChangeEvent[Job]{Err: errors.New("stream interrupted")}
+	repo.events <- models.ChangeEvent[Job]{Operation: models.OperationInsert, ID: repo.get("report").ID}
+	waitFor(t, "the definition to be scheduled", func() bool {
+		_, defined := s.definedEntry("report")
+		return defined
+	})
+
+	repo.remove("report")
+	repo.events <- models.ChangeEvent[Job]{Operation: models.OperationDelete, ID: "1"}
+	waitFor(t, "the definition to be removed", func() bool {
+		_, defined := s.definedEntry("report")
+		return !defined
+	})
+
+	// The subscription ends with the scheduler
+	close(repo.events)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the watch to end when its events are closed")
+	}
+}
+
+func TestWatchDefinitions_PollsWhenWatchFails(t *testing.T) {
+	ctx := context.Background()
+	RegisterHandler("poll-test", noopJob)
+	repo := &memJobRepo{watchErr: errors.New("watch unavailable")}
+	s := newTestScheduler(repo)
+	s.syncInterval = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		s.watchDefinitions()
+		close(done)
+	}()
+
+	if err := s.DefineJob(ctx, JobConfig{Name: "report", Handler: "poll-test", CronExpr: "0 0 * * * *"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, "the definition to be scheduled", func() bool {
+		_, defined := s.definedEntry("report")
+		return defined
+	})
+
+	s.baseCancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected polling to end when the scheduler shuts down")
+	}
+}
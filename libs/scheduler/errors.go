@@ -3,9 +3,16 @@ package scheduler
 type ErrorCode string
 
 const (
-	ErrCodeInvalidConfig ErrorCode = "INVALID_CONFIG"
-	ErrCodeJobNotFound   ErrorCode = "JOB_NOT_FOUND"
-	ErrCodeJobFailed     ErrorCode = "JOB_FAILED"
+	ErrCodeInvalidConfig    ErrorCode = "INVALID_CONFIG"
+	ErrCodeJobNotFound      ErrorCode = "JOB_NOT_FOUND"
+	ErrCodeJobFailed        ErrorCode = "JOB_FAILED"
+	ErrCodeJobTimeout       ErrorCode = "JOB_TIMEOUT"
+	ErrCodeJobCancelled     ErrorCode = "JOB_CANCELLED"
+	ErrCodeJobNotRunning    ErrorCode = "JOB_NOT_RUNNING"
+	ErrCodeWorkflowNotFound ErrorCode = "WORKFLOW_NOT_FOUND"
+	ErrCodeWorkflowRunning  ErrorCode = "WORKFLOW_RUNNING"
+	ErrCodeShutdown         ErrorCode = "SCHEDULER_SHUTDOWN"
+	ErrCodeShutdownTimeout  ErrorCode = "SHUTDOWN_TIMEOUT"
 )
 
 type SchedulerError struct {
@@ -20,3 +27,15 @@ func (e *SchedulerError) Error() string {
 	}
 	return e.Message
 }
+
+func (e *SchedulerError) Unwrap() error {
+	return e.Err
+}
+
+// HasErrorCode reports whether err is a SchedulerError with the given code
+func HasErrorCode(err error, code ErrorCode) bool {
+	if schedulerErr, ok := err.(*SchedulerError); ok {
+		return schedulerErr.Code == code
+	}
+	return false
+}
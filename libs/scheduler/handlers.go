@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]JobFunc)
+)
+
+// RegisterHandler makes fn available under name to jobs defined in the database.
+// Registering a name twice replaces the previous handler.
+func RegisterHandler(name string, fn JobFunc) {
+	if name == "" || fn == nil {
+		panic("scheduler: RegisterHandler requires a name and a handler")
+	}
+
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[name] = fn
+}
+
+// LookupHandler returns the handler registered under name
+func LookupHandler(name string) (JobFunc, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	fn, ok := handlers[name]
+	return fn, ok
+}
+
+// RegisteredHandlers returns the sorted names of every registered handler
+func RegisteredHandlers() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type contextKey string
+
+const correlationIDKey contextKey = "scheduler_correlation_id"
+
+// CorrelationIDFromContext returns the correlation ID of the job run executing with ctx
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	correlationID, ok := ctx.Value(correlationIDKey).(string)
+	return correlationID, ok
+}
+
+// startRun persists a running JobRun and returns it along with a context carrying its correlation ID
+func (s *Scheduler) startRun(ctx context.Context, job *Job, attempt int) (*JobRun, context.Context) {
+	run := &JobRun{
+		JobID:         job.ID,
+		JobName:       job.Name,
+		Status:        JobRunStatusRunning,
+		Attempt:       attempt,
+		StartedAt:     time.Now(),
+		Instance:      s.instanceID,
+		CorrelationID: primitive.NewObjectID().Hex(),
+	}
+
+	id, err := s.runRepo.Create(context.WithoutCancel(ctx), run)
+	if err != nil {
+		s.logger.Error("Failed to record job run", "job", job.Name, "error", err)
+	}
+	run.ID = id
+
+	return run, context.WithValue(ctx, correlationIDKey, run.CorrelationID)
+}
+
+// finishRun records the outcome of a JobRun
+func (s *Scheduler) finishRun(ctx context.Context, run *JobRun, runErr error) {
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Status = JobRunStatusCompleted
+	if runErr != nil {
+		run.Status = JobRunStatusFailed
+		if HasErrorCode(runErr, ErrCodeJobCancelled) {
+			run.Status = JobRunStatusCancelled
+		}
+		run.Error = runErr.Error()
+	}
+
+	if run.ID == "" {
+		return
+	}
+
+	_, err := s.runRepo.Update(ctx, map[string]interface{}{"_id": run.ID}, map[string]interface{}{
+		"status":      run.Status,
+		"finished_at": run.FinishedAt,
+		"duration_ms": run.DurationMs,
+		"error":       run.Error,
+	})
+	if err != nil {
+		s.logger.Error("Failed to update job run", "job", run.JobName, "run", run.ID, "error", err)
+	}
+}
+
+// recordSkippedRun records a trigger that was dropped by the job's concurrency policy
+func (s *Scheduler) recordSkippedRun(registered *registeredJob) {
+	now := time.Now()
+	run := &JobRun{
+		JobID:         registered.id,
+		JobName:       registered.config.Name,
+		Status:        JobRunStatusSkipped,
+		StartedAt:     now,
+		FinishedAt:    now,
+		Error:         "previous execution still running",
+		Instance:      s.instanceID,
+		CorrelationID: primitive.NewObjectID().Hex(),
+	}
+
+	if _, err := s.runRepo.Create(s.baseCtx, run); err != nil {
+		s.logger.Error("Failed to record skipped job run", "job", run.JobName, "error", err)
+	}
+}
+
+// GetJobRuns returns the runs of a job, newest first, along with the total number of runs.
+// An empty jobName returns the runs of every job.
+func (s *Scheduler) GetJobRuns(ctx context.Context, jobName string, pagination models.PaginationOptions) ([]*JobRun, int64, error) {
+	filter := map[string]interface{}{}
+	if jobName != "" {
+		filter["job_name"] = jobName
+	}
+
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.PageSize < 1 {
+		pagination.PageSize = 20
+	}
+
+	runs, err := s.runRepo.Find(ctx, filter, &models.FindOptions{
+		Sort:  map[string]interface{}{"started_at": -1},
+		Limit: pagination.PageSize,
+		Skip:  (pagination.Page - 1) * pagination.PageSize,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.runRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// GetJobRun fetches a single run by its ID
+func (s *Scheduler) GetJobRun(ctx context.Context, runID string) (*JobRun, error) {
+	return s.runRepo.FindByID(ctx, runID)
+}
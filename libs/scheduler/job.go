@@ -11,28 +11,79 @@ const (
 	JobStatusRunning   JobStatus = "running"
 	JobStatusCompleted JobStatus = "completed"
 	JobStatusFailed    JobStatus = "failed"
+	// JobStatusDeadLetter marks a job whose last execution failed after exhausting its retries
+	JobStatusDeadLetter JobStatus = "dead_letter"
+	// JobStatusCancelled marks a job whose last execution was cancelled
+	JobStatusCancelled JobStatus = "cancelled"
 )
 
 type JobConfig struct {
 	Name        string                 `bson:"name" json:"name"`
+	Handler     string                 `bson:"handler,omitempty" json:"handler,omitempty"` // handler name for database-defined jobs
 	CronExpr    string                 `bson:"cron_expr" json:"cron_expr"`
+	Timezone    string                 `bson:"timezone,omitempty" json:"timezone,omitempty"` // IANA name, empty means the process's local time
+	RunAt       time.Time              `bson:"run_at,omitempty" json:"run_at,omitempty"`     // fires once at this time instead of on CronExpr
 	Params      map[string]interface{} `bson:"params" json:"params"`
 	MaxRetries  int                    `bson:"max_retries" json:"max_retries"`
+	RetryPolicy *RetryPolicy           `bson:"retry_policy,omitempty" json:"retry_policy,omitempty"`
+	Timeout     time.Duration          `bson:"timeout" json:"timeout"` // bounds every attempt, zero means no timeout
 	IsRecurring bool                   `bson:"is_recurring" json:"is_recurring"`
+
+	ConcurrencyPolicy   ConcurrencyPolicy `bson:"concurrency_policy" json:"concurrency_policy"`
+	MisfirePolicy       MisfirePolicy     `bson:"misfire_policy" json:"misfire_policy"`
+	MisfireCatchUpLimit int               `bson:"misfire_catch_up_limit" json:"misfire_catch_up_limit"`
 }
 
 type Job struct {
-	ID          string                 `bson:"_id,omitempty" json:"id,omitempty"`
-	Name        string                 `bson:"name" json:"name"`
-	Status      JobStatus              `bson:"status" json:"status"`
-	CronExpr    string                 `bson:"cron_expr" json:"cron_expr"`
-	Params      map[string]interface{} `bson:"params" json:"params"`
-	LastRunAt   time.Time              `bson:"last_run_at" json:"last_run_at"`
-	NextRunAt   time.Time              `bson:"next_run_at" json:"next_run_at"`
-	RetryCount  int                    `bson:"retry_count" json:"retry_count"`
-	MaxRetries  int                    `bson:"max_retries" json:"max_retries"`
-	Error       string                 `bson:"error,omitempty" json:"error,omitempty"`
-	IsRecurring bool                   `bson:"is_recurring" json:"is_recurring"`
-	CreatedAt   time.Time              `bson:"created_at" json:"created_at"`
-	UpdatedAt   time.Time              `bson:"updated_at" json:"updated_at"`
+	ID           string                 `bson:"_id,omitempty" json:"id,omitempty"`
+	Name         string                 `bson:"name" json:"name"`
+	Handler      string                 `bson:"handler,omitempty" json:"handler,omitempty"`
+	Status       JobStatus              `bson:"status" json:"status"`
+	CronExpr     string                 `bson:"cron_expr" json:"cron_expr"`
+	Timezone     string                 `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	RunAt        time.Time              `bson:"run_at,omitempty" json:"run_at,omitempty"`
+	Params       map[string]interface{} `bson:"params" json:"params"`
+	LastRunAt    time.Time              `bson:"last_run_at" json:"last_run_at"`
+	NextRunAt    time.Time              `bson:"next_run_at" json:"next_run_at"`
+	RetryCount   int                    `bson:"retry_count" json:"retry_count"`
+	MaxRetries   int                    `bson:"max_retries" json:"max_retries"`
+	RetryPolicy  RetryPolicy            `bson:"retry_policy" json:"retry_policy"`
+	Timeout      time.Duration          `bson:"timeout" json:"timeout"`
+	RunCount     int64                  `bson:"run_count" json:"run_count"`
+	FailureCount int64                  `bson:"failure_count" json:"failure_count"`
+	Error        string                 `bson:"error,omitempty" json:"error,omitempty"`
+	IsRecurring  bool                   `bson:"is_recurring" json:"is_recurring"`
+	Paused       bool                   `bson:"paused" json:"paused"`
+
+	ConcurrencyPolicy   ConcurrencyPolicy `bson:"concurrency_policy" json:"concurrency_policy"`
+	MisfirePolicy       MisfirePolicy     `bson:"misfire_policy" json:"misfire_policy"`
+	MisfireCatchUpLimit int               `bson:"misfire_catch_up_limit" json:"misfire_catch_up_limit"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type JobRunStatus string
+
+const (
+	JobRunStatusRunning   JobRunStatus = "running"
+	JobRunStatusCompleted JobRunStatus = "completed"
+	JobRunStatusFailed    JobRunStatus = "failed"
+	JobRunStatusCancelled JobRunStatus = "cancelled"
+	JobRunStatusSkipped   JobRunStatus = "skipped"
+)
+
+// JobRun records a single execution of a job
+type JobRun struct {
+	ID            string       `bson:"_id,omitempty" json:"id,omitempty"`
+	JobID         string       `bson:"job_id" json:"job_id"`
+	JobName       string       `bson:"job_name" json:"job_name"`
+	Status        JobRunStatus `bson:"status" json:"status"`
+	Attempt       int          `bson:"attempt" json:"attempt"`
+	StartedAt     time.Time    `bson:"started_at" json:"started_at"`
+	FinishedAt    time.Time    `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	DurationMs    int64        `bson:"duration_ms" json:"duration_ms"`
+	Error         string       `bson:"error,omitempty" json:"error,omitempty"`
+	Instance      string       `bson:"instance" json:"instance"`
+	CorrelationID string       `bson:"correlation_id" json:"correlation_id"`
 }
@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// cancelGracePeriod is how long Shutdown waits for cancelled executions to record their outcome
+const cancelGracePeriod = 5 * time.Second
+
+// execution is a job execution in progress in this process
+type execution struct {
+	jobID  string
+	cancel context.CancelFunc
+}
+
+// beginExecution registers a new execution of a job and returns its context along with a
+// function that must be called once the execution is over
+func (s *Scheduler) beginExecution(jobID string) (context.Context, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return nil, nil, &SchedulerError{Code: ErrCodeShutdown, Message: "scheduler is shutting down"}
+	}
+
+	ctx, cancel := context.WithCancel(s.baseCtx)
+	s.nextExecutionID++
+	executionID := s.nextExecutionID
+	s.executions[executionID] = &execution{jobID: jobID, cancel: cancel}
+	s.inFlight.Add(1)
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.executions, executionID)
+		s.mu.Unlock()
+
+		cancel()
+		s.inFlight.Done()
+	}, nil
+}
+
+// runAttempt executes a single attempt of a job, bounded by the job timeout. An attempt that
+// ignores its context is abandoned once the context is done.
+func (s *Scheduler) runAttempt(ctx context.Context, job *Job, jobFunc JobFunc) error {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	if job.Timeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+	}
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- &SchedulerError{Code: ErrCodeJobFailed, Message: fmt.Sprintf("job %s panicked: %v", job.Name, r)}
+			}
+		}()
+		result <- jobFunc(attemptCtx, job.Params)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+		if err == nil {
+			return nil
+		}
+	case <-attemptCtx.Done():
+		s.logger.Warn("Abandoning job attempt that did not return after its context was done", "job", job.Name)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return &SchedulerError{Code: ErrCodeJobCancelled, Message: "job " + job.Name + " was cancelled", Err: ctx.Err()}
+	case attemptCtx.Err() == context.DeadlineExceeded:
+		return &SchedulerError{Code: ErrCodeJobTimeout, Message: fmt.Sprintf("job %s timed out after %s", job.Name, job.Timeout), Err: err}
+	}
+	return err
+}
+
+// CancelJob cancels every execution of the named job currently running in this process
+func (s *Scheduler) CancelJob(name string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	registered, err := s.registeredByName(name)
+	if err != nil {
+		return err
+	}
+
+	cancelled := 0
+	for _, exec := range s.executions {
+		if exec.jobID == registered.id {
+			exec.cancel()
+			cancelled++
+		}
+	}
+
+	if cancelled == 0 {
+		return &SchedulerError{Code: ErrCodeJobNotRunning, Message: "job " + name + " is not running"}
+	}
+
+	s.logger.Info("Cancelled running job", "job", name, "executions", cancelled)
+	return nil
+}
+
+// Shutdown stops triggering new executions and waits for running ones to finish. When ctx is
+// done before they finish, the running executions are cancelled and given a short grace period to
+// record their outcome before an error is returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	running := len(s.executions)
+	s.mu.Unlock()
+
+	s.cron.Stop()
+	s.stopStepTimers()
+	s.logger.Info("Scheduler stopped accepting new triggers, draining running jobs", "running", running)
+
+	drained := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		// Stops the background loops, such as the sync of database-defined jobs
+		s.baseCancel()
+		s.logger.Info("Scheduler shut down gracefully")
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.baseCancel()
+	select {
+	case <-drained:
+	case <-time.After(cancelGracePeriod):
+		s.logger.Warn("Cancelled jobs did not finish within the grace period", "gracePeriod", cancelGracePeriod)
+	}
+
+	s.logger.Warn("Scheduler shutdown deadline reached, cancelled running jobs")
+	return &SchedulerError{Code: ErrCodeShutdownTimeout, Message: "scheduler shutdown deadline exceeded", Err: ctx.Err()}
+}
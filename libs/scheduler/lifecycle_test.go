@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdown_Graceful(t *testing.T) {
+	s := newTestScheduler(&memJobRepo{})
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.baseCtx.Err() == nil {
+		t.Error("expected the background loops to be stopped")
+	}
+	if _, _, err := s.beginExecution("job"); err == nil {
+		t.Error("expected executions to be refused after shutdown")
+	}
+}
+
+func TestShutdown_TimeoutWaitsForCancelledExecutions(t *testing.T) {
+	s := newTestScheduler(&memJobRepo{})
+
+	execCtx, done, err := s.beginExecution("job")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var recorded atomic.Bool
+	go func() {
+		<-execCtx.Done()
+		// The execution records its outcome after being cancelled
+		time.Sleep(20 * time.Millisecond)
+		recorded.Store(true)
+		done()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.Shutdown(ctx)
+
+	var schedulerErr *SchedulerError
+	if !errors.As(err, &schedulerErr) || schedulerErr.Code != ErrCodeShutdownTimeout {
+		t.Errorf("expected a shutdown timeout, got %v", err)
+	}
+	if !recorded.Load() {
+		t.Error("expected Shutdown to wait for the cancelled execution")
+	}
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ConcurrencyPolicy decides what happens when a job is triggered while a previous execution is still running
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyAllow starts the new execution alongside the running one
+	ConcurrencyPolicyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyPolicySkipIfRunning drops the trigger and records a skipped run
+	ConcurrencyPolicySkipIfRunning ConcurrencyPolicy = "skip_if_running"
+	// ConcurrencyPolicyQueue starts the new execution once the running one finishes. At most one
+	// execution is queued, further triggers are coalesced into it and recorded as skipped runs.
+	ConcurrencyPolicyQueue ConcurrencyPolicy = "queue"
+)
+
+// MisfirePolicy decides what happens to triggers missed while no scheduler was running
+type MisfirePolicy string
+
+const (
+	// MisfirePolicyIgnore drops missed triggers
+	MisfirePolicyIgnore MisfirePolicy = "ignore"
+	// MisfirePolicyRunOnce runs the job once on startup when at least one trigger was missed
+	MisfirePolicyRunOnce MisfirePolicy = "run_once_on_startup"
+	// MisfirePolicyCatchUpAll runs the job once per missed trigger, up to MisfireCatchUpLimit
+	MisfirePolicyCatchUpAll MisfirePolicy = "catch_up_all"
+)
+
+// DefaultMisfireCatchUpLimit bounds catch-up runs when a job does not configure a limit
+const DefaultMisfireCatchUpLimit = 10
+
+// dispatch runs a triggered job according to its concurrency policy
+func (s *Scheduler) dispatch(name string) {
+	s.mu.Lock()
+	registered, exists := s.jobs[name]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+
+	policy := registered.config.ConcurrencyPolicy
+	if policy == ConcurrencyPolicySkipIfRunning && registered.running > 0 {
+		s.mu.Unlock()
+		s.logger.Info("Skipping job trigger, previous execution still running", "job", name)
+		s.recordSkippedRun(registered)
+		return
+	}
+	queued := false
+	if policy == ConcurrencyPolicyQueue && registered.running > 0 {
+		if registered.queued {
+			s.mu.Unlock()
+			s.logger.Info("Coalescing job trigger, an execution is already queued", "job", name)
+			s.recordSkippedRun(registered)
+			return
+		}
+		registered.queued = true
+		queued = true
+	}
+	registered.running++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		registered.running--
+		s.mu.Unlock()
+	}()
+
+	if policy == ConcurrencyPolicyQueue {
+		acquired := false
+		select {
+		case registered.slot <- struct{}{}:
+			acquired = true
+			defer func() { <-registered.slot }()
+		case <-s.baseCtx.Done():
+		}
+		if queued {
+			s.mu.Lock()
+			registered.queued = false
+			s.mu.Unlock()
+		}
+		if !acquired {
+			return
+		}
+	}
+
+	s.executeJob(registered.id, registered.jobFunc)
+}
+
+// catchUp runs the missed triggers of a job one after the other
+func (s *Scheduler) catchUp(name string, misfires int) {
+	s.logger.Info("Running missed job triggers", "job", name, "count", misfires)
+	for i := 0; i < misfires; i++ {
+		if s.baseCtx.Err() != nil {
+			return
+		}
+		s.dispatch(name)
+	}
+}
+
+// misfiresFor returns how many executions of job should be run to make up for triggers missed since its last run.
+// One-off jobs without a policy run once on startup, so a delayed job is not lost to downtime.
+// One-off jobs created with their time already past never had a trigger to miss and run once
+// right away, whatever their policy.
+func (s *Scheduler) misfiresFor(job *Job, config JobConfig) int {
+	if !config.RunAt.IsZero() && job.LastRunAt.IsZero() && !config.RunAt.After(job.CreatedAt) {
+		return 1
+	}
+
+	policy := config.MisfirePolicy
+	if policy == "" && !config.RunAt.IsZero() {
+		policy = MisfirePolicyRunOnce
+	}
+	if policy == "" || policy == MisfirePolicyIgnore {
+		return 0
+	}
+
+	since := job.LastRunAt
+	if since.IsZero() {
+		since = job.CreatedAt
+	}
+
+	limit := 1
+	if policy == MisfirePolicyCatchUpAll {
+		limit = config.MisfireCatchUpLimit
+		if limit <= 0 {
+			limit = DefaultMisfireCatchUpLimit
+		}
+	}
+
+	schedule, err := scheduleOf(config)
+	if err != nil {
+		s.logger.Error("Failed to compute missed triggers", "job", config.Name, "error", err)
+		return 0
+	}
+
+	missed := missedRunTimes(schedule, since, time.Now(), limit)
+	if len(missed) > 0 {
+		s.logger.Warn("Job missed triggers while the scheduler was down", "job", config.Name, "missed", len(missed), "policy", policy)
+	}
+	return len(missed)
+}
+
+// missedRunTimes returns up to limit fire times of schedule after since and before now
+func missedRunTimes(schedule cron.Schedule, since, now time.Time, limit int) []time.Time {
+	var missed []time.Time
+	for next := schedule.Next(since); !next.IsZero() && next.Before(now) && len(missed) < limit; next = schedule.Next(next) {
+		missed = append(missed, next)
+	}
+	return missed
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor fails the test when cond does not hold within a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingJob returns a job function that counts its executions and blocks until release is closed
+func blockingJob(executions *atomic.Int32, release <-chan struct{}) JobFunc {
+	return func(ctx context.Context, params map[string]interface{}) error {
+		executions.Add(1)
+		<-release
+		return nil
+	}
+}
+
+func (s *Scheduler) runningOf(name string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jobs[name].running
+}
+
+func TestDispatch_SkipIfRunning(t *testing.T) {
+	s := newTestScheduler(&memJobRepo{})
+	runs := s.runRepo.(*memRunRepo)
+
+	var executions atomic.Int32
+	release := make(chan struct{})
+	config := JobConfig{Name: "sync", CronExpr: "0 0 * * * *", ConcurrencyPolicy: ConcurrencyPolicySkipIfRunning}
+	if err := s.Schedule(context.Background(), config, blockingJob(&executions, release)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.dispatch("sync")
+		close(done)
+	}()
+	waitFor(t, "the first execution", func() bool { return executions.Load() == 1 })
+
+	// Registering the job again while it runs still takes its running execution into account
+	if err := s.Schedule(context.Background(), config, blockingJob(&executions, release)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.dispatch("sync")
+
+	close(release)
+	<-done
+	if got := executions.Load(); got != 1 {
+		t.Errorf("expected 1 execution, got %d", got)
+	}
+	if got := runs.count(JobRunStatusSkipped); got != 1 {
+		t.Errorf("expected 1 skipped run, got %d", got)
+	}
+	if got := s.runningOf("sync"); got != 0 {
+		t.Errorf("expected no running execution left, got %d", got)
+	}
+}
+
+func TestDispatch_Queue(t *testing.T) {
+	s := newTestScheduler(&memJobRepo{})
+	runs := s.runRepo.(*memRunRepo)
+
+	var executions atomic.Int32
+	release := make(chan struct{})
+	config := JobConfig{Name: "sync", CronExpr: "0 0 * * * *", ConcurrencyPolicy: ConcurrencyPolicyQueue}
+	if err := s.Schedule(context.Background(), config, blockingJob(&executions, release)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first, second := make(chan struct{}), make(chan struct{})
+	go func() {
+		s.dispatch("sync")
+		close(first)
+	}()
+	waitFor(t, "the first execution", func() bool { return executions.Load() == 1 })
+
+	if err := s.Schedule(context.Background(), config, blockingJob(&executions, release)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go func() {
+		s.dispatch("sync")
+		close(second)
+	}()
+	waitFor(t, "the queued trigger", func() bool { return s.runningOf("sync") == 2 })
+
+	// A third trigger is coalesced into the queued one
+	s.dispatch("sync")
+	if got := runs.count(JobRunStatusSkipped); got != 1 {
+		t.Errorf("expected 1 skipped run, got %d", got)
+	}
+	if got := executions.Load(); got != 1 {
+		t.Errorf("expected the queued trigger to wait, got %d executions", got)
+	}
+
+	close(release)
+	<-first
+	<-second
+	if got := executions.Load(); got != 2 {
+		t.Errorf("expected 2 executions, got %d", got)
+	}
+}
+
+func TestMisfiresFor_Policies(t *testing.T) {
+	s := newTestScheduler(&memJobRepo{})
+	job := Job{CreatedAt: time.Now().Add(-24 * time.Hour), LastRunAt: time.Now().Add(-5*time.Hour - time.Minute)}
+
+	cases := []struct {
+		name     string
+		policy   MisfirePolicy
+		limit    int
+		expected int
+	}{
+		{"no policy", "", 0, 0},
+		{"ignore", MisfirePolicyIgnore, 0, 0},
+		{"run once", MisfirePolicyRunOnce, 0, 1},
+		{"catch up all", MisfirePolicyCatchUpAll, 0, 5},
+		{"catch up to the limit", MisfirePolicyCatchUpAll, 2, 2},
+	}
+
+	for _, tc := range cases {
+		config := JobConfig{Name: "hourly", CronExpr: "0 0 * * * *", MisfirePolicy: tc.policy, MisfireCatchUpLimit: tc.limit}
+		if got := s.misfiresFor(&job, config); got != tc.expected {
+			t.Errorf("%s: expected %d misfires, got %d", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestSchedule_KeepsMisfiresUntilStart(t *testing.T) {
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+	config := JobConfig{Name: "hourly", CronExpr: "0 0 * * * *", MisfirePolicy: MisfirePolicyCatchUpAll}
+
+	scheduleTestJob(t, s, config)
+	repo.jobs[0].LastRunAt = time.Now().Add(-3*time.Hour - time.Minute)
+
+	// The next boot registers the job again and finds the triggers it missed
+	scheduleTestJob(t, s, config)
+	if got := s.jobs["hourly"].misfires; got != 3 {
+		t.Errorf("expected 3 misfires to run on Start, got %d", got)
+	}
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+type RetryStrategy string
+
+const (
+	// RetryStrategyFixed waits InitialInterval between every attempt
+	RetryStrategyFixed RetryStrategy = "fixed"
+	// RetryStrategyExponential multiplies the wait by Multiplier after every attempt
+	RetryStrategyExponential RetryStrategy = "exponential"
+	// RetryStrategyJittered picks a random wait between zero and the exponential wait
+	RetryStrategyJittered RetryStrategy = "jittered"
+)
+
+// RetryPolicy controls how a failed execution is retried within the same trigger
+type RetryPolicy struct {
+	Strategy        RetryStrategy `bson:"strategy" json:"strategy"`
+	InitialInterval time.Duration `bson:"initial_interval" json:"initial_interval"`
+	MaxInterval     time.Duration `bson:"max_interval" json:"max_interval"`
+	Multiplier      float64       `bson:"multiplier" json:"multiplier"`
+}
+
+// DefaultRetryPolicy is used for jobs that do not configure a retry policy
+var DefaultRetryPolicy = RetryPolicy{
+	Strategy:        RetryStrategyExponential,
+	InitialInterval: 10 * time.Second,
+	MaxInterval:     5 * time.Minute,
+	Multiplier:      2,
+}
+
+// Backoff returns how long to wait after the given failed attempt, attempts start at 1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = DefaultRetryPolicy.InitialInterval
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultRetryPolicy.Multiplier
+	}
+
+	// Computed as a float so that a wait past the range of a Duration saturates instead of
+	// overflowing into a negative one
+	wait := float64(initial)
+	if p.Strategy != RetryStrategyFixed {
+		wait *= math.Pow(multiplier, float64(attempt-1))
+	}
+	capped := p.capped(wait)
+
+	if p.Strategy == RetryStrategyJittered {
+		bound := int64(capped)
+		if bound < math.MaxInt64 {
+			bound++
+		}
+		return time.Duration(rand.Int63n(bound))
+	}
+	return capped
+}
+
+// capped limits wait to MaxInterval when one is configured, and to the longest Duration otherwise
+func (p RetryPolicy) capped(wait float64) time.Duration {
+	if p.MaxInterval > 0 && wait > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+	if wait >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(wait)
+}
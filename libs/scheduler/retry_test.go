@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff_Fixed(t *testing.T) {
+	policy := RetryPolicy{Strategy: RetryStrategyFixed, InitialInterval: 5 * time.Second}
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		if wait := policy.Backoff(attempt); wait != 5*time.Second {
+			t.Errorf("attempt %d: expected 5s, got %s", attempt, wait)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff_ExponentialIsCapped(t *testing.T) {
+	policy := RetryPolicy{
+		Strategy:        RetryStrategyExponential,
+		InitialInterval: time.Second,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2,
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if wait := policy.Backoff(i + 1); wait != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, wait)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff_JitteredStaysWithinBounds(t *testing.T) {
+	policy := RetryPolicy{
+		Strategy:        RetryStrategyJittered,
+		InitialInterval: time.Second,
+		MaxInterval:     3 * time.Second,
+		Multiplier:      2,
+	}
+
+	for i := 0; i < 100; i++ {
+		wait := policy.Backoff(3)
+		if wait < 0 || wait > 3*time.Second {
+			t.Fatalf("expected wait within [0, 3s], got %s", wait)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff_Overflow(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy RetryPolicy
+		max    time.Duration
+	}{
+		{"fixed", RetryPolicy{Strategy: RetryStrategyFixed, InitialInterval: time.Second}, time.Second},
+		{"exponential", RetryPolicy{Strategy: RetryStrategyExponential, InitialInterval: time.Second, Multiplier: 2}, time.Duration(math.MaxInt64)},
+		{"exponential capped", RetryPolicy{Strategy: RetryStrategyExponential, InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2}, time.Minute},
+		{"jittered", RetryPolicy{Strategy: RetryStrategyJittered, InitialInterval: time.Second, Multiplier: 2}, time.Duration(math.MaxInt64)},
+		{"jittered capped", RetryPolicy{Strategy: RetryStrategyJittered, InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2}, time.Minute},
+	}
+
+	for _, tc := range cases {
+		for _, attempt := range []int{64, 100, 2000} {
+			wait := tc.policy.Backoff(attempt)
+			if wait < 0 || wait > tc.max {
+				t.Errorf("%s, attempt %d: expected a wait within [0, %s], got %s", tc.name, attempt, tc.max, wait)
+			}
+			if tc.policy.Strategy != RetryStrategyJittered && wait != tc.max {
+				t.Errorf("%s, attempt %d: expected %s, got %s", tc.name, attempt, tc.max, wait)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MaxPreviewRuns bounds how many fire times NextRunTimes computes in one call
+const MaxPreviewRuns = 100
+
+// cronParser parses six-field, seconds-enabled cron expressions
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSchedule parses a six-field cron expression evaluated in the given IANA timezone.
+// An empty timezone evaluates the expression in the local time of the process.
+func ParseSchedule(cronExpr, timezone string) (cron.Schedule, error) {
+	if timezone != "" {
+		if strings.HasPrefix(cronExpr, "CRON_TZ=") || strings.HasPrefix(cronExpr, "TZ=") {
+			return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "cron expression already sets a timezone"}
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "invalid timezone " + timezone, Err: err}
+		}
+	}
+
+	schedule, err := cronParser.Parse(scheduleSpec(cronExpr, timezone))
+	if err != nil {
+		return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "invalid cron expression " + cronExpr, Err: err}
+	}
+	return schedule, nil
+}
+
+// NextRunTimes returns the next n fire times of cronExpr in the given timezone after from
+func NextRunTimes(cronExpr, timezone string, from time.Time, n int) ([]time.Time, error) {
+	if n <= 0 || n > MaxPreviewRuns {
+		return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: fmt.Sprintf("number of fire times must be between 1 and %d", MaxPreviewRuns)}
+	}
+
+	schedule, err := ParseSchedule(cronExpr, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]time.Time, 0, n)
+	for next := schedule.Next(from); !next.IsZero() && len(runs) < n; next = schedule.Next(next) {
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
+
+// scheduleOf returns when a job fires: once at RunAt for one-off jobs, on its cron expression otherwise
+func scheduleOf(config JobConfig) (cron.Schedule, error) {
+	if config.RunAt.IsZero() {
+		return ParseSchedule(config.CronExpr, config.Timezone)
+	}
+	if config.CronExpr != "" {
+		return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "job " + config.Name + " sets both a cron expression and a run time"}
+	}
+	return onceSchedule{at: config.RunAt}, nil
+}
+
+// onceSchedule fires a single time, a zero time tells cron there is nothing left to run
+type onceSchedule struct {
+	at time.Time
+}
+
+func (o onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(o.at) {
+		return o.at
+	}
+	return time.Time{}
+}
+
+// scheduleSpec prefixes cronExpr with the timezone it is evaluated in, as understood by the cron parser
+func scheduleSpec(cronExpr, timezone string) string {
+	if timezone == "" {
+		return cronExpr
+	}
+	return "CRON_TZ=" + timezone + " " + cronExpr
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRunTimes_SecondsField(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 2, 30, 0, time.UTC)
+
+	runs, err := NextRunTimes("0 */5 * * * *", "UTC", from, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []time.Time{
+		time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC),
+	}
+	if len(runs) != len(expected) {
+		t.Fatalf("expected %d runs, got %d", len(expected), len(runs))
+	}
+	for i, want := range expected {
+		if !runs[i].Equal(want) {
+			t.Errorf("run %d: expected %s, got %s", i, want, runs[i])
+		}
+	}
+}
+
+func TestNextRunTimes_Timezone(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("timezone database unavailable: %v", err)
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	runs, err := NextRunTimes("0 0 9 * * *", "Asia/Kolkata", from, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := time.Date(2024, 1, 1, 9, 0, 0, 0, location); !runs[0].Equal(want) {
+		t.Errorf("expected %s, got %s", want, runs[0])
+	}
+}
+
+func TestNextRunTimes_InvalidInput(t *testing.T) {
+	cases := []struct {
+		name     string
+		cronExpr string
+		timezone string
+		n        int
+	}{
+		{"five fields", "*/5 * * * *", "", 1},
+		{"unknown timezone", "0 */5 * * * *", "Mars/Olympus", 1},
+		{"too many runs", "0 */5 * * * *", "", MaxPreviewRuns + 1},
+	}
+
+	for _, tc := range cases {
+		if _, err := NextRunTimes(tc.cronExpr, tc.timezone, time.Now(), tc.n); !HasErrorCode(err, ErrCodeInvalidConfig) {
+			t.Errorf("%s: expected an invalid config error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestOnceSchedule_FiresOnce(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	schedule, err := scheduleOf(JobConfig{Name: "once", RunAt: at})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if next := schedule.Next(at.Add(-time.Minute)); !next.Equal(at) {
+		t.Errorf("expected %s, got %s", at, next)
+	}
+	if next := schedule.Next(at); !next.IsZero() {
+		t.Errorf("expected no fire time after %s, got %s", at, next)
+	}
+
+	if _, err := scheduleOf(JobConfig{Name: "both", RunAt: at, CronExpr: "0 * * * * *"}); !HasErrorCode(err, ErrCodeInvalidConfig) {
+		t.Errorf("expected an invalid config error, got %v", err)
+	}
+}
+
+func TestMisfiresFor_RunAt(t *testing.T) {
+	now := time.Now()
+	s := &Scheduler{}
+
+	cases := []struct {
+		name     string
+		job      Job
+		config   JobConfig
+		expected int
+	}{
+		{"created after its time", Job{CreatedAt: now}, JobConfig{RunAt: now.Add(-time.Hour)}, 1},
+		{"created after its time, misfires ignored", Job{CreatedAt: now}, JobConfig{RunAt: now.Add(-time.Hour), MisfirePolicy: MisfirePolicyIgnore}, 1},
+		{"not due yet", Job{CreatedAt: now}, JobConfig{RunAt: now.Add(time.Hour)}, 0},
+		{"already run", Job{CreatedAt: now.Add(-2 * time.Hour), LastRunAt: now.Add(-time.Hour)}, JobConfig{RunAt: now.Add(-time.Hour)}, 0},
+	}
+
+	for _, tc := range cases {
+		if got := s.misfiresFor(&tc.job, tc.config); got != tc.expected {
+			t.Errorf("%s: expected %d misfires, got %d", tc.name, tc.expected, got)
+		}
+	}
+}
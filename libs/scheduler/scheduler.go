@@ -2,98 +2,399 @@ package scheduler
 
 import (
 	"context"
+	"os"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
 	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
 	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
 	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
 )
 
 type SchedulerConfig struct {
+	// RetentionPeriod is how long job run history is kept
 	RetentionPeriod time.Duration
 	Collection      *models.MongoCollection
-	Logger          logger.ILogger
+	// RunCollection stores one JobRun document per execution
+	RunCollection *models.MongoCollection
+	// WorkflowCollection stores one WorkflowRun document per workflow execution, workflows are
+	// unavailable without it
+	WorkflowCollection *models.MongoCollection
+	// InstanceID identifies this process in job runs, defaults to the hostname
+	InstanceID string
+	// DeadLetterHook is called when a job execution fails after exhausting its retries
+	DeadLetterHook DeadLetterHook
+	// SyncInterval is how often database-defined jobs are polled when change streams are not
+	// supported, defaults to DefaultSyncInterval. Otherwise they are reloaded as they change.
+	SyncInterval time.Duration
+	Logger       logger.ILogger
+}
+
+// JobFunc is the function executed every time a scheduled job fires
+type JobFunc func(context.Context, map[string]interface{}) error
+
+// DeadLetterHook is notified about job executions that failed after exhausting their retries
+type DeadLetterHook func(ctx context.Context, job *Job, err error)
+
+// registeredJob tracks a job scheduled in this process, either registered by code or defined in the database
+type registeredJob struct {
+	*concurrency
+	id       string
+	config   JobConfig
+	jobFunc  JobFunc
+	entryID  cron.EntryID
+	misfires int
+	dynamic  bool
+}
+
+// concurrency tracks the executions of a job for its concurrency policy. It outlives the
+// registration of the job, so that a job registered again while it runs keeps its policy applied.
+type concurrency struct {
+	running int
+	queued  bool
+	slot    chan struct{}
 }
 
 type Scheduler struct {
 	cron            *cron.Cron
-	jobs            map[string]cron.EntryID
+	jobs            map[string]*registeredJob
+	concurrency     map[string]*concurrency
+	executions      map[uint64]*execution
+	nextExecutionID uint64
+	inFlight        sync.WaitGroup
+	started         bool
+	stopping        bool
+	baseCtx         context.Context
+	baseCancel      context.CancelFunc
 	jobRepo         repository.IRepository[Job]
+	runRepo         repository.IRepository[JobRun]
+	workflowRepo    repository.IRepository[WorkflowRun]
+	workflows       map[string]*registeredWorkflow
+	workflowMu      sync.Mutex
+	stepTimers      map[string]*time.Timer
+	timersStopped   bool
+	timersMu        sync.Mutex
+	instanceID      string
+	deadLetterHook  DeadLetterHook
+	syncInterval    time.Duration
+	missingHandlers map[string]bool
 	logger          logger.ILogger
 	mu              sync.RWMutex
+	indexOnce       sync.Once
 	retentionPeriod time.Duration
 }
 
 func NewScheduler(config SchedulerConfig) *Scheduler {
 	jobRepo := repository.Repository[Job]{Collection: config.Collection}
+	runRepo := repository.Repository[JobRun]{Collection: config.RunCollection}
+
+	var workflowRepo repository.IRepository[WorkflowRun]
+	if config.WorkflowCollection != nil {
+		workflowRepo = &repository.Repository[WorkflowRun]{Collection: config.WorkflowCollection}
+	}
+
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
+	syncInterval := config.SyncInterval
+	if syncInterval <= 0 {
+		syncInterval = DefaultSyncInterval
+	}
+
+	baseCtx, baseCancel := context.WithCancel(context.Background())
+
 	scheduler := &Scheduler{
-		cron:            cron.New(cron.WithSeconds()),
-		jobs:            make(map[string]cron.EntryID),
+		cron:            cron.New(cron.WithParser(cronParser)),
+		jobs:            make(map[string]*registeredJob),
+		concurrency:     make(map[string]*concurrency),
+		executions:      make(map[uint64]*execution),
+		baseCtx:         baseCtx,
+		baseCancel:      baseCancel,
 		jobRepo:         &jobRepo,
+		runRepo:         &runRepo,
+		workflowRepo:    workflowRepo,
+		workflows:       make(map[string]*registeredWorkflow),
+		stepTimers:      make(map[string]*time.Timer),
+		instanceID:      instanceID,
+		deadLetterHook:  config.DeadLetterHook,
+		syncInterval:    syncInterval,
+		missingHandlers: make(map[string]bool),
 		logger:          config.Logger,
 		retentionPeriod: config.RetentionPeriod,
 	}
 
 	// Schedule cleanup job at midnight
-	scheduler.cron.AddFunc("0 0 * * *", func() {
+	scheduler.cron.AddFunc("0 0 0 * * *", func() {
 		scheduler.cleanup(context.Background())
 	})
 
 	return scheduler
 }
 
+// cleanup removes job runs older than the retention period, job definitions are kept
 func (s *Scheduler) cleanup(ctx context.Context) {
 	cutoff := time.Now().Add(-s.retentionPeriod)
 	filter := map[string]interface{}{
-		"updated_at": map[string]interface{}{
+		"started_at": map[string]interface{}{
 			"$lt": cutoff,
 		},
 	}
 
-	_, err := s.jobRepo.Delete(ctx, filter)
+	deleted, err := s.runRepo.Delete(ctx, filter)
+	if err != nil {
+		s.logger.Error("Failed to cleanup old job runs", "error", err)
+		return
+	}
+	s.logger.Info("Cleaned up old job runs", "deleted", deleted)
+
+	if s.workflowRepo == nil {
+		return
+	}
+	deleted, err = s.workflowRepo.Delete(ctx, map[string]interface{}{
+		"status":      map[string]interface{}{"$ne": WorkflowStatusRunning},
+		"finished_at": map[string]interface{}{"$lt": cutoff},
+	})
 	if err != nil {
-		s.logger.Error("Failed to cleanup old jobs", "error", err)
+		s.logger.Error("Failed to cleanup old workflow runs", "error", err)
+		return
 	}
+	s.logger.Info("Cleaned up old workflow runs", "deleted", deleted)
 }
 
-func (s *Scheduler) Schedule(ctx context.Context, config JobConfig, jobFunc func(context.Context, map[string]interface{}) error) error {
-	job := &Job{
-		Name:        config.Name,
-		Status:      JobStatusPending,
-		CronExpr:    config.CronExpr,
-		Params:      config.Params,
-		MaxRetries:  config.MaxRetries,
-		IsRecurring: config.IsRecurring,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+// Schedule registers a job under its name. Registration is idempotent: the job document is
+// created on first registration and reused afterwards, keeping its run history and counters.
+// A changed cron expression or changed params are reconciled onto the stored document.
+// ctx is only used for registration, executions run with a context owned by the scheduler.
+func (s *Scheduler) Schedule(ctx context.Context, config JobConfig, jobFunc JobFunc) error {
+	if config.Name == "" {
+		return &SchedulerError{Code: ErrCodeInvalidConfig, Message: "job name is required"}
 	}
+	if _, err := scheduleOf(config); err != nil {
+		return err
+	}
+
+	s.ensureIndexes(ctx)
 
-	// Save job to database
-	id, err := s.jobRepo.Create(ctx, job)
+	// Save or reconcile the job in the database
+	job, err := s.upsertJob(ctx, config)
 	if err != nil {
 		return err
 	}
 
-	// Create cron job
-	entryID, err := s.cron.AddFunc(config.CronExpr, func() {
-		s.executeJob(ctx, id, jobFunc)
+	// Paused jobs stay registered but are not triggered until resumed
+	misfires := 0
+	if !job.Paused {
+		misfires = s.misfiresFor(job, config)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Re-registering a name replaces the previous cron entry
+	if existing, exists := s.jobs[config.Name]; exists {
+		s.cron.Remove(existing.entryID)
+	}
+
+	registered := &registeredJob{
+		concurrency: s.concurrencyOf(config.Name),
+		id:          job.ID,
+		config:      config,
+		jobFunc:     jobFunc,
+		misfires:    misfires,
+	}
+
+	if !job.Paused {
+		entryID, err := s.addCronEntry(config.Name, config)
+		if err != nil {
+			return err
+		}
+		registered.entryID = entryID
+	}
+
+	s.jobs[config.Name] = registered
+
+	// Missed triggers are run on Start, or right away when the scheduler is already running
+	if s.started && misfires > 0 {
+		s.jobs[config.Name].misfires = 0
+		go s.catchUp(config.Name, misfires)
+	}
+
+	return nil
+}
+
+// concurrencyOf returns the concurrency state of the named job, the caller must hold s.mu
+func (s *Scheduler) concurrencyOf(name string) *concurrency {
+	state, exists := s.concurrency[name]
+	if !exists {
+		state = &concurrency{slot: make(chan struct{}, 1)}
+		s.concurrency[name] = state
+	}
+	return state
+}
+
+// addCronEntry adds a cron entry that dispatches the named job on the schedule of config
+func (s *Scheduler) addCronEntry(name string, config JobConfig) (cron.EntryID, error) {
+	schedule, err := scheduleOf(config)
+	if err != nil {
+		return 0, err
+	}
+	return s.cron.Schedule(schedule, cron.FuncJob(func() {
+		s.dispatch(name)
+	})), nil
+}
+
+// upsertJob creates the job document for config.Name or reconciles the existing one
+func (s *Scheduler) upsertJob(ctx context.Context, config JobConfig) (*Job, error) {
+	existing, err := s.jobRepo.FindOne(ctx, map[string]interface{}{"name": config.Name}, nil)
+	if err != nil && err != mongo_errors.ErrDocumentNotFound {
+		return nil, err
+	}
+
+	if existing == nil {
+		job := newJob(config)
+		id, err := s.jobRepo.Create(ctx, job)
+		if err == nil {
+			s.logger.Info("Registered new job", "job", config.Name)
+			job.ID = id
+			return job, nil
+		}
+
+		// Another instance may have registered the same name concurrently
+		existing, err = s.jobRepo.FindOne(ctx, map[string]interface{}{"name": config.Name}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updateFields := map[string]interface{}{}
+	if existing.Handler != config.Handler {
+		updateFields["handler"] = config.Handler
+	}
+	if existing.CronExpr != config.CronExpr {
+		updateFields["cron_expr"] = config.CronExpr
+	}
+	if existing.Timezone != config.Timezone {
+		updateFields["timezone"] = config.Timezone
+	}
+	// MongoDB stores times with millisecond precision
+	if runAt := config.RunAt.Truncate(time.Millisecond); !existing.RunAt.Equal(runAt) {
+		updateFields["run_at"] = runAt
+	}
+	if !paramsEqual(existing.Params, config.Params) {
+		updateFields["params"] = config.Params
+	}
+	if existing.MaxRetries != config.MaxRetries {
+		updateFields["max_retries"] = config.MaxRetries
+	}
+	if policy := retryPolicyFor(config); existing.RetryPolicy != policy {
+		updateFields["retry_policy"] = policy
+	}
+	if existing.IsRecurring != config.IsRecurring {
+		updateFields["is_recurring"] = config.IsRecurring
+	}
+	if existing.Timeout != config.Timeout {
+		updateFields["timeout"] = config.Timeout
+	}
+	if existing.ConcurrencyPolicy != config.ConcurrencyPolicy {
+		updateFields["concurrency_policy"] = config.ConcurrencyPolicy
+	}
+	if existing.MisfirePolicy != config.MisfirePolicy {
+		updateFields["misfire_policy"] = config.MisfirePolicy
+	}
+	if existing.MisfireCatchUpLimit != config.MisfireCatchUpLimit {
+		updateFields["misfire_catch_up_limit"] = config.MisfireCatchUpLimit
+	}
+
+	if len(updateFields) > 0 {
+		changed := make([]string, 0, len(updateFields))
+		for field := range updateFields {
+			changed = append(changed, field)
+		}
+		updateFields["updated_at"] = time.Now()
+
+		updated, err := s.jobRepo.FindOneAndUpdate(ctx, map[string]interface{}{"_id": existing.ID}, updateFields, &models.FindOneAndUpdateOptions{ReturnAfter: true})
+		if err != nil {
+			return nil, err
+		}
+		s.logger.Info("Reconciled job definition", "job", config.Name, "changed", changed)
+		return updated, nil
+	}
+
+	return existing, nil
+}
+
+// ensureIndexes collapses duplicate job documents left by earlier versions and enforces one document per job name
+func (s *Scheduler) ensureIndexes(ctx context.Context) {
+	s.indexOnce.Do(func() {
+		if err := s.removeDuplicateJobs(ctx); err != nil {
+			s.logger.Error("Failed to remove duplicate jobs", "error", err)
+		}
+		if err := s.jobRepo.CreateIndex(ctx, bson.D{{Key: "name", Value: 1}}, true); err != nil {
+			s.logger.Error("Failed to create unique index on job name", "error", err)
+		}
+		if err := s.runRepo.CreateIndex(ctx, bson.D{{Key: "job_name", Value: 1}, {Key: "started_at", Value: -1}}, false); err != nil {
+			s.logger.Error("Failed to create index on job runs", "error", err)
+		}
+		if s.workflowRepo != nil {
+			if err := s.workflowRepo.CreateIndex(ctx, bson.D{{Key: "status", Value: 1}}, false); err != nil {
+				s.logger.Error("Failed to create index on workflow status", "error", err)
+			}
+			if err := s.workflowRepo.CreateIndex(ctx, bson.D{{Key: "workflow", Value: 1}, {Key: "created_at", Value: -1}}, false); err != nil {
+				s.logger.Error("Failed to create index on workflow runs", "error", err)
+			}
+			// Exclusive workflows have at most one running run
+			exclusive := map[string]interface{}{"exclusive": true, "status": WorkflowStatusRunning}
+			if err := s.workflowRepo.CreatePartialIndex(ctx, bson.D{{Key: "workflow", Value: 1}}, exclusive); err != nil {
+				s.logger.Error("Failed to create unique index on running exclusive workflows", "error", err)
+			}
+		}
+	})
+}
+
+// removeDuplicateJobs keeps the oldest document for every job name and deletes the rest
+func (s *Scheduler) removeDuplicateJobs(ctx context.Context) error {
+	jobs, err := s.jobRepo.Find(ctx, map[string]interface{}{}, &models.FindOptions{
+		Sort: map[string]interface{}{"created_at": 1},
 	})
 	if err != nil {
 		return err
 	}
 
-	s.mu.Lock()
-	s.jobs[id] = entryID
-	s.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, job := range jobs {
+		if !seen[job.Name] {
+			seen[job.Name] = true
+			continue
+		}
 
+		if _, err := s.jobRepo.Delete(ctx, map[string]interface{}{"_id": job.ID}); err != nil {
+			return err
+		}
+		s.logger.Warn("Removed duplicate job document", "job", job.Name, "id", job.ID)
+	}
 	return nil
 }
 
-func (s *Scheduler) executeJob(ctx context.Context, jobID string, jobFunc func(context.Context, map[string]interface{}) error) {
-	job, err := s.GetJobStatus(ctx, jobID)
+func (s *Scheduler) executeJob(jobID string, jobFunc JobFunc) {
+	execCtx, finish, err := s.beginExecution(jobID)
 	if err != nil {
+		s.logger.Warn("Skipping job execution", "id", jobID, "error", err)
+		return
+	}
+	defer finish()
+
+	// Bookkeeping must still be written when the execution is cancelled
+	ctx := context.WithoutCancel(execCtx)
+
+	job, err := s.GetJobStatus(ctx, jobID)
+	if err != nil || job == nil {
 		s.logger.Error("Failed to get job details", "error", err)
 		return
 	}
@@ -108,44 +409,97 @@ func (s *Scheduler) executeJob(ctx context.Context, jobID string, jobFunc func(c
 		return
 	}
 
-	err = jobFunc(ctx, job.Params)
+	attempt, err := s.runWithRetries(execCtx, job, jobFunc)
 	updateFields := map[string]interface{}{
 		"updated_at":  time.Now(),
-		"next_run_at": s.getNextRunTime(job.CronExpr),
+		"next_run_at": s.getNextRunTime(job),
+		"run_count":   job.RunCount + 1,
+		"retry_count": attempt - 1,
 	}
 
-	if err != nil {
+	cancelled := HasErrorCode(err, ErrCodeJobCancelled)
+	// A recurring job without retries simply failed this run, it is dead lettered only once
+	// its retries are exhausted or when it will not run again
+	deadLettered := err != nil && !cancelled && (!job.IsRecurring || job.MaxRetries > 0)
+	switch {
+	case cancelled:
+		updateFields["status"] = JobStatusCancelled
+		updateFields["error"] = err.Error()
+	case deadLettered:
+		updateFields["status"] = JobStatusDeadLetter
+		updateFields["error"] = err.Error()
+		updateFields["failure_count"] = job.FailureCount + 1
+	case err != nil:
 		updateFields["status"] = JobStatusFailed
 		updateFields["error"] = err.Error()
-		updateFields["retry_count"] = job.RetryCount + 1
-
-		if job.RetryCount >= job.MaxRetries && !job.IsRecurring {
-			s.removeJob(ctx, jobID)
-			return
-		}
-	} else {
+		updateFields["failure_count"] = job.FailureCount + 1
+	default:
 		updateFields["status"] = JobStatusCompleted
 		updateFields["error"] = ""
-		updateFields["retry_count"] = 0
 	}
 
-	_, err = s.jobRepo.Update(ctx, map[string]interface{}{"_id": jobID}, updateFields)
-	if err != nil {
-		s.logger.Error("Failed to update job status", "error", err)
+	_, updateErr := s.jobRepo.Update(ctx, map[string]interface{}{"_id": jobID}, updateFields)
+	if updateErr != nil {
+		s.logger.Error("Failed to update job status", "error", updateErr)
+	}
+
+	if deadLettered {
+		s.deadLetter(ctx, job, err)
+	}
+}
+
+// runWithRetries executes jobFunc, retrying failed attempts according to the job's retry policy.
+// It returns the number of the last attempt and its error.
+func (s *Scheduler) runWithRetries(ctx context.Context, job *Job, jobFunc JobFunc) (int, error) {
+	attempt := 1
+	for {
+		run, runCtx := s.startRun(ctx, job, attempt)
+		err := s.runAttempt(runCtx, job, jobFunc)
+		s.finishRun(context.WithoutCancel(ctx), run, err)
+
+		if err == nil || attempt > job.MaxRetries || HasErrorCode(err, ErrCodeJobCancelled) {
+			return attempt, err
+		}
+
+		wait := job.RetryPolicy.Backoff(attempt)
+		s.logger.Warn("Job attempt failed, retrying", "job", job.Name, "attempt", attempt, "retryIn", wait.String(), "error", err)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return attempt, &SchedulerError{Code: ErrCodeJobCancelled, Message: "job " + job.Name + " was cancelled while waiting to retry", Err: ctx.Err()}
+		}
+		attempt++
+	}
+}
+
+// deadLetter handles a job whose retries are exhausted: non-recurring jobs are unscheduled and the
+// configured dead letter hook is notified
+func (s *Scheduler) deadLetter(ctx context.Context, job *Job, err error) {
+	s.logger.Error("Job moved to dead letter after exhausting retries", "job", job.Name, "maxRetries", job.MaxRetries, "error", err)
+
+	if !job.IsRecurring {
+		s.unscheduleJob(job.ID)
+	}
+
+	if s.deadLetterHook != nil {
+		job.Status = JobStatusDeadLetter
+		job.Error = err.Error()
+		s.deadLetterHook(ctx, job, err)
 	}
 }
 
-func (s *Scheduler) removeJob(ctx context.Context, jobID string) error {
+// unscheduleJob removes the cron entry of a job, its document and run history are kept
+func (s *Scheduler) unscheduleJob(jobID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if entryID, exists := s.jobs[jobID]; exists {
-		s.cron.Remove(entryID)
-		delete(s.jobs, jobID)
+	for name, registered := range s.jobs {
+		if registered.id == jobID {
+			s.cron.Remove(registered.entryID)
+			delete(s.jobs, name)
+		}
 	}
-
-	_, err := s.jobRepo.Delete(ctx, map[string]interface{}{"_id": jobID})
-	return err
 }
 
 func (s *Scheduler) GetJobStatus(ctx context.Context, jobID string) (*Job, error) {
@@ -156,22 +510,172 @@ func (s *Scheduler) GetJobStatus(ctx context.Context, jobID string) (*Job, error
 	return jobs[0], nil
 }
 
+// ListJobs returns every persisted job, with the next run time of jobs scheduled in this process
 func (s *Scheduler) ListJobs(ctx context.Context) ([]*Job, error) {
-	return s.jobRepo.Find(ctx, map[string]interface{}{}, nil)
+	jobs, err := s.jobRepo.Find(ctx, map[string]interface{}{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		s.fillNextRunAt(job)
+	}
+	return jobs, nil
+}
+
+// fillNextRunAt sets the next run time of a job from its cron entry when it is scheduled in this process
+func (s *Scheduler) fillNextRunAt(job *Job) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if registered, exists := s.jobs[job.Name]; exists && registered.entryID != 0 {
+		if next := s.cron.Entry(registered.entryID).Next; !next.IsZero() {
+			job.NextRunAt = next
+		}
+	}
+}
+
+// OrphanedJobs returns the persisted jobs that are no longer registered by code in this process.
+// It is only meaningful once every job of the process has been scheduled.
+func (s *Scheduler) OrphanedJobs(ctx context.Context) ([]*Job, error) {
+	jobs, err := s.ListJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orphans []*Job
+	for _, job := range jobs {
+		if _, registered := s.jobs[job.Name]; !registered {
+			orphans = append(orphans, job)
+		}
+	}
+	return orphans, nil
+}
+
+// ReportOrphanedJobs logs a warning for every orphaned job and returns them
+func (s *Scheduler) ReportOrphanedJobs(ctx context.Context) ([]*Job, error) {
+	orphans, err := s.OrphanedJobs(ctx)
+	if err != nil {
+		s.logger.Error("Failed to look up orphaned jobs", "error", err)
+		return nil, err
+	}
+
+	for _, job := range orphans {
+		s.logger.Warn("Job is persisted but no longer registered by code", "job", job.Name, "id", job.ID, "lastRunAt", job.LastRunAt)
+	}
+	return orphans, nil
 }
 
-func (s *Scheduler) getNextRunTime(cronExpr string) time.Time {
-	schedule, err := cron.ParseStandard(cronExpr)
+func (s *Scheduler) getNextRunTime(job *Job) time.Time {
+	schedule, err := scheduleOf(configFromJob(job))
 	if err != nil {
 		return time.Time{}
 	}
 	return schedule.Next(time.Now())
 }
 
+// Start loads the database-defined jobs, begins triggering jobs, runs the triggers missed while
+// the scheduler was down and resumes running workflows. Workflows must be registered before Start.
+// Calling Start again has no effect.
 func (s *Scheduler) Start() {
+	s.mu.RLock()
+	started := s.started
+	s.mu.RUnlock()
+	if started {
+		return
+	}
+
+	if err := s.SyncDefinitions(s.baseCtx); err != nil {
+		s.logger.Error("Failed to load job definitions", "error", err)
+	}
+
+	s.mu.Lock()
+	s.started = true
+	for name, registered := range s.jobs {
+		if registered.misfires > 0 {
+			go s.catchUp(name, registered.misfires)
+			registered.misfires = 0
+		}
+	}
+	s.mu.Unlock()
+
 	s.cron.Start()
+	s.resumeWorkflows(s.baseCtx)
+	go s.watchDefinitions()
 }
 
+// Stop halts new triggers without waiting for running jobs, use Shutdown to drain them
 func (s *Scheduler) Stop() context.Context {
 	return s.cron.Stop()
 }
+
+// newJob builds the document stored when a job is registered for the first time
+func newJob(config JobConfig) *Job {
+	return &Job{
+		Name:        config.Name,
+		Handler:     config.Handler,
+		Status:      JobStatusPending,
+		CronExpr:    config.CronExpr,
+		Timezone:    config.Timezone,
+		RunAt:       config.RunAt,
+		Params:      config.Params,
+		MaxRetries:  config.MaxRetries,
+		RetryPolicy: retryPolicyFor(config),
+		Timeout:     config.Timeout,
+		IsRecurring: config.IsRecurring,
+
+		ConcurrencyPolicy:   config.ConcurrencyPolicy,
+		MisfirePolicy:       config.MisfirePolicy,
+		MisfireCatchUpLimit: config.MisfireCatchUpLimit,
+
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// retryPolicyFor returns the retry policy configured for a job or the default one
+func retryPolicyFor(config JobConfig) RetryPolicy {
+	if config.RetryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+	return *config.RetryPolicy
+}
+
+// paramsEqual compares job params as they are stored, so that the numeric types and nested
+// documents decoded from MongoDB compare equal to the values used in code
+func paramsEqual(a, b map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	normalizedA, errA := normalizeParams(a)
+	normalizedB, errB := normalizeParams(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalizedA, normalizedB)
+}
+
+// normalizeParams round-trips params through BSON, nested documents are decoded as maps so that
+// their field order does not matter
+func normalizeParams(params map[string]interface{}) (bson.M, error) {
+	encoded, err := bson.Marshal(bson.M{"params": params})
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	decoder.DefaultDocumentM()
+
+	var document bson.M
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	normalized, _ := document["params"].(bson.M)
+	return normalized, nil
+}
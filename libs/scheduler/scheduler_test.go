@@ -0,0 +1,303 @@
+package scheduler
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// memJobRepo keeps job documents in memory. Filters match on their plain fields, operators such as
+// $exists are ignored. Documents go through BSON on every write, like they would in MongoDB.
+type memJobRepo struct {
+	repository.IRepository[Job]
+	mu      sync.Mutex
+	jobs    []*Job
+	nextID  int
+	writes  int
+	failing error
+	// events is returned by Watch, which fails with watchErr when it is set
+	events   chan models.ChangeEvent[Job]
+	watchErr error
+}
+
+func (r *memJobRepo) matches(job *Job, filter interface{}) bool {
+	doc, _ := toDocument(job)
+	for key, value := range filter.(map[string]interface{}) {
+		if _, operator := value.(map[string]interface{}); operator {
+			continue
+		}
+		if key == "_id" {
+			if job.ID != value {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(doc[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *memJobRepo) FindOne(ctx context.Context, filter interface{}, opts ...*models.FindOptions) (*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if r.matches(job, filter) {
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, mongo_errors.ErrDocumentNotFound
+}
+
+func (r *memJobRepo) Find(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := []*Job{}
+	for _, job := range r.jobs {
+		if r.matches(job, filter) {
+			copied := *job
+			found = append(found, &copied)
+		}
+	}
+	return found, nil
+}
+
+func (r *memJobRepo) Create(ctx context.Context, data *Job) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	r.writes++
+	stored, err := roundTrip(data)
+	if err != nil {
+		return "", err
+	}
+	stored.ID = strconv.Itoa(r.nextID)
+	r.jobs = append(r.jobs, stored)
+	return stored.ID, nil
+}
+
+func (r *memJobRepo) Update(ctx context.Context, filter interface{}, update interface{}) (*models.UpdateResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failing != nil {
+		return nil, r.failing
+	}
+
+	result := &models.UpdateResult{}
+	for i, job := range r.jobs {
+		if !r.matches(job, filter) {
+			continue
+		}
+		updated, err := applyUpdate(job, update.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		r.jobs[i] = updated
+		r.writes++
+		result.MatchedCount++
+		result.ModifiedCount++
+	}
+	return result, nil
+}
+
+func (r *memJobRepo) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*models.FindOneAndUpdateOptions) (*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failing != nil {
+		return nil, r.failing
+	}
+
+	for i, job := range r.jobs {
+		if !r.matches(job, filter) {
+			continue
+		}
+		updated, err := applyUpdate(job, update.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		r.jobs[i] = updated
+		r.writes++
+		copied := *updated
+		return &copied, nil
+	}
+	return nil, mongo_errors.ErrDocumentNotFound
+}
+
+func (r *memJobRepo) Watch(ctx context.Context, filter interface{}, opts *models.WatchOptions) (<-chan models.ChangeEvent[Job], error) {
+	if r.watchErr != nil {
+		return nil, r.watchErr
+	}
+	return r.events, nil
+}
+
+// remove deletes the job stored under name
+func (r *memJobRepo) remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = slices.DeleteFunc(r.jobs, func(job *Job) bool { return job.Name == name })
+}
+
+func (r *memJobRepo) get(name string) *Job {
+	job, _ := r.FindOne(context.Background(), map[string]interface{}{"name": name})
+	return job
+}
+
+func toDocument(job *Job) (bson.M, error) {
+	encoded, err := bson.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	return doc, bson.Unmarshal(encoded, &doc)
+}
+
+func roundTrip(job *Job) (*Job, error) {
+	encoded, err := bson.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	var stored Job
+	if err := bson.Unmarshal(encoded, &stored); err != nil {
+		return nil, err
+	}
+	stored.ID = job.ID
+	return &stored, nil
+}
+
+func applyUpdate(job *Job, fields map[string]interface{}) (*Job, error) {
+	doc, err := toDocument(job)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		doc[key] = value
+	}
+	encoded, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var updated Job
+	if err := bson.Unmarshal(encoded, &updated); err != nil {
+		return nil, err
+	}
+	updated.ID = job.ID
+	return &updated, nil
+}
+
+// memRunRepo keeps job runs in memory
+type memRunRepo struct {
+	repository.IRepository[JobRun]
+	mu   sync.Mutex
+	runs []*JobRun
+}
+
+func (r *memRunRepo) Create(ctx context.Context, data *JobRun) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *data
+	stored.ID = strconv.Itoa(len(r.runs) + 1)
+	r.runs = append(r.runs, &stored)
+	return stored.ID, nil
+}
+
+func (r *memRunRepo) Update(ctx context.Context, filter interface{}, update interface{}) (*models.UpdateResult, error) {
+	return &models.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+// count returns how many runs were recorded with status
+func (r *memRunRepo) count(status JobRunStatus) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, run := range r.runs {
+		if run.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// newTestScheduler returns a scheduler on an in-memory job repository, with its indexes considered
+// created
+func newTestScheduler(repo *memJobRepo) *Scheduler {
+	s := NewScheduler(SchedulerConfig{
+		Logger: logger.New(logger.DefaultConfig("scheduler")),
+	})
+	s.jobRepo = repo
+	s.runRepo = &memRunRepo{}
+	s.indexOnce.Do(func() {})
+	return s
+}
+
+func TestParamsEqual(t *testing.T) {
+	cases := []struct {
+		name     string
+		a, b     map[string]interface{}
+		expected bool
+	}{
+		{"both empty", nil, map[string]interface{}{}, true},
+		{"numeric type decoded from MongoDB", map[string]interface{}{"limit": 10}, map[string]interface{}{"limit": int32(10)}, true},
+		{"nested documents in another order", map[string]interface{}{
+			"filter": map[string]interface{}{"a": 1, "b": 2, "c": 3},
+		}, map[string]interface{}{
+			"filter": bson.D{{Key: "c", Value: 3}, {Key: "a", Value: 1}, {Key: "b", Value: 2}},
+		}, true},
+		{"different values", map[string]interface{}{"limit": 10}, map[string]interface{}{"limit": 20}, false},
+		{"missing key", map[string]interface{}{"limit": 10}, map[string]interface{}{}, false},
+	}
+
+	for _, tc := range cases {
+		if got := paramsEqual(tc.a, tc.b); got != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestUpsertJob_Reconciles(t *testing.T) {
+	ctx := context.Background()
+	repo := &memJobRepo{}
+	s := newTestScheduler(repo)
+
+	config := JobConfig{
+		Name:   "report",
+		RunAt:  time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Params: map[string]interface{}{"filter": map[string]interface{}{"a": 1, "b": 2, "c": 3}, "limit": 10},
+	}
+	if _, err := s.upsertJob(ctx, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Registering the same definition again, as on every boot, writes nothing
+	writes := repo.writes
+	if _, err := s.upsertJob(ctx, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.writes != writes {
+		t.Errorf("expected an unchanged definition not to be written, got %d writes", repo.writes-writes)
+	}
+
+	config.Handler = "reports"
+	config.Params = map[string]interface{}{"limit": 20}
+	job, err := s.upsertJob(ctx, config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Handler != "reports" || !paramsEqual(job.Params, config.Params) {
+		t.Errorf("expected the updated job to be returned, got handler %q and params %v", job.Handler, job.Params)
+	}
+	if stored := repo.get("report"); stored.Handler != "reports" {
+		t.Errorf("expected the handler to be reconciled, got %q", stored.Handler)
+	}
+}
@@ -0,0 +1,372 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultStaleStepAfter is how long a step without a timeout may stay claimed by another instance
+// before it is considered abandoned when workflows are resumed
+const DefaultStaleStepAfter = time.Hour
+
+// armStep runs a step of a workflow run at the given time
+func (s *Scheduler) armStep(runID, stepName string, at time.Time) {
+	key := runID + "/" + stepName
+
+	s.timersMu.Lock()
+	defer s.timersMu.Unlock()
+
+	// Steps armed during shutdown stay scheduled in the database and are resumed on the next start
+	if s.timersStopped {
+		return
+	}
+	if timer, exists := s.stepTimers[key]; exists {
+		timer.Stop()
+	}
+
+	s.stepTimers[key] = time.AfterFunc(time.Until(at), func() {
+		s.timersMu.Lock()
+		delete(s.stepTimers, key)
+		s.timersMu.Unlock()
+
+		s.executeStep(runID, stepName)
+	})
+}
+
+// stopStepTimers cancels every armed step, their state is kept in the database
+func (s *Scheduler) stopStepTimers() {
+	s.timersMu.Lock()
+	defer s.timersMu.Unlock()
+
+	s.timersStopped = true
+	for key, timer := range s.stepTimers {
+		timer.Stop()
+		delete(s.stepTimers, key)
+	}
+}
+
+// executeStep claims a scheduled step, runs a single attempt of it and records the outcome
+func (s *Scheduler) executeStep(runID, stepName string) {
+	execCtx, finish, err := s.beginExecution(runID)
+	if err != nil {
+		s.logger.Warn("Skipping workflow step", "run", runID, "step", stepName, "error", err)
+		return
+	}
+	defer finish()
+
+	// Bookkeeping must still be written when the execution is cancelled
+	ctx := context.WithoutCancel(execCtx)
+
+	run, err := s.workflowRepo.FindByID(ctx, runID)
+	if err != nil {
+		s.logger.Error("Failed to load workflow run", "run", runID, "error", err)
+		return
+	}
+
+	state := run.Steps[stepName]
+	if run.Status != WorkflowStatusRunning || state == nil || state.Status != StepStatusScheduled {
+		return
+	}
+
+	s.mu.RLock()
+	registered, exists := s.workflows[run.Workflow]
+	s.mu.RUnlock()
+	if !exists || registered.steps[stepName] == nil {
+		s.logger.Warn("Workflow step is not registered in this process", "workflow", run.Workflow, "step", stepName, "run", runID)
+		return
+	}
+	step := registered.steps[stepName]
+
+	// Claim the step so it is not run twice by a concurrent trigger or another instance
+	now := time.Now()
+	if state.StartedAt.IsZero() {
+		state.StartedAt = now
+	}
+	prefix := "steps." + stepName + "."
+	result, err := s.workflowRepo.Update(ctx, map[string]interface{}{
+		"_id":             runID,
+		prefix + "status": StepStatusScheduled,
+	}, map[string]interface{}{
+		prefix + "status":     StepStatusRunning,
+		prefix + "instance":   s.instanceID,
+		prefix + "started_at": state.StartedAt,
+		prefix + "claimed_at": now,
+		"updated_at":          now,
+	})
+	if err != nil {
+		s.logger.Error("Failed to claim workflow step", "run", runID, "step", stepName, "error", err)
+		return
+	}
+	if result.MatchedCount == 0 {
+		return
+	}
+
+	s.logger.Info("Running workflow step", "workflow", run.Workflow, "step", stepName, "run", runID, "attempt", state.Attempt+1)
+
+	// The workflow run ID is the correlation ID of every step
+	stepCtx := context.WithValue(execCtx, correlationIDKey, runID)
+	job := &Job{Name: run.Workflow + "/" + stepName, Params: stepParams(run, step), Timeout: step.Timeout}
+
+	outputs := make(chan map[string]interface{}, 1)
+	err = s.runAttempt(stepCtx, job, func(ctx context.Context, params map[string]interface{}) error {
+		output, err := step.Run(ctx, params)
+		outputs <- output
+		return err
+	})
+
+	var output map[string]interface{}
+	if err == nil {
+		output = <-outputs
+	}
+	s.finishStep(ctx, run, registered, step, state, output, err)
+}
+
+// finishStep records the outcome of a step attempt: completed steps schedule their successors,
+// failed ones are retried according to the step's retry policy before failing the workflow
+func (s *Scheduler) finishStep(ctx context.Context, run *WorkflowRun, registered *registeredWorkflow, step *WorkflowStep, state *StepState, output map[string]interface{}, err error) {
+	prefix := "steps." + step.Name + "."
+	now := time.Now()
+
+	var notReady *NotReadyError
+	switch {
+	case err == nil:
+		s.updateWorkflowRun(ctx, run.ID, map[string]interface{}{
+			prefix + "status":      StepStatusCompleted,
+			prefix + "output":      output,
+			prefix + "error":       "",
+			prefix + "finished_at": now,
+		})
+		s.logger.Info("Workflow step completed", "workflow", run.Workflow, "step", step.Name, "run", run.ID)
+		s.advanceWorkflow(ctx, run.ID, registered)
+		return
+
+	case errors.As(err, &notReady):
+		if step.MaxWait > 0 && now.Sub(state.StartedAt) >= step.MaxWait {
+			err = &SchedulerError{Code: ErrCodeJobTimeout, Message: fmt.Sprintf("step %s was not ready within %s", step.Name, step.MaxWait), Err: err}
+			break
+		}
+		s.rescheduleStep(ctx, run.ID, step.Name, now.Add(notReady.After), map[string]interface{}{
+			prefix + "polls": state.Polls + 1,
+			prefix + "error": notReady.Reason,
+		})
+		return
+
+	case HasErrorCode(err, ErrCodeJobCancelled):
+		// Interrupted by shutdown, the step runs again once the workflow is resumed
+		s.updateWorkflowRun(ctx, run.ID, map[string]interface{}{
+			prefix + "status": StepStatusScheduled,
+			prefix + "run_at": now,
+			prefix + "error":  err.Error(),
+		})
+		return
+
+	case state.Attempt < step.MaxRetries:
+		policy := DefaultRetryPolicy
+		if step.RetryPolicy != nil {
+			policy = *step.RetryPolicy
+		}
+		wait := policy.Backoff(state.Attempt + 1)
+
+		s.logger.Warn("Workflow step failed, retrying", "workflow", run.Workflow, "step", step.Name, "run", run.ID, "attempt", state.Attempt+1, "retryIn", wait.String(), "error", err)
+		s.rescheduleStep(ctx, run.ID, step.Name, now.Add(wait), map[string]interface{}{
+			prefix + "attempt": state.Attempt + 1,
+			prefix + "error":   err.Error(),
+		})
+		return
+	}
+
+	s.logger.Error("Workflow step failed, failing workflow", "workflow", run.Workflow, "step", step.Name, "run", run.ID, "error", err)
+	s.updateWorkflowRun(ctx, run.ID, map[string]interface{}{
+		prefix + "status":      StepStatusFailed,
+		prefix + "attempt":     state.Attempt + 1,
+		prefix + "error":       err.Error(),
+		prefix + "finished_at": now,
+		"status":               WorkflowStatusFailed,
+		"error":                "step " + step.Name + ": " + err.Error(),
+		"finished_at":          now,
+	})
+}
+
+// rescheduleStep persists a new run time for a step along with extra fields and arms it
+func (s *Scheduler) rescheduleStep(ctx context.Context, runID, stepName string, at time.Time, fields map[string]interface{}) {
+	prefix := "steps." + stepName + "."
+	fields[prefix+"status"] = StepStatusScheduled
+	fields[prefix+"run_at"] = at
+
+	if s.updateWorkflowRun(ctx, runID, fields) {
+		s.armStep(runID, stepName, at)
+	}
+}
+
+// advanceWorkflow schedules the pending steps whose dependencies have all completed and marks the
+// run completed once every step has
+func (s *Scheduler) advanceWorkflow(ctx context.Context, runID string, registered *registeredWorkflow) {
+	// Parallel branches finishing together must not both miss their common successor
+	s.workflowMu.Lock()
+	defer s.workflowMu.Unlock()
+
+	run, err := s.workflowRepo.FindByID(ctx, runID)
+	if err != nil {
+		s.logger.Error("Failed to load workflow run", "run", runID, "error", err)
+		return
+	}
+	if run.Status != WorkflowStatusRunning {
+		return
+	}
+
+	completed := true
+	for _, state := range run.Steps {
+		if state.Status != StepStatusCompleted {
+			completed = false
+			break
+		}
+	}
+	if completed {
+		now := time.Now()
+		_, err := s.workflowRepo.Update(ctx, map[string]interface{}{"_id": runID, "status": WorkflowStatusRunning}, map[string]interface{}{
+			"status":      WorkflowStatusCompleted,
+			"finished_at": now,
+			"updated_at":  now,
+		})
+		if err != nil {
+			s.logger.Error("Failed to complete workflow run", "run", runID, "error", err)
+			return
+		}
+		s.logger.Info("Workflow completed", "workflow", run.Workflow, "run", runID)
+		return
+	}
+
+	for stepName, state := range run.Steps {
+		step := registered.steps[stepName]
+		if state.Status != StepStatusPending || step == nil {
+			continue
+		}
+
+		ready := true
+		for _, dependency := range step.DependsOn {
+			if run.Steps[dependency] == nil || run.Steps[dependency].Status != StepStatusCompleted {
+				ready = false
+				break
+			}
+		}
+		if !ready {
+			continue
+		}
+
+		now := time.Now()
+		prefix := "steps." + stepName + "."
+		result, err := s.workflowRepo.Update(ctx, map[string]interface{}{"_id": runID, prefix + "status": StepStatusPending}, map[string]interface{}{
+			prefix + "status": StepStatusScheduled,
+			prefix + "run_at": now,
+			"updated_at":      now,
+		})
+		if err != nil {
+			s.logger.Error("Failed to schedule workflow step", "run", runID, "step", stepName, "error", err)
+			continue
+		}
+		if result.MatchedCount > 0 {
+			s.armStep(runID, stepName, now)
+		}
+	}
+}
+
+// updateWorkflowRun sets fields on a workflow run and reports whether it succeeded
+func (s *Scheduler) updateWorkflowRun(ctx context.Context, runID string, fields map[string]interface{}) bool {
+	fields["updated_at"] = time.Now()
+	if _, err := s.workflowRepo.Update(ctx, map[string]interface{}{"_id": runID}, fields); err != nil {
+		s.logger.Error("Failed to update workflow run", "run", runID, "error", err)
+		return false
+	}
+	return true
+}
+
+// resumeWorkflows re-arms the scheduled steps of running workflows and reschedules the steps
+// interrupted while this instance, or an instance that did not come back, was running them
+func (s *Scheduler) resumeWorkflows(ctx context.Context) {
+	if s.workflowRepo == nil {
+		return
+	}
+
+	runs, err := s.workflowRepo.Find(ctx, map[string]interface{}{"status": WorkflowStatusRunning}, nil)
+	if err != nil {
+		s.logger.Error("Failed to load running workflows", "error", err)
+		return
+	}
+
+	for _, run := range runs {
+		s.mu.RLock()
+		registered, exists := s.workflows[run.Workflow]
+		s.mu.RUnlock()
+		if !exists {
+			s.logger.Warn("Running workflow is not registered in this process", "workflow", run.Workflow, "run", run.ID)
+			continue
+		}
+
+		for stepName, state := range run.Steps {
+			switch state.Status {
+			case StepStatusScheduled:
+				s.armStep(run.ID, stepName, state.RunAt)
+			case StepStatusRunning:
+				if !s.stepAbandoned(state, registered.steps[stepName]) {
+					continue
+				}
+
+				prefix := "steps." + stepName + "."
+				now := time.Now()
+				result, err := s.workflowRepo.Update(ctx, map[string]interface{}{
+					"_id":                 run.ID,
+					prefix + "status":     StepStatusRunning,
+					prefix + "claimed_at": state.ClaimedAt,
+				}, map[string]interface{}{
+					prefix + "status": StepStatusScheduled,
+					prefix + "run_at": now,
+					"updated_at":      now,
+				})
+				if err != nil || result.MatchedCount == 0 {
+					continue
+				}
+				s.logger.Warn("Resuming interrupted workflow step", "workflow", run.Workflow, "step", stepName, "run", run.ID, "instance", state.Instance)
+				s.armStep(run.ID, stepName, now)
+			}
+		}
+
+		// Steps completed right before a crash may not have scheduled their successors yet
+		s.advanceWorkflow(ctx, run.ID, registered)
+		s.logger.Info("Resumed workflow", "workflow", run.Workflow, "run", run.ID)
+	}
+}
+
+// stepAbandoned reports whether a running step was left behind by a stopped process
+func (s *Scheduler) stepAbandoned(state *StepState, step *WorkflowStep) bool {
+	if step == nil {
+		return false
+	}
+	if state.Instance == s.instanceID {
+		return true
+	}
+
+	staleAfter := DefaultStaleStepAfter
+	if step.Timeout > 0 {
+		staleAfter = step.Timeout
+	}
+	return time.Since(state.ClaimedAt) > staleAfter
+}
+
+// stepParams merges the workflow params with the outputs of the steps a step depends on,
+// later dependencies overriding earlier ones
+func stepParams(run *WorkflowRun, step *WorkflowStep) map[string]interface{} {
+	params := make(map[string]interface{}, len(run.Params))
+	for key, value := range run.Params {
+		params[key] = value
+	}
+	for _, dependency := range step.DependsOn {
+		if state := run.Steps[dependency]; state != nil {
+			for key, value := range state.Output {
+				params[key] = value
+			}
+		}
+	}
+	return params
+}
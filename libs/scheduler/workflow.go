@@ -0,0 +1,302 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StepFunc runs a workflow step. params holds the workflow params merged with the outputs of the
+// steps it depends on, the returned output is passed on as params to its successors.
+type StepFunc func(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error)
+
+// WorkflowStep is a single step of a workflow
+type WorkflowStep struct {
+	Name      string
+	Run       StepFunc
+	DependsOn []string
+
+	MaxRetries  int
+	RetryPolicy *RetryPolicy  // defaults to DefaultRetryPolicy
+	Timeout     time.Duration // bounds every attempt, zero means no timeout
+	// MaxWait bounds how long a step may keep asking to run again with RetryAfter, zero means no limit
+	MaxWait time.Duration
+}
+
+// Workflow is a declarative chain or DAG of steps. A step is scheduled once every step it depends
+// on has completed, steps without dependencies are scheduled when the workflow starts.
+type Workflow struct {
+	Name  string
+	Steps []WorkflowStep
+	// Exclusive allows a single running run of the workflow, across all scheduler instances
+	Exclusive bool
+}
+
+type WorkflowStatus string
+
+const (
+	WorkflowStatusRunning   WorkflowStatus = "running"
+	WorkflowStatusCompleted WorkflowStatus = "completed"
+	WorkflowStatusFailed    WorkflowStatus = "failed"
+)
+
+type StepStatus string
+
+const (
+	// StepStatusPending marks a step waiting for the steps it depends on
+	StepStatusPending   StepStatus = "pending"
+	StepStatusScheduled StepStatus = "scheduled"
+	StepStatusRunning   StepStatus = "running"
+	StepStatusCompleted StepStatus = "completed"
+	StepStatusFailed    StepStatus = "failed"
+)
+
+// StepState is the persisted progress of a step within a workflow run
+type StepState struct {
+	Status     StepStatus             `bson:"status" json:"status"`
+	Attempt    int                    `bson:"attempt" json:"attempt"` // failed attempts so far
+	Polls      int                    `bson:"polls" json:"polls"`     // times the step asked to run again with RetryAfter
+	RunAt      time.Time              `bson:"run_at,omitempty" json:"run_at,omitempty"`
+	Output     map[string]interface{} `bson:"output,omitempty" json:"output,omitempty"`
+	Error      string                 `bson:"error,omitempty" json:"error,omitempty"`
+	Instance   string                 `bson:"instance,omitempty" json:"instance,omitempty"`
+	StartedAt  time.Time              `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	ClaimedAt  time.Time              `bson:"claimed_at,omitempty" json:"claimed_at,omitempty"`
+	FinishedAt time.Time              `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// WorkflowRun is the persisted state of one execution of a workflow
+type WorkflowRun struct {
+	ID         string                 `bson:"_id,omitempty" json:"id,omitempty"`
+	Workflow   string                 `bson:"workflow" json:"workflow"`
+	Status     WorkflowStatus         `bson:"status" json:"status"`
+	Params     map[string]interface{} `bson:"params" json:"params"`
+	Steps      map[string]*StepState  `bson:"steps" json:"steps"`
+	Error      string                 `bson:"error,omitempty" json:"error,omitempty"`
+	Exclusive  bool                   `bson:"exclusive,omitempty" json:"exclusive,omitempty"`
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time              `bson:"updated_at" json:"updated_at"`
+	FinishedAt time.Time              `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// NotReadyError asks the workflow engine to run a step again after a delay without counting a
+// failed attempt, typically while polling an external system
+type NotReadyError struct {
+	After  time.Duration
+	Reason string
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("not ready, retrying in %s: %s", e.After, e.Reason)
+}
+
+// RetryAfter returns a NotReadyError, steps return it to be run again after the given delay
+func RetryAfter(after time.Duration, reason string) error {
+	return &NotReadyError{After: after, Reason: reason}
+}
+
+// registeredWorkflow is a workflow registered by code in this process
+type registeredWorkflow struct {
+	workflow Workflow
+	steps    map[string]*WorkflowStep
+}
+
+// RegisterWorkflow makes a workflow available to StartWorkflow and to the runs resumed on Start.
+// Registering a name twice replaces the previous definition.
+func (s *Scheduler) RegisterWorkflow(workflow Workflow) error {
+	if s.workflowRepo == nil {
+		return &SchedulerError{Code: ErrCodeInvalidConfig, Message: "workflows require a WorkflowCollection"}
+	}
+
+	steps, err := validateWorkflow(workflow)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.workflows[workflow.Name] = &registeredWorkflow{workflow: workflow, steps: steps}
+	s.mu.Unlock()
+
+	s.logger.Info("Registered workflow", "workflow", workflow.Name, "steps", len(workflow.Steps))
+	return nil
+}
+
+// validateWorkflow checks step names and dependencies and rejects cycles
+func validateWorkflow(workflow Workflow) (map[string]*WorkflowStep, error) {
+	invalid := func(message string) error {
+		return &SchedulerError{Code: ErrCodeInvalidConfig, Message: "workflow " + workflow.Name + ": " + message}
+	}
+
+	if workflow.Name == "" {
+		return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "workflow name is required"}
+	}
+	if len(workflow.Steps) == 0 {
+		return nil, invalid("at least one step is required")
+	}
+
+	steps := make(map[string]*WorkflowStep, len(workflow.Steps))
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		// Step names are used as keys of the persisted steps document
+		if step.Name == "" || strings.ContainsAny(step.Name, ".$") {
+			return nil, invalid(fmt.Sprintf("invalid step name %q", step.Name))
+		}
+		if step.Run == nil {
+			return nil, invalid("step " + step.Name + " has no function")
+		}
+		if _, exists := steps[step.Name]; exists {
+			return nil, invalid("duplicate step " + step.Name)
+		}
+		steps[step.Name] = step
+	}
+
+	for _, step := range steps {
+		for _, dependency := range step.DependsOn {
+			if _, exists := steps[dependency]; !exists {
+				return nil, invalid("step " + step.Name + " depends on unknown step " + dependency)
+			}
+		}
+	}
+
+	// Kahn's algorithm: every step must eventually have all its dependencies resolved
+	remaining := make(map[string]int, len(steps))
+	for name, step := range steps {
+		remaining[name] = len(step.DependsOn)
+	}
+	for resolved := true; resolved; {
+		resolved = false
+		for name, count := range remaining {
+			if count > 0 {
+				continue
+			}
+			delete(remaining, name)
+			resolved = true
+			for other, step := range steps {
+				for _, dependency := range step.DependsOn {
+					if _, pending := remaining[other]; pending && dependency == name {
+						remaining[other]--
+					}
+				}
+			}
+		}
+	}
+	if len(remaining) > 0 {
+		return nil, invalid("steps form a cycle")
+	}
+
+	return steps, nil
+}
+
+// StartWorkflow persists a new run of the named workflow and schedules the steps without dependencies
+func (s *Scheduler) StartWorkflow(ctx context.Context, name string, params map[string]interface{}) (*WorkflowRun, error) {
+	s.mu.RLock()
+	registered, exists := s.workflows[name]
+	stopping := s.stopping
+	s.mu.RUnlock()
+
+	if !exists {
+		return nil, &SchedulerError{Code: ErrCodeWorkflowNotFound, Message: "workflow " + name + " is not registered"}
+	}
+	if stopping {
+		return nil, &SchedulerError{Code: ErrCodeShutdown, Message: "scheduler is shutting down"}
+	}
+
+	s.ensureIndexes(ctx)
+
+	now := time.Now()
+	run := &WorkflowRun{
+		Workflow:  name,
+		Status:    WorkflowStatusRunning,
+		Params:    params,
+		Steps:     make(map[string]*StepState, len(registered.steps)),
+		Exclusive: registered.workflow.Exclusive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if run.Params == nil {
+		run.Params = map[string]interface{}{}
+	}
+
+	for stepName, step := range registered.steps {
+		state := &StepState{Status: StepStatusPending}
+		if len(step.DependsOn) == 0 {
+			state.Status = StepStatusScheduled
+			state.RunAt = now
+		}
+		run.Steps[stepName] = state
+	}
+
+	id, err := s.workflowRepo.Create(ctx, run)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, &SchedulerError{Code: ErrCodeWorkflowRunning, Message: "workflow " + name + " is already running", Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.ID = id
+
+	s.logger.Info("Started workflow", "workflow", name, "run", id)
+	for stepName, state := range run.Steps {
+		if state.Status == StepStatusScheduled {
+			s.armStep(id, stepName, now)
+		}
+	}
+	return run, nil
+}
+
+// GetWorkflowRun fetches a single workflow run by its ID
+func (s *Scheduler) GetWorkflowRun(ctx context.Context, runID string) (*WorkflowRun, error) {
+	if s.workflowRepo == nil {
+		return nil, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "workflows require a WorkflowCollection"}
+	}
+
+	run, err := s.workflowRepo.FindByID(ctx, runID)
+	if err == mongo_errors.ErrDocumentNotFound {
+		return nil, &SchedulerError{Code: ErrCodeWorkflowNotFound, Message: "workflow run " + runID + " not found", Err: err}
+	}
+	return run, err
+}
+
+// GetWorkflowRuns returns the runs of a workflow, newest first, along with their total number.
+// An empty workflow or status matches every run.
+func (s *Scheduler) GetWorkflowRuns(ctx context.Context, workflow string, status WorkflowStatus, pagination models.PaginationOptions) ([]*WorkflowRun, int64, error) {
+	if s.workflowRepo == nil {
+		return nil, 0, &SchedulerError{Code: ErrCodeInvalidConfig, Message: "workflows require a WorkflowCollection"}
+	}
+
+	filter := map[string]interface{}{}
+	if workflow != "" {
+		filter["workflow"] = workflow
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	if pagination.PageSize < 1 {
+		pagination.PageSize = 20
+	}
+
+	runs, err := s.workflowRepo.Find(ctx, filter, &models.FindOptions{
+		Sort:  map[string]interface{}{"created_at": -1},
+		Limit: pagination.PageSize,
+		Skip:  (pagination.Page - 1) * pagination.PageSize,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.workflowRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
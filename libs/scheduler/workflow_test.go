@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+)
+
+func noopStep(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func TestValidateWorkflow(t *testing.T) {
+	cases := []struct {
+		name  string
+		steps []WorkflowStep
+		valid bool
+	}{
+		{
+			name: "chain",
+			steps: []WorkflowStep{
+				{Name: "a", Run: noopStep},
+				{Name: "b", Run: noopStep, DependsOn: []string{"a"}},
+				{Name: "c", Run: noopStep, DependsOn: []string{"b"}},
+			},
+			valid: true,
+		},
+		{
+			name: "diamond",
+			steps: []WorkflowStep{
+				{Name: "a", Run: noopStep},
+				{Name: "b", Run: noopStep, DependsOn: []string{"a"}},
+				{Name: "c", Run: noopStep, DependsOn: []string{"a"}},
+				{Name: "d", Run: noopStep, DependsOn: []string{"b", "c"}},
+			},
+			valid: true,
+		},
+		{
+			name: "cycle",
+			steps: []WorkflowStep{
+				{Name: "a", Run: noopStep, DependsOn: []string{"c"}},
+				{Name: "b", Run: noopStep, DependsOn: []string{"a"}},
+				{Name: "c", Run: noopStep, DependsOn: []string{"b"}},
+			},
+		},
+		{
+			name:  "unknown dependency",
+			steps: []WorkflowStep{{Name: "a", Run: noopStep, DependsOn: []string{"missing"}}},
+		},
+		{
+			name:  "duplicate step",
+			steps: []WorkflowStep{{Name: "a", Run: noopStep}, {Name: "a", Run: noopStep}},
+		},
+		{
+			name:  "dotted step name",
+			steps: []WorkflowStep{{Name: "a.b", Run: noopStep}},
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := validateWorkflow(Workflow{Name: tc.name, Steps: tc.steps})
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && !HasErrorCode(err, ErrCodeInvalidConfig) {
+			t.Errorf("%s: expected an invalid config error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestStepParams_MergesDependencyOutputs(t *testing.T) {
+	run := &WorkflowRun{
+		Params: map[string]interface{}{"source": "unicommerce", "job_code": "stale"},
+		Steps: map[string]*StepState{
+			"create": {Status: StepStatusCompleted, Output: map[string]interface{}{"job_code": "JC-1"}},
+		},
+	}
+	step := &WorkflowStep{Name: "await", DependsOn: []string{"create"}}
+
+	params := stepParams(run, step)
+	if params["job_code"] != "JC-1" || params["source"] != "unicommerce" {
+		t.Errorf("unexpected params: %v", params)
+	}
+	if run.Params["job_code"] != "stale" {
+		t.Errorf("workflow params must not be modified")
+	}
+}
@@ -0,0 +1,78 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+)
+
+// CancelTask cancels a pending, waiting or running task and records it as cancelled, along with the
+// children of a parent task. A task running on another instance is cancelled by that instance on
+// its next heartbeat.
+func (tm *TaskManager) CancelTask(id string) error {
+	ctx := context.Background()
+
+	task, err := tm.TaskRepo.FindByID(ctx, id)
+	if err != nil {
+		if err == mongo_errors.ErrDocumentNotFound {
+			return ErrTaskNotFound
+		}
+		tm.Logger.Error("Error fetching task by ID", "error", err)
+		return err
+	}
+
+	switch task.Status {
+	case TaskStatusPending, TaskStatusWaiting:
+		result, err := tm.TaskRepo.Update(ctx, map[string]interface{}{"_id": id, "status": task.Status}, map[string]interface{}{
+			"status":      TaskStatusCancelled,
+			"error":       ErrTaskCancelled.Error(),
+			"active_key":  nil,
+			"updated_at":  time.Now(),
+			"finished_at": time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount > 0 {
+			tm.mu.Lock()
+			delete(tm.funcs, id)
+			tm.mu.Unlock()
+
+			if task.Children > 0 {
+				tm.cancelChildren(ctx, id)
+			}
+			if task.ParentID != "" {
+				tm.childFinished(ctx, task)
+			}
+
+			tm.Logger.Info("Cancelled pending task", "task", id)
+			return nil
+		}
+		// A worker claimed the task in the meantime, cancel it as a running task
+	case TaskStatusRunning:
+	default:
+		return ErrTaskFinished
+	}
+
+	tm.mu.Lock()
+	cancel, local := tm.running[id]
+	tm.mu.Unlock()
+
+	if local {
+		cancel(ErrTaskCancelled)
+		tm.Logger.Info("Cancelled running task", "task", id)
+		return nil
+	}
+
+	_, err = tm.TaskRepo.Update(ctx, map[string]interface{}{"_id": id, "status": TaskStatusRunning}, map[string]interface{}{
+		"cancel_requested": true,
+		"updated_at":       time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	tm.Logger.Info("Requested cancellation of task running on another instance", "task", id)
+	return nil
+}
@@ -0,0 +1,368 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FanOutSpec describes a parent task split into child tasks
+type FanOutSpec struct {
+	// ChildType is the registered type of the children
+	ChildType string
+	// Children holds the params of every child
+	Children []interface{}
+	// Parallelism bounds how many children are pending or running at once, zero releases them all
+	Parallelism int
+	// FanIn is the registered type of the parent, it runs once every child completed and reads
+	// their results with Children
+	FanIn string
+	// Params are the params of the fan-in
+	Params interface{}
+}
+
+// FanOut queues a parent task waiting on one child task per entry of spec.Children. The parent
+// status rolls up from its children: it fails as soon as they all finished and one of them did not
+// complete, otherwise its fan-in runs. opts apply to the parent, children use the defaults of
+// their type.
+func (tm *TaskManager) FanOut(ctx context.Context, spec FanOutSpec, opts ...TaskOption) (string, error) {
+	childType, ok := lookupType(spec.ChildType)
+	if !ok {
+		return "", fmt.Errorf("task type %s is not registered", spec.ChildType)
+	}
+	fanIn, ok := lookupType(spec.FanIn)
+	if !ok {
+		return "", fmt.Errorf("task type %s is not registered", spec.FanIn)
+	}
+
+	parent, err := newTask(spec.FanIn, spec.Params, append(append([]TaskOption{}, fanIn.defaults...), opts...))
+	if err != nil {
+		return "", err
+	}
+	parent.Status = TaskStatusWaiting
+	parent.Children = len(spec.Children)
+	parent.Parallelism = spec.parallelism()
+	if parent.Children == 0 {
+		parent.Status = TaskStatusPending
+	}
+
+	parentID, created, err := tm.enqueue(parent, nil)
+	if err != nil || !created || parent.Children == 0 {
+		return parentID, err
+	}
+
+	// Every child is stored waiting before any is released, so a child finishing early always
+	// finds the others to release in its place
+	children, err := newChildren(spec, parentID, childType.defaults)
+	if err == nil {
+		bulk := repository.NewBulkWrite[Task]()
+		for _, child := range children {
+			bulk.Insert(child)
+		}
+		_, err = tm.TaskRepo.BulkWrite(ctx, bulk)
+	}
+	if err != nil {
+		tm.Logger.Error("Error creating child tasks", "parent", parentID, "error", err)
+		tm.cancelChildren(ctx, parentID)
+		tm.failTask(ctx, parentID, fmt.Sprintf("creating children failed: %v", err))
+		return "", err
+	}
+
+	parallelism := parent.Parallelism
+	if err := tm.releaseChildren(ctx, parentID, parallelism); err != nil {
+		tm.Logger.Error("Error releasing child tasks", "parent", parentID, "error", err)
+		tm.cancelChildren(ctx, parentID)
+		tm.failTask(ctx, parentID, fmt.Sprintf("releasing children failed: %v", err))
+		return "", err
+	}
+
+	tm.notify()
+	tm.Logger.Info("Fanned out task", "parent", parentID, "children", len(spec.Children), "parallelism", parallelism)
+	return parentID, nil
+}
+
+// parallelism returns how many children of the spec are pending or running at once
+func (spec FanOutSpec) parallelism() int {
+	if spec.Parallelism <= 0 || spec.Parallelism > len(spec.Children) {
+		return len(spec.Children)
+	}
+	return spec.Parallelism
+}
+
+// newChildren builds the child tasks of a fan-out, all waiting to be released
+func newChildren(spec FanOutSpec, parentID string, defaults []TaskOption) ([]*Task, error) {
+	children := make([]*Task, 0, len(spec.Children))
+	for i, params := range spec.Children {
+		child, err := newTask(spec.ChildType, params, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("child %d: %w", i, err)
+		}
+		child.ParentID = parentID
+		child.Status = TaskStatusWaiting
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+// releaseChildren makes the n oldest waiting children of a parent pending
+func (tm *TaskManager) releaseChildren(ctx context.Context, parentID string, n int) error {
+	waiting, err := tm.TaskRepo.Find(ctx, map[string]interface{}{"parent_id": parentID, "status": TaskStatusWaiting}, &models.FindOptions{
+		Sort:       bson.D{{Key: "_id", Value: 1}},
+		Limit:      int64(n),
+		Projection: bson.M{"_id": 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(waiting))
+	for _, child := range waiting {
+		id, err := primitive.ObjectIDFromHex(child.ID)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	_, err = tm.TaskRepo.Update(ctx, map[string]interface{}{
+		"_id":    map[string]interface{}{"$in": ids},
+		"status": TaskStatusWaiting,
+	}, map[string]interface{}{
+		"status":     TaskStatusPending,
+		"run_at":     time.Now(),
+		"updated_at": time.Now(),
+	})
+	return err
+}
+
+// rollUp returns the status of a waiting parent from the outcome of its children: it keeps waiting
+// until they all finished, then fails when one of them did not complete or becomes pending to run
+// its fan-in
+func rollUp(parent *Task) (TaskStatus, string) {
+	switch {
+	case parent.ChildrenDone < parent.Children:
+		return TaskStatusWaiting, ""
+	case parent.ChildrenFailed > 0:
+		return TaskStatusFailed, fmt.Sprintf("%d of %d children did not complete", parent.ChildrenFailed, parent.Children)
+	default:
+		return TaskStatusPending, ""
+	}
+}
+
+// GetChildren returns the children of a parent task, oldest first
+func (tm *TaskManager) GetChildren(ctx context.Context, parentID string) ([]*Task, error) {
+	return tm.TaskRepo.Find(ctx, map[string]interface{}{"parent_id": parentID}, &models.FindOptions{
+		Sort: bson.D{{Key: "_id", Value: 1}},
+	})
+}
+
+// Children returns the children of the task running with ctx, fan-in handlers use it to aggregate
+// the results of their children
+func Children(ctx context.Context) ([]*Task, error) {
+	reporter, ok := ctx.Value(taskKey{}).(*progressReporter)
+	if !ok {
+		return nil, errors.New("task: Children called outside of a task")
+	}
+	return reporter.tm.GetChildren(ctx, reporter.taskID)
+}
+
+// childCounts counts the children of a parent by outcome
+type childCounts struct {
+	// stored children, active ones are pending or running, done ones finished
+	stored, active, done int
+	// failed children finished without completing
+	failed int
+}
+
+// countChildren counts the children of a parent as stored, rolling up from these counts rather than
+// incrementing the parent makes rolling up again harmless
+func (tm *TaskManager) countChildren(ctx context.Context, parentID string) (childCounts, error) {
+	filters := []map[string]interface{}{
+		{"parent_id": parentID},
+		{"parent_id": parentID, "status": map[string]interface{}{"$in": []TaskStatus{TaskStatusPending, TaskStatusRunning}}},
+		{"parent_id": parentID, "status": map[string]interface{}{"$in": []TaskStatus{TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled}}},
+		{"parent_id": parentID, "status": map[string]interface{}{"$in": []TaskStatus{TaskStatusFailed, TaskStatusCancelled}}},
+	}
+
+	counts := make([]int, len(filters))
+	for i, filter := range filters {
+		count, err := tm.TaskRepo.Count(ctx, filter)
+		if err != nil {
+			return childCounts{}, err
+		}
+		counts[i] = int(count)
+	}
+	return childCounts{stored: counts[0], active: counts[1], done: counts[2], failed: counts[3]}, nil
+}
+
+// childFinished rolls the outcome of a finished child up to its parent: the next waiting child is
+// released, and once every child finished the parent either fails or becomes pending to run its
+// fan-in
+func (tm *TaskManager) childFinished(ctx context.Context, child *Task) {
+	counts, err := tm.countChildren(ctx, child.ParentID)
+	if err != nil {
+		tm.Logger.Error("Error rolling up child task", "parent", child.ParentID, "child", child.ID, "error", err)
+		return
+	}
+
+	// Keep the parallelism of the parent by releasing the next waiting child
+	_, err = tm.TaskRepo.FindOneAndUpdate(ctx, map[string]interface{}{"parent_id": child.ParentID, "status": TaskStatusWaiting}, map[string]interface{}{
+		"status":     TaskStatusPending,
+		"run_at":     time.Now(),
+		"updated_at": time.Now(),
+	}, &models.FindOneAndUpdateOptions{Sort: bson.D{{Key: "_id", Value: 1}}})
+	if err != nil && !errors.Is(err, mongo_errors.ErrDocumentNotFound) {
+		tm.Logger.Error("Error releasing child task", "parent", child.ParentID, "error", err)
+	}
+
+	tm.rollUpParent(ctx, child.ParentID, counts)
+}
+
+// rollUpParent records the finished children of a waiting parent and, once they all finished,
+// fails the parent or makes it pending to run its fan-in. Counts only move forward, an older count
+// rolled up after a newer one is ignored.
+func (tm *TaskManager) rollUpParent(ctx context.Context, parentID string, counts childCounts) {
+	id, err := primitive.ObjectIDFromHex(parentID)
+	if err != nil {
+		return
+	}
+
+	var parent Task
+	err = tm.collection.Collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": TaskStatusWaiting},
+		bson.M{
+			"$max": bson.M{"children_done": counts.done, "children_failed": counts.failed},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&parent)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			tm.Logger.Error("Error rolling up parent task", "parent", parentID, "error", err)
+		}
+		return
+	}
+
+	status, reason := rollUp(&parent)
+	if status == TaskStatusWaiting {
+		tm.notify()
+		return
+	}
+
+	if status == TaskStatusFailed {
+		if _, err := tm.failTasks(ctx, map[string]interface{}{"_id": parent.ID, "status": TaskStatusWaiting}, reason); err != nil {
+			tm.Logger.Error("Error failing parent task", "parent", parent.ID, "error", err)
+			return
+		}
+		tm.Logger.Warn("Parent task failed", "parent", parent.ID, "reason", reason)
+		return
+	}
+
+	_, err = tm.TaskRepo.Update(ctx, map[string]interface{}{"_id": parent.ID, "status": TaskStatusWaiting}, map[string]interface{}{
+		"status":     TaskStatusPending,
+		"run_at":     time.Now(),
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		tm.Logger.Error("Error releasing parent task", "parent", parent.ID, "error", err)
+		return
+	}
+	tm.notify()
+}
+
+// reconcile decides how to repair a waiting parent from the counts of its children: a fan-out
+// interrupted before storing every child cannot complete, otherwise release waiting children up to
+// the parallelism of the parent and roll up the children that finished since it was last updated
+func reconcile(parent *Task, counts childCounts) (interrupted bool, release int, stale bool) {
+	if counts.stored < parent.Children {
+		return true, 0, false
+	}
+
+	parallelism := parent.Parallelism
+	if parallelism <= 0 {
+		parallelism = parent.Children
+	}
+	waiting := counts.stored - counts.active - counts.done
+	release = min(parallelism-counts.active, waiting)
+	if release < 0 {
+		release = 0
+	}
+
+	stale = counts.done > parent.ChildrenDone || counts.failed > parent.ChildrenFailed || counts.done >= parent.Children
+	return false, release, stale
+}
+
+// reconcileParents repairs the waiting parents left behind by a process that stopped between
+// finishing a child and rolling it up, or in the middle of a fan-out. Parents updated within the
+// visibility timeout may still be handled by a live process and are left alone.
+func (tm *TaskManager) reconcileParents(ctx context.Context) (int64, error) {
+	parents, err := tm.TaskRepo.Find(ctx, map[string]interface{}{
+		"status":     TaskStatusWaiting,
+		"children":   map[string]interface{}{"$gt": 0},
+		"updated_at": map[string]interface{}{"$lt": time.Now().Add(-tm.visibilityTimeout)},
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var repaired int64
+	for _, parent := range parents {
+		counts, err := tm.countChildren(ctx, parent.ID)
+		if err != nil {
+			return repaired, err
+		}
+
+		interrupted, release, stale := reconcile(parent, counts)
+		switch {
+		case interrupted:
+			tm.cancelChildren(ctx, parent.ID)
+			tm.failTask(ctx, parent.ID, "fanning out was interrupted before every child was created")
+		case release > 0 || stale:
+			if release > 0 {
+				if err := tm.releaseChildren(ctx, parent.ID, release); err != nil {
+					return repaired, err
+				}
+			}
+			if stale {
+				tm.rollUpParent(ctx, parent.ID, counts)
+			}
+		default:
+			continue
+		}
+		repaired++
+	}
+	return repaired, nil
+}
+
+// cancelChildren cancels the children of a cancelled parent that did not finish yet
+func (tm *TaskManager) cancelChildren(ctx context.Context, parentID string) {
+	_, err := tm.TaskRepo.Update(ctx, map[string]interface{}{
+		"parent_id": parentID,
+		"status":    map[string]interface{}{"$in": []TaskStatus{TaskStatusPending, TaskStatusWaiting}},
+	}, map[string]interface{}{
+		"status":      TaskStatusCancelled,
+		"error":       "parent task was cancelled",
+		"active_key":  nil,
+		"updated_at":  time.Now(),
+		"finished_at": time.Now(),
+	})
+	if err != nil {
+		tm.Logger.Error("Error cancelling child tasks", "parent", parentID, "error", err)
+	}
+
+	_, err = tm.TaskRepo.Update(ctx, map[string]interface{}{"parent_id": parentID, "status": TaskStatusRunning}, map[string]interface{}{
+		"cancel_requested": true,
+	})
+	if err != nil {
+		tm.Logger.Error("Error cancelling running child tasks", "parent", parentID, "error", err)
+	}
+}
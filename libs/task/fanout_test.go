@@ -0,0 +1,102 @@
+package task
+
+import (
+	"testing"
+)
+
+func TestRollUp(t *testing.T) {
+	cases := []struct {
+		name     string
+		parent   Task
+		expected TaskStatus
+	}{
+		{"children still running", Task{Children: 3, ChildrenDone: 2}, TaskStatusWaiting},
+		{"failure before the last child", Task{Children: 3, ChildrenDone: 1, ChildrenFailed: 1}, TaskStatusWaiting},
+		{"every child completed", Task{Children: 3, ChildrenDone: 3}, TaskStatusPending},
+		{"a child did not complete", Task{Children: 3, ChildrenDone: 3, ChildrenFailed: 1}, TaskStatusFailed},
+	}
+
+	for _, tc := range cases {
+		status, reason := rollUp(&tc.parent)
+		if status != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, status)
+		}
+		if (status == TaskStatusFailed) != (reason != "") {
+			t.Errorf("%s: unexpected reason %q for status %s", tc.name, reason, status)
+		}
+	}
+}
+
+func TestFanOutSpecParallelism(t *testing.T) {
+	children := []interface{}{1, 2, 3, 4}
+
+	cases := []struct {
+		name        string
+		parallelism int
+		expected    int
+	}{
+		{"unbounded", 0, 4},
+		{"negative", -1, 4},
+		{"bounded", 2, 2},
+		{"above the number of children", 10, 4},
+	}
+
+	for _, tc := range cases {
+		spec := FanOutSpec{Children: children, Parallelism: tc.parallelism}
+		if got := spec.parallelism(); got != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestNewChildren_AllWaiting(t *testing.T) {
+	spec := FanOutSpec{ChildType: "child", Children: []interface{}{map[string]int{"page": 1}, map[string]int{"page": 2}}}
+
+	children, err := newChildren(spec, "parent", []TaskOption{WithMaxRetries(2, nil)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children, got %d", len(children))
+	}
+
+	for i, child := range children {
+		if child.Status != TaskStatusWaiting || child.ParentID != "parent" || child.TaskType != "child" || child.MaxRetries != 2 {
+			t.Errorf("child %d: unexpected child %+v", i, child)
+		}
+	}
+	if children[0].Params != `{"page":1}` || children[1].Params != `{"page":2}` {
+		t.Errorf("expected children params in order, got %s and %s", children[0].Params, children[1].Params)
+	}
+
+	if _, err := newChildren(FanOutSpec{ChildType: "child", Children: []interface{}{make(chan int)}}, "parent", nil); err == nil {
+		t.Error("expected an error for params that cannot be serialized")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name        string
+		parent      Task
+		counts      childCounts
+		interrupted bool
+		release     int
+		stale       bool
+	}{
+		{"fan-out stopped creating children", Task{Children: 3}, childCounts{stored: 1}, true, 0, false},
+		{"fan-out stopped releasing children", Task{Children: 3, Parallelism: 2}, childCounts{stored: 3}, false, 2, false},
+		{"parallelism of a legacy parent", Task{Children: 3}, childCounts{stored: 3}, false, 3, false},
+		{"release bounded by the waiting children", Task{Children: 3, Parallelism: 2}, childCounts{stored: 3, done: 2}, false, 1, true},
+		{"children running", Task{Children: 3, Parallelism: 2}, childCounts{stored: 3, active: 2}, false, 0, false},
+		{"child finished without a roll up", Task{Children: 3, Parallelism: 3, ChildrenDone: 1}, childCounts{stored: 3, active: 1, done: 2}, false, 0, true},
+		{"failed child without a roll up", Task{Children: 3, Parallelism: 3, ChildrenDone: 2}, childCounts{stored: 3, active: 1, done: 2, failed: 1}, false, 0, true},
+		{"every child finished", Task{Children: 3, ChildrenDone: 3}, childCounts{stored: 3, done: 3}, false, 0, true},
+	}
+
+	for _, tc := range cases {
+		interrupted, release, stale := reconcile(&tc.parent, tc.counts)
+		if interrupted != tc.interrupted || release != tc.release || stale != tc.stale {
+			t.Errorf("%s: expected (%t, %d, %t), got (%t, %d, %t)", tc.name, tc.interrupted, tc.release, tc.stale, interrupted, release, stale)
+		}
+	}
+}
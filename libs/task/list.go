@@ -0,0 +1,105 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// TaskFilter selects the tasks returned by ListTasks, zero fields match every task
+type TaskFilter struct {
+	TaskType      string
+	Status        TaskStatus
+	ParentID      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// ListTasks returns the tasks matching filter, newest first. cursor is the next cursor returned by
+// the previous page, empty for the first page. The returned cursor is empty on the last page.
+func (tm *TaskManager) ListTasks(ctx context.Context, filter TaskFilter, cursor string, limit int64) ([]*Task, string, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	query := map[string]interface{}{}
+	if filter.TaskType != "" {
+		query["task_type"] = filter.TaskType
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.ParentID != "" {
+		query["parent_id"] = filter.ParentID
+	}
+
+	createdAt := map[string]interface{}{}
+	if !filter.CreatedAfter.IsZero() {
+		createdAt["$gte"] = filter.CreatedAfter
+	}
+	if !filter.CreatedBefore.IsZero() {
+		createdAt["$lt"] = filter.CreatedBefore
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	if cursor != "" {
+		after, err := primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		query["_id"] = map[string]interface{}{"$lt": after}
+	}
+
+	// One extra task tells whether there is a next page
+	tasks, err := tm.TaskRepo.Find(ctx, query, &models.FindOptions{
+		Sort:  bson.D{{Key: "_id", Value: -1}},
+		Limit: limit + 1,
+	})
+	if err != nil {
+		tm.Logger.Error("Error listing tasks", "error", err)
+		return nil, "", err
+	}
+
+	next := ""
+	if int64(len(tasks)) > limit {
+		tasks = tasks[:limit]
+		next = tasks[len(tasks)-1].ID
+	}
+	return tasks, next, nil
+}
+
+// ensureRetention creates the TTL index deleting finished tasks, or updates its expiry when the
+// retention changed since it was created
+func (tm *TaskManager) ensureRetention(ctx context.Context) error {
+	keys := bson.D{{Key: "finished_at", Value: 1}}
+	seconds := int32(tm.retention / time.Second)
+
+	index := mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	}
+	_, err := tm.collection.Collection.Indexes().CreateOne(ctx, index)
+	if err == nil {
+		return nil
+	}
+
+	return tm.collection.Collection.Database().RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: tm.collection.Collection.Name()},
+		{Key: "index", Value: bson.D{{Key: "keyPattern", Value: keys}, {Key: "expireAfterSeconds", Value: seconds}}},
+	}).Err()
+}
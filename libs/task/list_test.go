@@ -0,0 +1,84 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// listRepo serves Find from a fixed list of tasks, newest first, and records the query it was given
+type listRepo struct {
+	repository.IRepository[Task]
+	tasks []*Task
+	query map[string]interface{}
+	limit int64
+}
+
+func (r *listRepo) Find(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*Task, error) {
+	r.query = filter.(map[string]interface{})
+	r.limit = opts[0].Limit
+	if int64(len(r.tasks)) > r.limit {
+		return r.tasks[:r.limit], nil
+	}
+	return r.tasks, nil
+}
+
+func TestListTasks_Cursor(t *testing.T) {
+	tasks := make([]*Task, 5)
+	for i := range tasks {
+		tasks[i] = &Task{ID: primitive.NewObjectID().Hex()}
+	}
+
+	cases := []struct {
+		name          string
+		limit         int64
+		expectedLen   int
+		expectedLimit int64
+		expectedNext  string
+	}{
+		{"next page", 3, 3, 4, tasks[2].ID},
+		{"last page", 5, 5, 6, ""},
+		{"default limit", 0, 5, DefaultListLimit + 1, ""},
+		{"limit above the maximum", 1000, 5, MaxListLimit + 1, ""},
+	}
+
+	for _, tc := range cases {
+		repo := &listRepo{tasks: tasks}
+		tm := &TaskManager{TaskRepo: repo}
+
+		page, next, err := tm.ListTasks(context.Background(), TaskFilter{}, "", tc.limit)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if len(page) != tc.expectedLen || next != tc.expectedNext || repo.limit != tc.expectedLimit {
+			t.Errorf("%s: expected %d tasks, cursor %q and limit %d, got %d tasks, cursor %q and limit %d",
+				tc.name, tc.expectedLen, tc.expectedNext, tc.expectedLimit, len(page), next, repo.limit)
+		}
+	}
+}
+
+func TestListTasks_FiltersFromCursor(t *testing.T) {
+	after := primitive.NewObjectID()
+	repo := &listRepo{}
+	tm := &TaskManager{TaskRepo: repo}
+
+	if _, _, err := tm.ListTasks(context.Background(), TaskFilter{Status: TaskStatusFailed}, after.Hex(), 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	id, ok := repo.query["_id"].(map[string]interface{})
+	if !ok || id["$lt"] != after {
+		t.Errorf("expected tasks before %s, got %v", after.Hex(), repo.query["_id"])
+	}
+	if repo.query["status"] != TaskStatusFailed {
+		t.Errorf("expected the status filter to apply, got %v", repo.query["status"])
+	}
+
+	if _, _, err := tm.ListTasks(context.Background(), TaskFilter{}, "not-a-cursor", 10); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected an invalid cursor error, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package task
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrateLegacyTasks converts the tasks stored before timestamps were dates. Their created_at and
+// updated_at RFC3339 strings become dates, and finished ones get the finished_at that drives
+// retention. Migrated tasks no longer match, so running it again has no effect.
+func (tm *TaskManager) migrateLegacyTasks(ctx context.Context) {
+	toDate := func(field string) bson.M {
+		return bson.M{"$convert": bson.M{"input": field, "to": "date", "onError": "$$NOW", "onNull": "$$NOW"}}
+	}
+	finished := bson.A{TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"created_at": toDate("$created_at"),
+			"updated_at": toDate("$updated_at"),
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"finished_at": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$status", finished}},
+				bson.M{"$ifNull": bson.A{"$finished_at", "$updated_at"}},
+				"$$REMOVE",
+			}},
+		}}},
+	}
+
+	result, err := tm.collection.Collection.UpdateMany(ctx, bson.M{"created_at": bson.M{"$type": "string"}}, pipeline)
+	if err != nil {
+		tm.Logger.Error("Error migrating legacy tasks", "error", err)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		tm.Logger.Info("Migrated legacy tasks to date timestamps", "count", result.ModifiedCount)
+	}
+}
@@ -0,0 +1,132 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// ProgressInterval is the minimum time between two progress writes of a task, intermediate
+	// reports are coalesced and the latest one is written with the final status
+	ProgressInterval = time.Second
+	// watchInterval is how often WatchTask polls the task document
+	watchInterval = time.Second
+)
+
+// Progress is the latest progress reported by a running task
+type Progress struct {
+	Percent   float64   `bson:"percent" json:"percent"`
+	Step      string    `bson:"step,omitempty" json:"step,omitempty"`
+	Current   int64     `bson:"current,omitempty" json:"current,omitempty"`
+	Total     int64     `bson:"total,omitempty" json:"total,omitempty"`
+	Message   string    `bson:"message,omitempty" json:"message,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// taskKey holds the progressReporter of the running task in its context, it also identifies the task
+type taskKey struct{}
+
+// progressReporter persists the progress of a single task, throttled to ProgressInterval
+type progressReporter struct {
+	tm     *TaskManager
+	taskID string
+
+	mu      sync.Mutex
+	latest  *Progress
+	written time.Time
+	dirty   bool
+}
+
+// ReportProgress records the progress of the task running with ctx. Percent is derived from
+// Current and Total when left at zero. It does nothing outside of a task.
+func ReportProgress(ctx context.Context, progress Progress) {
+	reporter, ok := ctx.Value(taskKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+
+	if progress.Percent == 0 && progress.Total > 0 {
+		progress.Percent = float64(progress.Current) * 100 / float64(progress.Total)
+	}
+	progress.UpdatedAt = time.Now()
+
+	reporter.mu.Lock()
+	reporter.latest = &progress
+	if time.Since(reporter.written) < ProgressInterval {
+		reporter.dirty = true
+		reporter.mu.Unlock()
+		return
+	}
+	reporter.written = progress.UpdatedAt
+	reporter.dirty = false
+	reporter.mu.Unlock()
+
+	_, err := reporter.tm.TaskRepo.Update(context.WithoutCancel(ctx), map[string]interface{}{"_id": reporter.taskID, "status": TaskStatusRunning}, map[string]interface{}{
+		"progress":   progress,
+		"updated_at": progress.UpdatedAt,
+	})
+	if err != nil {
+		reporter.tm.Logger.Error("Error recording task progress", "task", reporter.taskID, "error", err)
+	}
+}
+
+// pending returns the latest progress that was not written yet
+func (r *progressReporter) pending() (*Progress, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.latest, r.dirty
+}
+
+// WatchTask polls a task and sends it on the returned channel whenever its status or progress
+// changes. The channel is closed once the task finishes or ctx is done.
+func (tm *TaskManager) WatchTask(ctx context.Context, id string) (<-chan *Task, error) {
+	task, err := tm.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan *Task, 1)
+	updates <- task
+
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		last := task
+		for !last.Status.Finished() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := tm.GetTaskByID(id)
+			if err != nil {
+				tm.Logger.Error("Error polling watched task", "task", id, "error", err)
+				continue
+			}
+			if current.Status == last.Status && sameProgress(current.Progress, last.Progress) {
+				continue
+			}
+
+			select {
+			case updates <- current:
+				last = current
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+func sameProgress(a, b *Progress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
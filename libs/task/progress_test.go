@@ -0,0 +1,93 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReportProgress_Throttles(t *testing.T) {
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+	id := storeTask(t, repo, Task{TaskType: "report", Status: TaskStatusRunning, ClaimedBy: "test"})
+
+	reporter := &progressReporter{tm: tm, taskID: id}
+	ctx := context.WithValue(context.Background(), taskKey{}, reporter)
+
+	ReportProgress(ctx, Progress{Current: 1, Total: 4})
+	if progress := repo.get(id).Progress; progress == nil || progress.Percent != 25 {
+		t.Fatalf("expected the first report to be written with its percent derived, got %+v", progress)
+	}
+
+	// Reports within ProgressInterval are coalesced, only the latest is kept
+	ReportProgress(ctx, Progress{Current: 2, Total: 4})
+	ReportProgress(ctx, Progress{Current: 3, Total: 4, Step: "export"})
+	if progress := repo.get(id).Progress; progress.Current != 1 {
+		t.Errorf("expected reports within the interval not to be written, got %+v", progress)
+	}
+	if progress, dirty := reporter.pending(); !dirty || progress.Current != 3 || progress.Step != "export" {
+		t.Errorf("expected the latest report to be pending, got %+v (dirty %t)", progress, dirty)
+	}
+
+	// The next report after the interval is written and nothing is pending anymore
+	reporter.mu.Lock()
+	reporter.written = time.Now().Add(-ProgressInterval)
+	reporter.mu.Unlock()
+	ReportProgress(ctx, Progress{Percent: 90})
+	if progress := repo.get(id).Progress; progress.Percent != 90 {
+		t.Errorf("expected the report after the interval to be written, got %+v", progress)
+	}
+	if _, dirty := reporter.pending(); dirty {
+		t.Errorf("expected nothing to be pending after a write")
+	}
+
+	// Outside of a task reports are ignored
+	ReportProgress(context.Background(), Progress{Percent: 100})
+}
+
+func TestReportProgress_CoalescedWrittenWithOutcome(t *testing.T) {
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+
+	id, err := tm.RunTask("report", nil, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		for i := int64(1); i <= 3; i++ {
+			ReportProgress(ctx, Progress{Current: i, Total: 3})
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !tm.runNext() {
+		t.Fatalf("expected the task to be claimed")
+	}
+
+	task := repo.get(id)
+	if task.Status != TaskStatusCompleted {
+		t.Errorf("expected the task to complete, got %s", task.Status)
+	}
+	if task.Progress == nil || task.Progress.Current != 3 || task.Progress.Percent != 100 {
+		t.Errorf("expected the last report to be written with the outcome, got %+v", task.Progress)
+	}
+}
+
+func TestSameProgress(t *testing.T) {
+	at := time.Now()
+	cases := []struct {
+		name     string
+		a, b     *Progress
+		expected bool
+	}{
+		{"both nil", nil, nil, true},
+		{"one nil", &Progress{}, nil, false},
+		{"equal", &Progress{Percent: 50, Step: "export", UpdatedAt: at}, &Progress{Percent: 50, Step: "export", UpdatedAt: at}, true},
+		{"different percent", &Progress{Percent: 50}, &Progress{Percent: 60}, false},
+		{"reported again", &Progress{Percent: 50, UpdatedAt: at}, &Progress{Percent: 50, UpdatedAt: at.Add(time.Second)}, false},
+	}
+
+	for _, tc := range cases {
+		if got := sameProgress(tc.a, tc.b); got != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.expected, got)
+		}
+	}
+}
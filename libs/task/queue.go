@@ -0,0 +1,578 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultWorkers           = 4
+	DefaultPollInterval      = 5 * time.Second
+	DefaultHeartbeatInterval = 10 * time.Second
+	DefaultVisibilityTimeout = time.Minute
+)
+
+// cancelGracePeriod is how long Shutdown waits for cancelled tasks to record their outcome
+const cancelGracePeriod = 5 * time.Second
+
+var (
+	// ErrShuttingDown is returned when a task is queued after Shutdown was called
+	ErrShuttingDown = errors.New("task manager is shutting down")
+	// ErrTaskNotFound is returned when cancelling a task that does not exist
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskFinished is returned when cancelling a task that already finished
+	ErrTaskFinished = errors.New("task already finished")
+	// ErrTaskCancelled is the cause of the context of a cancelled task
+	ErrTaskCancelled = errors.New("task was cancelled")
+	// ErrTaskTimeout is the cause of the context of a task that reached its deadline
+	ErrTaskTimeout = errors.New("task timed out")
+	// ErrInvalidCursor is returned by ListTasks for a cursor it did not return
+	ErrInvalidCursor = errors.New("invalid task cursor")
+)
+
+// Start migrates legacy task documents, recovers the tasks abandoned by a previous run of this
+// process and starts the worker pool. Calling Start again has no effect.
+func (tm *TaskManager) Start() {
+	tm.mu.Lock()
+	if tm.started {
+		tm.mu.Unlock()
+		return
+	}
+	tm.started = true
+	tm.mu.Unlock()
+
+	tm.migrateLegacyTasks(tm.baseCtx)
+	tm.ensureIndexes(tm.baseCtx)
+	tm.recoverAbandoned(tm.baseCtx)
+
+	for i := 0; i < tm.workers; i++ {
+		go tm.work()
+	}
+	go tm.reapExpired()
+
+	tm.Logger.Info("Task manager started", "workers", tm.workers, "instance", tm.instanceID)
+}
+
+// Shutdown stops claiming tasks and waits for the running ones to finish. When ctx is done first,
+// running tasks are cancelled and given cancelGracePeriod to record their outcome. Tasks still
+// pending are failed since their functions do not outlive this process.
+func (tm *TaskManager) Shutdown(ctx context.Context) error {
+	tm.mu.Lock()
+	tm.stopping = true
+	tm.mu.Unlock()
+	tm.stop()
+
+	drained := make(chan struct{})
+	go func() {
+		tm.inFlight.Wait()
+		close(drained)
+	}()
+
+	timedOut := false
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		timedOut = true
+		tm.mu.Lock()
+		for _, cancel := range tm.running {
+			cancel(ErrShuttingDown)
+		}
+		tm.mu.Unlock()
+
+		tm.Logger.Warn("Task manager shutdown deadline reached, cancelled running tasks")
+		select {
+		case <-drained:
+		case <-time.After(cancelGracePeriod):
+			tm.Logger.Warn("Cancelled tasks did not finish within the grace period", "gracePeriod", cancelGracePeriod)
+		}
+	}
+
+	// The deadline of ctx may have passed, pending tasks are failed regardless
+	failCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelGracePeriod)
+	defer cancel()
+	failed, err := tm.failTasks(failCtx, map[string]interface{}{
+		"owner":  tm.instanceID,
+		"status": TaskStatusPending,
+	}, "task was not started before shutdown")
+	if err != nil {
+		return err
+	}
+
+	if timedOut {
+		tm.Logger.Warn("Task manager shut down after its deadline", "failedPending", failed)
+		return ctx.Err()
+	}
+	tm.Logger.Info("Task manager shut down gracefully", "failedPending", failed)
+	return nil
+}
+
+// ensureIndexes creates the indexes used to claim tasks, to find expired leases and to enforce
+// idempotency keys
+func (tm *TaskManager) ensureIndexes(ctx context.Context) {
+	if err := tm.TaskRepo.CreateIndex(ctx, bson.D{{Key: "status", Value: 1}, {Key: "owner", Value: 1}}, false); err != nil {
+		tm.Logger.Error("Error creating index on task status", "error", err)
+	}
+	if err := tm.TaskRepo.CreateIndex(ctx, bson.D{{Key: "status", Value: 1}, {Key: "priority", Value: -1}, {Key: "run_at", Value: 1}}, false); err != nil {
+		tm.Logger.Error("Error creating index on task priority", "error", err)
+	}
+
+	// Only pending and running tasks hold a key, finished ones set it to null
+	activeKey := mongo.IndexModel{
+		Keys: bson.D{{Key: "active_key", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"active_key": bson.M{"$type": "string"}}),
+	}
+	if _, err := tm.collection.Collection.Indexes().CreateOne(ctx, activeKey); err != nil {
+		tm.Logger.Error("Error creating index on task idempotency keys", "error", err)
+	}
+
+	// Indexes backing ListTasks
+	if err := tm.TaskRepo.CreateIndex(ctx, bson.D{{Key: "task_type", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: -1}}, false); err != nil {
+		tm.Logger.Error("Error creating index on task types", "error", err)
+	}
+	if err := tm.TaskRepo.CreateIndex(ctx, bson.D{{Key: "created_at", Value: -1}}, false); err != nil {
+		tm.Logger.Error("Error creating index on task creation time", "error", err)
+	}
+
+	if tm.retention > 0 {
+		if err := tm.ensureRetention(ctx); err != nil {
+			tm.Logger.Error("Error applying task retention", "retention", tm.retention, "error", err)
+		}
+	}
+	if err := tm.TaskRepo.CreateIndex(ctx, bson.D{{Key: "status", Value: 1}, {Key: "lease_until", Value: 1}}, false); err != nil {
+		tm.Logger.Error("Error creating index on task leases", "error", err)
+	}
+}
+
+// notify wakes up an idle worker
+func (tm *TaskManager) notify() {
+	select {
+	case tm.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work claims and runs tasks until the task manager shuts down
+func (tm *TaskManager) work() {
+	ticker := time.NewTicker(tm.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for tm.runNext() {
+		}
+
+		select {
+		case <-tm.baseCtx.Done():
+			return
+		case <-tm.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext claims and runs a single task, it reports whether one was found
+func (tm *TaskManager) runNext() bool {
+	tm.mu.Lock()
+	if tm.stopping {
+		tm.mu.Unlock()
+		return false
+	}
+	tm.inFlight.Add(1)
+	tm.mu.Unlock()
+	defer tm.inFlight.Done()
+
+	task, err := tm.claim(tm.baseCtx)
+	if err != nil {
+		if !errors.Is(err, mongo_errors.ErrDocumentNotFound) && tm.baseCtx.Err() == nil {
+			tm.Logger.Error("Error claiming task", "error", err)
+		}
+		return false
+	}
+
+	// Another task may be waiting, let an idle worker look for it
+	tm.notify()
+	tm.execute(task)
+	return true
+}
+
+// claim atomically moves the pending task with the highest priority that this instance can run to
+// running: its own tasks and the tasks of registered types, oldest first
+func (tm *TaskManager) claim(ctx context.Context) (*Task, error) {
+	now := time.Now()
+	filter := map[string]interface{}{
+		"status": TaskStatusPending,
+		"run_at": map[string]interface{}{"$lte": now},
+		"$or": []map[string]interface{}{
+			{"owner": tm.instanceID},
+			{"owner": map[string]interface{}{"$exists": false}, "task_type": map[string]interface{}{"$in": RegisteredTypes()}},
+		},
+	}
+	return tm.TaskRepo.FindOneAndUpdate(ctx, filter, map[string]interface{}{
+		"status":       TaskStatusRunning,
+		"claimed_by":   tm.instanceID,
+		"heartbeat_at": now,
+		"lease_until":  now.Add(tm.visibilityTimeout),
+		"updated_at":   now,
+	}, &models.FindOneAndUpdateOptions{
+		Sort:        bson.D{{Key: "priority", Value: -1}, {Key: "_id", Value: 1}},
+		ReturnAfter: true,
+	})
+}
+
+// execute runs a claimed task while renewing its lease and records the outcome
+func (tm *TaskManager) execute(task *Task) {
+	// Bookkeeping must still be written when the task manager shuts down
+	ctx := context.WithoutCancel(tm.baseCtx)
+
+	tm.mu.Lock()
+	taskFunc, owned := tm.funcs[task.ID]
+	delete(tm.funcs, task.ID)
+	tm.mu.Unlock()
+
+	var run func(ctx context.Context) (interface{}, error)
+	registered, isRegistered := lookupType(task.TaskType)
+	switch {
+	case owned:
+		var params map[string]interface{}
+		if task.Params != "" {
+			if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
+				tm.failTask(ctx, task.ID, "invalid task params: "+err.Error())
+				return
+			}
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			return taskFunc(ctx, params)
+		}
+	case task.Owner == "" && isRegistered:
+		run = func(ctx context.Context) (interface{}, error) {
+			return registered.run(ctx, task.Params)
+		}
+	default:
+		tm.failTask(ctx, task.ID, "task function is not available in this process")
+		return
+	}
+
+	timeout := task.Timeout
+	if timeout <= 0 {
+		timeout = tm.defaultTimeout
+	}
+
+	taskCtx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		taskCtx, cancelTimeout = context.WithTimeoutCause(taskCtx, timeout, ErrTaskTimeout)
+		defer cancelTimeout()
+	}
+
+	reporter := &progressReporter{tm: tm, taskID: task.ID}
+	taskCtx = context.WithValue(taskCtx, taskKey{}, reporter)
+
+	tm.mu.Lock()
+	tm.running[task.ID] = cancel
+	tm.mu.Unlock()
+	defer func() {
+		tm.mu.Lock()
+		delete(tm.running, task.ID)
+		tm.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go tm.heartbeat(task.ID, done, cancel)
+
+	result, err := runWithContext(taskCtx, run)
+	updateFields := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+
+	cause := context.Cause(taskCtx)
+	switch {
+	case err != nil && errors.Is(cause, ErrShuttingDown) && !owned:
+		// Another instance runs the task again
+		updateFields["status"] = TaskStatusPending
+		updateFields["claimed_by"] = ""
+	case err != nil && (errors.Is(cause, ErrTaskCancelled) || errors.Is(cause, ErrShuttingDown)):
+		updateFields["status"] = TaskStatusCancelled
+		updateFields["error"] = cause.Error()
+	case err != nil:
+		message := err.Error()
+		if errors.Is(cause, ErrTaskTimeout) {
+			message = fmt.Sprintf("task timed out after %s", timeout)
+		}
+		updateFields["error"] = message
+
+		if task.Attempt >= task.MaxRetries {
+			updateFields["status"] = TaskStatusFailed
+			break
+		}
+
+		policy := DefaultRetryPolicy
+		if task.RetryPolicy != nil {
+			policy = *task.RetryPolicy
+		}
+		attempt := task.Attempt + 1
+		wait := policy.Backoff(attempt)
+		updateFields["status"] = TaskStatusPending
+		updateFields["attempt"] = attempt
+		updateFields["run_at"] = time.Now().Add(wait)
+		updateFields["claimed_by"] = ""
+		if owned {
+			tm.mu.Lock()
+			tm.funcs[task.ID] = taskFunc
+			tm.mu.Unlock()
+		}
+		tm.Logger.Warn("Task failed, retrying", "task", task.ID, "attempt", attempt, "maxRetries", task.MaxRetries, "wait", wait, "error", message)
+	default:
+		updateFields["status"] = TaskStatusCompleted
+		serializedResult, err := json.Marshal(result)
+		if err != nil {
+			tm.Logger.Error("Error serializing task result", "error", err)
+			updateFields["status"] = TaskStatusFailed
+			updateFields["error"] = "invalid task result: " + err.Error()
+		} else {
+			updateFields["result"] = string(serializedResult)
+		}
+	}
+
+	// A finished task releases its idempotency key
+	if updateFields["status"] != TaskStatusPending {
+		updateFields["active_key"] = nil
+		updateFields["finished_at"] = time.Now()
+	}
+
+	// Progress reports coalesced by the throttle are written along with the outcome
+	if progress, dirty := reporter.pending(); dirty {
+		updateFields["progress"] = progress
+	}
+
+	// Update final status and result, unless the task was reaped in the meantime
+	updated, err := tm.TaskRepo.Update(ctx, map[string]interface{}{"_id": task.ID, "status": TaskStatusRunning, "claimed_by": tm.instanceID}, updateFields)
+	if err != nil {
+		tm.Logger.Error("Error updating task status and result", "error", err)
+		return
+	}
+
+	if status := updateFields["status"].(TaskStatus); task.ParentID != "" && status.Finished() && updated.MatchedCount > 0 {
+		tm.childFinished(ctx, task)
+	}
+}
+
+// runWithContext runs a task function, turning a panic into an error. A function that ignores its
+// context is abandoned once the context is done.
+func runWithContext(ctx context.Context, run func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+
+	finished := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				finished <- outcome{err: fmt.Errorf("task panicked: %v", r)}
+			}
+		}()
+		result, err := run(ctx)
+		finished <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case out := <-finished:
+		return out.result, out.err
+	case <-ctx.Done():
+	}
+
+	// The function may have returned right as the context was done
+	select {
+	case out := <-finished:
+		return out.result, out.err
+	default:
+		return nil, context.Cause(ctx)
+	}
+}
+
+// heartbeat renews the lease of a running task until done is closed. A cancellation requested
+// through the task document, possibly by another instance, cancels the task.
+func (tm *TaskManager) heartbeat(taskID string, done <-chan struct{}, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(tm.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			result, err := tm.TaskRepo.Update(context.Background(), map[string]interface{}{
+				"_id":              taskID,
+				"status":           TaskStatusRunning,
+				"claimed_by":       tm.instanceID,
+				"cancel_requested": map[string]interface{}{"$ne": true},
+			}, map[string]interface{}{
+				"heartbeat_at": now,
+				"lease_until":  now.Add(tm.visibilityTimeout),
+			})
+			if err != nil {
+				tm.Logger.Error("Error renewing task lease", "task", taskID, "error", err)
+				continue
+			}
+			if result.MatchedCount == 0 {
+				tm.Logger.Info("Cancelling task on request", "task", taskID)
+				cancel(ErrTaskCancelled)
+				return
+			}
+		}
+	}
+}
+
+// recoverAbandoned requeues the tasks of registered types a previous run of this process was running
+// and fails the tasks it left pending or running, their functions were lost with that process.
+// Running tasks whose lease expired and parents whose children were not rolled up are recovered as
+// well.
+func (tm *TaskManager) recoverAbandoned(ctx context.Context) {
+	requeued, err := tm.requeueTasks(ctx, map[string]interface{}{
+		"status":       TaskStatusRunning,
+		"claimed_by":   tm.instanceID,
+		"heartbeat_at": map[string]interface{}{"$lt": tm.bootedAt},
+	})
+	if err != nil {
+		tm.Logger.Error("Error requeuing abandoned tasks", "error", err)
+		return
+	}
+
+	owned, err := tm.failTasks(ctx, map[string]interface{}{
+		"owner":  tm.instanceID,
+		"status": map[string]interface{}{"$in": []TaskStatus{TaskStatusPending, TaskStatusRunning}},
+		// Tasks queued by this process before Start are not abandoned
+		"_id": map[string]interface{}{"$lt": primitive.NewObjectIDFromTimestamp(tm.bootedAt)},
+	}, "task was abandoned when its process stopped")
+	if err != nil {
+		tm.Logger.Error("Error recovering abandoned tasks", "error", err)
+		return
+	}
+
+	expired, err := tm.failExpired(ctx)
+	if err != nil {
+		tm.Logger.Error("Error recovering expired tasks", "error", err)
+		return
+	}
+
+	parents, err := tm.reconcileParents(ctx)
+	if err != nil {
+		tm.Logger.Error("Error reconciling parent tasks", "error", err)
+	}
+
+	if requeued+owned+expired+parents > 0 {
+		tm.Logger.Warn("Recovered abandoned tasks", "requeued", requeued, "owned", owned, "expired", expired, "parents", parents)
+	}
+}
+
+// reapExpired periodically recovers running tasks whose lease expired without a heartbeat and the
+// parents waiting on children that were not rolled up
+func (tm *TaskManager) reapExpired() {
+	ticker := time.NewTicker(tm.visibilityTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tm.baseCtx.Done():
+			return
+		case <-ticker.C:
+			expired, err := tm.failExpired(tm.baseCtx)
+			if err != nil {
+				tm.Logger.Error("Error reaping expired tasks", "error", err)
+				continue
+			}
+			if expired > 0 {
+				tm.Logger.Warn("Reaped tasks whose lease expired", "count", expired)
+			}
+
+			// Children failed by the reaper are rolled up here
+			parents, err := tm.reconcileParents(tm.baseCtx)
+			if err != nil {
+				tm.Logger.Error("Error reconciling parent tasks", "error", err)
+				continue
+			}
+			if parents > 0 {
+				tm.Logger.Warn("Reconciled parent tasks", "count", parents)
+			}
+		}
+	}
+}
+
+// failExpired recovers every running task whose lease expired: tasks of registered types are
+// requeued, the others are failed
+func (tm *TaskManager) failExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	requeued, err := tm.requeueTasks(ctx, map[string]interface{}{
+		"status":      TaskStatusRunning,
+		"lease_until": map[string]interface{}{"$lt": now},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	failed, err := tm.failTasks(ctx, map[string]interface{}{
+		"status":      TaskStatusRunning,
+		"lease_until": map[string]interface{}{"$lt": now},
+	}, "task lease expired without a heartbeat")
+	return requeued + failed, err
+}
+
+// requeueTasks moves the tasks of registered types matching filter back to pending so that any
+// instance runs them again
+func (tm *TaskManager) requeueTasks(ctx context.Context, filter map[string]interface{}) (int64, error) {
+	filter["owner"] = map[string]interface{}{"$exists": false}
+	result, err := tm.TaskRepo.Update(ctx, filter, map[string]interface{}{
+		"status":     TaskStatusPending,
+		"claimed_by": "",
+		"run_at":     time.Now(),
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// failTasks marks the tasks matching filter as failed with the given reason
+func (tm *TaskManager) failTasks(ctx context.Context, filter map[string]interface{}, reason string) (int64, error) {
+	result, err := tm.TaskRepo.Update(ctx, filter, map[string]interface{}{
+		"status":      TaskStatusFailed,
+		"error":       reason,
+		"active_key":  nil,
+		"updated_at":  time.Now(),
+		"finished_at": time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// failTask marks a single task as failed
+func (tm *TaskManager) failTask(ctx context.Context, taskID, reason string) {
+	if _, err := tm.failTasks(ctx, map[string]interface{}{"_id": taskID}, reason); err != nil {
+		tm.Logger.Error("Error failing task", "task", taskID, "error", err)
+	}
+}
+
+// withDefault returns value, or fallback when value is not set
+func withDefault[T int | time.Duration](value, fallback T) T {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,635 @@
+package task
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memTaskRepo keeps tasks in memory. Filters support plain fields and the $or, $in, $exists, $ne,
+// $lt, $lte, $gt and $gte operators, updates set fields. Documents go through BSON on every write,
+// like they would in MongoDB.
+type memTaskRepo struct {
+	repository.IRepository[Task]
+	mu    sync.Mutex
+	tasks []*Task
+	// updateDelay slows down every update, like a remote database would
+	updateDelay time.Duration
+}
+
+func (r *memTaskRepo) Create(ctx context.Context, data *Task) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, err := roundTrip(data)
+	if err != nil {
+		return "", err
+	}
+	if stored.ID == "" {
+		stored.ID = primitive.NewObjectID().Hex()
+	}
+	r.tasks = append(r.tasks, stored)
+	return stored.ID, nil
+}
+
+func (r *memTaskRepo) FindByID(ctx context.Context, id string) (*Task, error) {
+	return r.FindOne(ctx, map[string]interface{}{"_id": id})
+}
+
+func (r *memTaskRepo) FindOne(ctx context.Context, filter interface{}, opts ...*models.FindOptions) (*Task, error) {
+	found, err := r.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, mongo_errors.ErrDocumentNotFound
+	}
+	return found[0], nil
+}
+
+func (r *memTaskRepo) Find(ctx context.Context, filter interface{}, opts ...*models.FindOptions) ([]*Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := []*Task{}
+	for _, task := range r.tasks {
+		if matchTask(task, filter) {
+			copied := *task
+			found = append(found, &copied)
+		}
+	}
+	return found, nil
+}
+
+func (r *memTaskRepo) Count(ctx context.Context, filter interface{}) (int64, error) {
+	found, err := r.Find(ctx, filter)
+	return int64(len(found)), err
+}
+
+func (r *memTaskRepo) Update(ctx context.Context, filter interface{}, update interface{}) (*models.UpdateResult, error) {
+	r.mu.Lock()
+	delay := r.updateDelay
+	r.mu.Unlock()
+	time.Sleep(delay)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := &models.UpdateResult{}
+	for i, task := range r.tasks {
+		if !matchTask(task, filter) {
+			continue
+		}
+		updated, err := applyUpdate(task, update.(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		r.tasks[i] = updated
+		result.MatchedCount++
+		result.ModifiedCount++
+	}
+	return result, nil
+}
+
+func (r *memTaskRepo) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*models.FindOneAndUpdateOptions) (*Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var candidates []int
+	for i, task := range r.tasks {
+		if matchTask(task, filter) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, mongo_errors.ErrDocumentNotFound
+	}
+	if len(opts) > 0 && opts[0] != nil && opts[0].Sort != nil {
+		sort := opts[0].Sort.(bson.D)
+		slices.SortStableFunc(candidates, func(a, b int) int {
+			docA, _ := toDocument(r.tasks[a])
+			docB, _ := toDocument(r.tasks[b])
+			for _, key := range sort {
+				if order := compare(docA[key.Key], docB[key.Key]) * key.Value.(int); order != 0 {
+					return order
+				}
+			}
+			return 0
+		})
+	}
+
+	updated, err := applyUpdate(r.tasks[candidates[0]], update.(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	r.tasks[candidates[0]] = updated
+	copied := *updated
+	return &copied, nil
+}
+
+// get returns the stored task with id
+func (r *memTaskRepo) get(id string) *Task {
+	task, _ := r.FindByID(context.Background(), id)
+	return task
+}
+
+func matchTask(task *Task, filter interface{}) bool {
+	doc, err := toDocument(task)
+	if err != nil {
+		return false
+	}
+	return matchDocument(doc, filter.(map[string]interface{}))
+}
+
+func matchDocument(doc bson.M, filter map[string]interface{}) bool {
+	for key, value := range filter {
+		if key == "$or" {
+			matched := false
+			for _, alternative := range value.([]map[string]interface{}) {
+				matched = matched || matchDocument(doc, alternative)
+			}
+			if !matched {
+				return false
+			}
+			continue
+		}
+
+		field, exists := doc[key]
+		operators, isOperator := value.(map[string]interface{})
+		if !isOperator {
+			if compare(field, value) != 0 {
+				return false
+			}
+			continue
+		}
+		for operator, operand := range operators {
+			var ok bool
+			switch operator {
+			case "$exists":
+				ok = exists == operand.(bool)
+			case "$ne":
+				ok = compare(field, operand) != 0
+			case "$in":
+				list := reflect.ValueOf(operand)
+				for i := 0; i < list.Len() && !ok; i++ {
+					ok = compare(field, list.Index(i).Interface()) == 0
+				}
+			case "$lt":
+				ok = exists && compare(field, operand) < 0
+			case "$lte":
+				ok = exists && compare(field, operand) <= 0
+			case "$gt":
+				ok = exists && compare(field, operand) > 0
+			case "$gte":
+				ok = exists && compare(field, operand) >= 0
+			default:
+				panic("memTaskRepo: unsupported operator " + operator)
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compare orders two values of a filter or document the way MongoDB compares them
+func compare(a, b interface{}) int {
+	a, b = normalize(a), normalize(b)
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return cmp.Compare(a, b)
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+	return -1
+}
+
+// normalize turns the values MongoDB compares alike into the same type
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UnixMilli()
+	case primitive.DateTime:
+		return int64(v)
+	case primitive.ObjectID:
+		return v.Hex()
+	case bool, nil:
+		return v
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return v.Int()
+	}
+	return value
+}
+
+func toDocument(task *Task) (bson.M, error) {
+	encoded, err := bson.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	return doc, bson.Unmarshal(encoded, &doc)
+}
+
+func roundTrip(task *Task) (*Task, error) {
+	encoded, err := bson.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	var stored Task
+	return &stored, bson.Unmarshal(encoded, &stored)
+}
+
+func applyUpdate(task *Task, fields map[string]interface{}) (*Task, error) {
+	doc, err := toDocument(task)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		doc[key] = value
+	}
+	encoded, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var updated Task
+	return &updated, bson.Unmarshal(encoded, &updated)
+}
+
+// newTestManager returns a task manager on an in-memory repository
+func newTestManager(repo *memTaskRepo) *TaskManager {
+	tm := NewTaskManager(TaskManagerConfig{
+		InstanceID:        "test",
+		PollInterval:      10 * time.Millisecond,
+		HeartbeatInterval: time.Millisecond,
+		VisibilityTimeout: time.Minute,
+		Logger:            logger.New(logger.DefaultConfig("task")),
+	})
+	tm.TaskRepo = repo
+	return tm
+}
+
+// storeTask stores task as is, a task without RunAt may run right away
+func storeTask(t *testing.T, repo *memTaskRepo, task Task) string {
+	t.Helper()
+	if task.RunAt.IsZero() {
+		task.RunAt = time.Now().Add(-time.Second)
+	}
+	id, err := repo.Create(context.Background(), &task)
+	if err != nil {
+		t.Fatalf("unexpected error storing task: %v", err)
+	}
+	return id
+}
+
+func TestWithDefault(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    time.Duration
+		expected time.Duration
+	}{
+		{"set", 2 * time.Second, 2 * time.Second},
+		{"zero", 0, time.Minute},
+		{"negative", -time.Second, time.Minute},
+	}
+
+	for _, tc := range cases {
+		if got := withDefault(tc.value, time.Minute); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, got)
+		}
+	}
+
+	if got := withDefault(0, 4); got != 4 {
+		t.Errorf("expected the default worker count 4, got %d", got)
+	}
+}
+
+func TestTaskStatusFinished(t *testing.T) {
+	cases := []struct {
+		status   TaskStatus
+		expected bool
+	}{
+		{TaskStatusPending, false},
+		{TaskStatusRunning, false},
+		{TaskStatusWaiting, false},
+		{TaskStatusCompleted, true},
+		{TaskStatusFailed, true},
+		{TaskStatusCancelled, true},
+	}
+
+	for _, tc := range cases {
+		if got := tc.status.Finished(); got != tc.expected {
+			t.Errorf("%s: expected finished to be %t, got %t", tc.status, tc.expected, got)
+		}
+	}
+}
+
+func TestRunWithContext(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	cases := []struct {
+		name     string
+		run      func(ctx context.Context) (interface{}, error)
+		cancel   bool
+		expected error
+		result   interface{}
+	}{
+		{
+			name:   "result",
+			run:    func(ctx context.Context) (interface{}, error) { return 42, nil },
+			result: 42,
+		},
+		{
+			name:     "error",
+			run:      func(ctx context.Context) (interface{}, error) { return nil, errFailed },
+			expected: errFailed,
+		},
+		{
+			name: "function ignoring its context",
+			run: func(ctx context.Context) (interface{}, error) {
+				time.Sleep(time.Second)
+				return 42, nil
+			},
+			cancel:   true,
+			expected: ErrTaskCancelled,
+		},
+	}
+
+	for _, tc := range cases {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		if tc.cancel {
+			cancel(ErrTaskCancelled)
+		}
+
+		result, err := runWithContext(ctx, tc.run)
+		cancel(nil)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected error %v, got %v", tc.name, tc.expected, err)
+		}
+		if result != tc.result {
+			t.Errorf("%s: expected result %v, got %v", tc.name, tc.result, result)
+		}
+	}
+}
+
+func TestRunWithContext_Panic(t *testing.T) {
+	_, err := runWithContext(context.Background(), func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+var queueTestType = Register("queue-test", func(ctx context.Context, params struct{}) (struct{}, error) {
+	return struct{}{}, nil
+})
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+
+	storeTask(t, repo, Task{TaskType: "adhoc", Status: TaskStatusPending, Owner: "other"})
+	storeTask(t, repo, Task{TaskType: "unregistered", Status: TaskStatusPending})
+	storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusPending, RunAt: time.Now().Add(time.Hour)})
+	storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusRunning, ClaimedBy: "other"})
+	owned := storeTask(t, repo, Task{TaskType: "adhoc", Status: TaskStatusPending, Owner: "test"})
+	urgent := storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusPending, Priority: PriorityHigh})
+
+	// Higher priorities first, then tasks owned by this instance or of a registered type
+	for _, expected := range []string{urgent, owned} {
+		task, err := tm.claim(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if task.ID != expected {
+			t.Errorf("expected task %s to be claimed, got %s", expected, task.ID)
+		}
+		if task.Status != TaskStatusRunning || task.ClaimedBy != "test" || time.Until(task.LeaseUntil) < tm.visibilityTimeout-time.Second {
+			t.Errorf("expected the task to be running with a lease, got %s claimed by %q until %v", task.Status, task.ClaimedBy, task.LeaseUntil)
+		}
+	}
+
+	if _, err := tm.claim(ctx); !errors.Is(err, mongo_errors.ErrDocumentNotFound) {
+		t.Errorf("expected %v once no task can be claimed, got %v", mongo_errors.ErrDocumentNotFound, err)
+	}
+}
+
+func TestClaim_Concurrent(t *testing.T) {
+	repo := &memTaskRepo{}
+	for i := 0; i < 20; i++ {
+		storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusPending})
+	}
+
+	var mu sync.Mutex
+	claimed := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		tm := newTestManager(repo)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				task, err := tm.claim(context.Background())
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				claimed[task.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != 20 {
+		t.Errorf("expected every task to be claimed, got %d", len(claimed))
+	}
+	for id, count := range claimed {
+		if count != 1 {
+			t.Errorf("expected task %s to be claimed once, got %d", id, count)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+	id := storeTask(t, repo, Task{TaskType: "adhoc", Status: TaskStatusRunning, ClaimedBy: "test", LeaseUntil: time.Now()})
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		tm.heartbeat(id, make(chan struct{}), cancel)
+		close(stopped)
+	}()
+
+	waitFor(t, "the lease to be renewed", func() bool {
+		return time.Until(repo.get(id).LeaseUntil) > tm.visibilityTimeout/2
+	})
+
+	// A cancellation requested through the document, possibly by another instance, cancels the task
+	if _, err := repo.Update(context.Background(), map[string]interface{}{"_id": id}, map[string]interface{}{"cancel_requested": true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the heartbeat to stop once cancellation was requested")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrTaskCancelled) {
+		t.Errorf("expected the task to be cancelled with %v, got %v", ErrTaskCancelled, cause)
+	}
+}
+
+func TestFailExpired(t *testing.T) {
+	ctx := context.Background()
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+
+	expired := time.Now().Add(-time.Second)
+	registered := storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusRunning, ClaimedBy: "other", LeaseUntil: expired})
+	owned := storeTask(t, repo, Task{TaskType: "adhoc", Owner: "other", Status: TaskStatusRunning, ClaimedBy: "other", LeaseUntil: expired})
+	live := storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusRunning, ClaimedBy: "other", LeaseUntil: time.Now().Add(time.Minute)})
+
+	count, err := tm.failExpired(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 expired tasks, got %d", count)
+	}
+
+	// Any instance can run a registered type again, the function of an owned task is lost
+	if task := repo.get(registered); task.Status != TaskStatusPending || task.ClaimedBy != "" {
+		t.Errorf("expected the registered task to be requeued, got %s claimed by %q", task.Status, task.ClaimedBy)
+	}
+	if task := repo.get(owned); task.Status != TaskStatusFailed || !strings.Contains(task.Error, "lease expired") {
+		t.Errorf("expected the owned task to fail, got %s with %q", task.Status, task.Error)
+	}
+	if task := repo.get(live); task.Status != TaskStatusRunning {
+		t.Errorf("expected the task holding a lease to keep running, got %s", task.Status)
+	}
+}
+
+func TestRecoverAbandoned(t *testing.T) {
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+	before, after := tm.bootedAt.Add(-time.Minute), tm.bootedAt.Add(time.Second)
+	idAt := func(at time.Time) string { return primitive.NewObjectIDFromTimestamp(at).Hex() }
+
+	interrupted := storeTask(t, repo, Task{TaskType: queueTestType.Name(), Status: TaskStatusRunning, ClaimedBy: "test", HeartbeatAt: before, LeaseUntil: after})
+	lost := storeTask(t, repo, Task{ID: idAt(before), TaskType: "adhoc", Owner: "test", Status: TaskStatusPending})
+	queued := storeTask(t, repo, Task{ID: idAt(after), TaskType: "adhoc", Owner: "test", Status: TaskStatusPending})
+	other := storeTask(t, repo, Task{ID: idAt(before), TaskType: "adhoc", Owner: "other", Status: TaskStatusPending})
+
+	tm.recoverAbandoned(context.Background())
+
+	cases := []struct {
+		name     string
+		id       string
+		expected TaskStatus
+	}{
+		{"registered task interrupted by the restart", interrupted, TaskStatusPending},
+		{"owned task of the previous run", lost, TaskStatusFailed},
+		{"owned task queued before Start", queued, TaskStatusPending},
+		{"task owned by another instance", other, TaskStatusPending},
+	}
+	for _, tc := range cases {
+		if status := repo.get(tc.id).Status; status != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, status)
+		}
+	}
+}
+
+func noopTask(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func TestShutdown_FailsPendingOwnedTasks(t *testing.T) {
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+
+	id, err := tm.RunTask("report", nil, noopTask)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := storeTask(t, repo, Task{TaskType: "report", Owner: "other", Status: TaskStatusPending})
+
+	if err := tm.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if task := repo.get(id); task.Status != TaskStatusFailed {
+		t.Errorf("expected the pending task to fail, got %s", task.Status)
+	}
+	if task := repo.get(other); task.Status != TaskStatusPending {
+		t.Errorf("expected the task of another instance to stay pending, got %s", task.Status)
+	}
+	if _, err := tm.RunTask("report", nil, noopTask); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("expected %v, got %v", ErrShuttingDown, err)
+	}
+}
+
+func TestShutdown_TimeoutWaitsForCancelledTasks(t *testing.T) {
+	repo := &memTaskRepo{}
+	tm := newTestManager(repo)
+
+	started := make(chan struct{})
+	id, err := tm.RunTask("report", nil, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	go tm.work()
+	<-started
+
+	// Recording the outcome takes longer than the deadline
+	repo.mu.Lock()
+	repo.updateDelay = 50 * time.Millisecond
+	repo.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tm.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if task := repo.get(id); task.Status != TaskStatusCancelled {
+		t.Errorf("expected the cancelled task to be recorded before Shutdown returns, got %s", task.Status)
+	}
+}
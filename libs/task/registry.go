@@ -0,0 +1,96 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Handler runs a registered task type with its decoded params
+type Handler[P, R any] func(ctx context.Context, params P) (R, error)
+
+// TaskType is a handle on a registered task type, it enqueues params and decodes results with
+// the types of its handler
+type TaskType[P, R any] struct {
+	name string
+}
+
+// registeredType runs a task from its persisted params
+type registeredType struct {
+	run      func(ctx context.Context, params string) (interface{}, error)
+	defaults []TaskOption
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[string]*registeredType)
+)
+
+// Register makes handler available under taskType to every task manager of this process. Tasks
+// of a registered type are persisted with their params only, so any instance that registered the
+// type can run them, including after a restart. opts are the defaults of the type, options given
+// when enqueuing override them. Registering a type twice replaces the previous handler.
+func Register[P, R any](taskType string, handler Handler[P, R], opts ...TaskOption) TaskType[P, R] {
+	if taskType == "" || handler == nil {
+		panic("task: Register requires a task type and a handler")
+	}
+
+	run := func(ctx context.Context, raw string) (interface{}, error) {
+		var params P
+		if raw != "" {
+			if err := json.Unmarshal([]byte(raw), &params); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, params)
+	}
+
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	types[taskType] = &registeredType{run: run, defaults: opts}
+
+	return TaskType[P, R]{name: taskType}
+}
+
+// Name returns the name the task type was registered under
+func (t TaskType[P, R]) Name() string {
+	return t.name
+}
+
+// Enqueue queues a task of this type and returns its ID
+func (t TaskType[P, R]) Enqueue(tm *TaskManager, params P, opts ...TaskOption) (string, error) {
+	return tm.Enqueue(t.name, params, opts...)
+}
+
+// Result decodes the result of a completed task of this type
+func (t TaskType[P, R]) Result(task *Task) (R, error) {
+	var result R
+	if task.Result == "" {
+		return result, nil
+	}
+	err := json.Unmarshal([]byte(task.Result), &result)
+	return result, err
+}
+
+// lookupType returns the registered task type named taskType
+func lookupType(taskType string) (*registeredType, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	registered, ok := types[taskType]
+	return registered, ok
+}
+
+// RegisteredTypes returns the sorted names of every registered task type
+func RegisteredTypes() []string {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
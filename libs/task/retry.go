@@ -0,0 +1,46 @@
+package task
+
+import (
+	"math"
+	"time"
+)
+
+// RetryPolicy controls how long a failed task waits before its next attempt
+type RetryPolicy struct {
+	InitialInterval time.Duration `bson:"initial_interval" json:"initial_interval"`
+	MaxInterval     time.Duration `bson:"max_interval" json:"max_interval"`
+	Multiplier      float64       `bson:"multiplier" json:"multiplier"`
+}
+
+// DefaultRetryPolicy is used for retried tasks that do not configure a retry policy
+var DefaultRetryPolicy = RetryPolicy{
+	InitialInterval: 5 * time.Second,
+	MaxInterval:     5 * time.Minute,
+	Multiplier:      2,
+}
+
+// Backoff returns how long to wait after the given failed attempt, attempts start at 1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = DefaultRetryPolicy.InitialInterval
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = DefaultRetryPolicy.Multiplier
+	}
+
+	// Computed as a float so that a wait past the range of a Duration does not overflow
+	wait := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && wait > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+	if wait >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(wait)
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	capped := RetryPolicy{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Multiplier: 2}
+	uncapped := RetryPolicy{InitialInterval: time.Second, Multiplier: 2}
+
+	cases := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		expected time.Duration
+	}{
+		{"first attempt", capped, 1, time.Second},
+		{"attempt before 1", capped, 0, time.Second},
+		{"exponential", capped, 3, 4 * time.Second},
+		{"capped", capped, 4, 5 * time.Second},
+		{"capped on overflow", capped, 200, 5 * time.Second},
+		{"uncapped", uncapped, 4, 8 * time.Second},
+		{"uncapped on overflow", uncapped, 200, time.Duration(math.MaxInt64)},
+		{"defaults", RetryPolicy{}, 2, 2 * DefaultRetryPolicy.InitialInterval},
+	}
+
+	for _, tc := range cases {
+		if wait := tc.policy.Backoff(tc.attempt); wait != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, wait)
+		}
+	}
+}
+
+func TestNewTask_Options(t *testing.T) {
+	policy := &RetryPolicy{InitialInterval: time.Second}
+	task, err := newTask("import", map[string]string{"file": "a.csv"}, []TaskOption{
+		WithPriority(PriorityHigh),
+		WithMaxRetries(3, policy),
+		WithIdempotencyKey("import-a"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if task.Status != TaskStatusPending || task.Params != `{"file":"a.csv"}` {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if task.Priority != PriorityHigh || task.MaxRetries != 3 || task.RetryPolicy != policy {
+		t.Errorf("expected the options to apply, got %+v", task)
+	}
+	if task.ActiveKey == nil || *task.ActiveKey != "import-a" {
+		t.Errorf("expected the idempotency key to be active, got %v", task.ActiveKey)
+	}
+}
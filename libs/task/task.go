@@ -3,6 +3,9 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/himdhiman/dashboard-backend/libs/logger"
@@ -13,12 +16,30 @@ import (
 type TaskStatus string
 
 const (
-	TaskStatusPending   TaskStatus = "pending"
+	TaskStatusPending TaskStatus = "pending"
+	// TaskStatusWaiting marks a parent waiting for its children, or a child waiting for a free slot
+	// of the parallelism of its parent
+	TaskStatusWaiting   TaskStatus = "waiting"
 	TaskStatusRunning   TaskStatus = "running"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusCancelled TaskStatus = "cancelled"
 )
 
+// Priority orders pending tasks, higher priorities are claimed first
+type Priority int
+
+const (
+	PriorityLow    Priority = -10
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 10
+)
+
+// Finished reports whether a task in this status will not run anymore
+func (s TaskStatus) Finished() bool {
+	return s == TaskStatusCompleted || s == TaskStatusFailed || s == TaskStatusCancelled
+}
+
 type Task struct {
 	ID        string     `bson:"_id,omitempty" json:"id,omitempty"`
 	TaskType  string     `bson:"task_type" json:"task_type"`
@@ -26,88 +47,263 @@ type Task struct {
 	Params    string     `bson:"params,omitempty" json:"params,omitempty"`
 	Result    string     `bson:"result,omitempty" json:"result,omitempty"`
 	Error     string     `bson:"error,omitempty" json:"error,omitempty"`
-	CreatedAt string     `bson:"created_at" json:"created_at"`
-	UpdatedAt string     `bson:"updated_at" json:"updated_at"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updated_at"`
+	// FinishedAt is set once the task completes, fails or is cancelled, it drives retention
+	FinishedAt time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+
+	Priority Priority `bson:"priority" json:"priority"`
+	// RunAt is when the task may be claimed, it is pushed back between retries
+	RunAt       time.Time    `bson:"run_at" json:"run_at"`
+	Attempt     int          `bson:"attempt" json:"attempt"` // failed attempts so far
+	MaxRetries  int          `bson:"max_retries" json:"max_retries"`
+	RetryPolicy *RetryPolicy `bson:"retry_policy,omitempty" json:"retry_policy,omitempty"`
+	// IdempotencyKey identifies the logical work of the task, at most one pending or running task
+	// holds a given key
+	IdempotencyKey string `bson:"idempotency_key,omitempty" json:"idempotency_key,omitempty"`
+	// ActiveKey holds IdempotencyKey until the task finishes, it backs the unique index
+	ActiveKey *string `bson:"active_key,omitempty" json:"-"`
+
+	// ParentID links a child task to the parent it was fanned out from
+	ParentID       string `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	Children       int    `bson:"children,omitempty" json:"children,omitempty"`
+	ChildrenDone   int    `bson:"children_done,omitempty" json:"children_done,omitempty"`
+	ChildrenFailed int    `bson:"children_failed,omitempty" json:"children_failed,omitempty"`
+	// Parallelism bounds how many children of the parent are pending or running at once
+	Parallelism int `bson:"parallelism,omitempty" json:"parallelism,omitempty"`
+
+	// Owner is the instance holding the function of the task, only it can claim the task. Tasks of
+	// a registered type have no owner and can be claimed by any instance.
+	Owner       string    `bson:"owner,omitempty" json:"owner,omitempty"`
+	ClaimedBy   string    `bson:"claimed_by,omitempty" json:"claimed_by,omitempty"`
+	HeartbeatAt time.Time `bson:"heartbeat_at,omitempty" json:"heartbeat_at,omitempty"`
+	// LeaseUntil is when a running task without heartbeats is considered abandoned
+	LeaseUntil time.Time `bson:"lease_until,omitempty" json:"lease_until,omitempty"`
+	// Timeout bounds the execution of the task, zero means no timeout
+	Timeout time.Duration `bson:"timeout,omitempty" json:"timeout,omitempty"`
+	// CancelRequested asks the instance running the task to cancel it
+	CancelRequested bool `bson:"cancel_requested,omitempty" json:"cancel_requested,omitempty"`
+	// Progress is the latest progress reported with ReportProgress
+	Progress *Progress `bson:"progress,omitempty" json:"progress,omitempty"`
+}
+
+// TaskFunc is the function executed for a task, ctx is cancelled when the task is cancelled or
+// its deadline is reached. Progress is reported with ReportProgress(ctx, ...).
+type TaskFunc func(ctx context.Context, params map[string]interface{}) (interface{}, error)
+
+// TaskOption customises a task queued with RunTask
+type TaskOption func(*Task)
+
+// WithTimeout bounds the execution of a task, it overrides the DefaultTimeout of the task manager
+func WithTimeout(timeout time.Duration) TaskOption {
+	return func(task *Task) {
+		task.Timeout = timeout
+	}
+}
+
+// WithPriority sets the priority of a task, tasks default to PriorityNormal
+func WithPriority(priority Priority) TaskOption {
+	return func(task *Task) {
+		task.Priority = priority
+	}
+}
+
+// WithMaxRetries retries a failed task up to maxRetries times, waiting as told by policy between
+// attempts. A nil policy uses DefaultRetryPolicy.
+func WithMaxRetries(maxRetries int, policy *RetryPolicy) TaskOption {
+	return func(task *Task) {
+		task.MaxRetries = maxRetries
+		task.RetryPolicy = policy
+	}
+}
+
+// WithIdempotencyKey deduplicates tasks: queuing a task while another pending or running task
+// holds the same key returns the existing task instead
+func WithIdempotencyKey(key string) TaskOption {
+	return func(task *Task) {
+		task.IdempotencyKey = key
+	}
+}
+
+type TaskManagerConfig struct {
+	Collection *models.MongoCollection
+	// Workers bounds how many tasks run at the same time, defaults to DefaultWorkers
+	Workers int
+	// PollInterval is how often idle workers look for pending tasks, defaults to DefaultPollInterval
+	PollInterval time.Duration
+	// HeartbeatInterval is how often running tasks renew their lease, defaults to DefaultHeartbeatInterval
+	HeartbeatInterval time.Duration
+	// VisibilityTimeout is how long a running task stays claimed without a heartbeat, defaults to DefaultVisibilityTimeout
+	VisibilityTimeout time.Duration
+	// DefaultTimeout bounds tasks queued without WithTimeout, zero means no timeout
+	DefaultTimeout time.Duration
+	// RetentionPeriod is how long finished tasks are kept before MongoDB deletes them, zero keeps them forever
+	RetentionPeriod time.Duration
+	// InstanceID identifies this process as the owner of its tasks, defaults to the hostname
+	InstanceID string
+	Logger     logger.ILogger
 }
 
 type TaskManager struct {
 	Logger   logger.ILogger
 	TaskRepo repository.IRepository[Task]
+
+	collection        *models.MongoCollection
+	instanceID        string
+	workers           int
+	pollInterval      time.Duration
+	heartbeatInterval time.Duration
+	visibilityTimeout time.Duration
+	defaultTimeout    time.Duration
+	retention         time.Duration
+
+	bootedAt time.Time
+	funcs    map[string]TaskFunc
+	running  map[string]context.CancelCauseFunc
+	wake     chan struct{}
+	baseCtx  context.Context
+	stop     context.CancelFunc
+	inFlight sync.WaitGroup
+	started  bool
+	stopping bool
+	mu       sync.Mutex
 }
 
-func NewTaskManager(collection *models.MongoCollection, logger logger.ILogger) *TaskManager {
-	taskRepo := repository.Repository[Task]{Collection: collection}
+func NewTaskManager(config TaskManagerConfig) *TaskManager {
+	taskRepo := repository.Repository[Task]{Collection: config.Collection}
+
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
+	baseCtx, stop := context.WithCancel(context.Background())
 
 	return &TaskManager{
-		Logger:   logger,
-		TaskRepo: &taskRepo,
+		Logger:            config.Logger,
+		TaskRepo:          &taskRepo,
+		collection:        config.Collection,
+		instanceID:        instanceID,
+		workers:           withDefault(config.Workers, DefaultWorkers),
+		pollInterval:      withDefault(config.PollInterval, DefaultPollInterval),
+		heartbeatInterval: withDefault(config.HeartbeatInterval, DefaultHeartbeatInterval),
+		visibilityTimeout: withDefault(config.VisibilityTimeout, DefaultVisibilityTimeout),
+		defaultTimeout:    config.DefaultTimeout,
+		retention:         config.RetentionPeriod,
+		bootedAt:          time.Now(),
+		funcs:             make(map[string]TaskFunc),
+		running:           make(map[string]context.CancelCauseFunc),
+		wake:              make(chan struct{}, 1),
+		baseCtx:           baseCtx,
+		stop:              stop,
 	}
 }
 
-// RunTask runs a task in the background and adds an entry in the MongoDB database for that task
-func (tm *TaskManager) RunTask(taskType string, params map[string]interface{}, taskFunc func(params map[string]interface{}) (interface{}, error)) (string, error) {
-	// serilize the params to store in the database
-	serializedParams, err := json.Marshal(params)
+// RunTask queues a task and adds an entry in the MongoDB database for that task. The task runs on
+// one of the workers of this process once one is free, since taskFunc does not outlive it.
+func (tm *TaskManager) RunTask(taskType string, params map[string]interface{}, taskFunc TaskFunc, opts ...TaskOption) (string, error) {
+	task, err := newTask(taskType, params, opts)
 	if err != nil {
 		tm.Logger.Error("Error serializing task params", "error", err)
 		return "", err
 	}
+	task.Owner = tm.instanceID
+
+	id, _, err := tm.enqueue(task, taskFunc)
+	return id, err
+}
 
+// Enqueue queues a task of a type registered with Register, any instance that registered the type
+// may run it. params must match the params type of the handler.
+func (tm *TaskManager) Enqueue(taskType string, params interface{}, opts ...TaskOption) (string, error) {
+	registered, ok := lookupType(taskType)
+	if !ok {
+		return "", fmt.Errorf("task type %s is not registered", taskType)
+	}
+
+	// Options given here override the defaults of the type
+	options := append(append([]TaskOption{}, registered.defaults...), opts...)
+	task, err := newTask(taskType, params, options)
+	if err != nil {
+		tm.Logger.Error("Error serializing task params", "error", err)
+		return "", err
+	}
+
+	id, _, err := tm.enqueue(task, nil)
+	return id, err
+}
+
+// newTask builds a pending task with serialized params
+func newTask(taskType string, params interface{}, opts []TaskOption) (*Task, error) {
+	// serilize the params to store in the database
+	serializedParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
 	task := &Task{
 		TaskType:  taskType,
 		Status:    TaskStatusPending,
 		Params:    string(serializedParams),
-		Error:     "",
-		CreatedAt: time.Now().Format(time.RFC3339),
-		UpdatedAt: time.Now().Format(time.RFC3339),
+		Priority:  PriorityNormal,
+		RunAt:     now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, opt := range opts {
+		opt(task)
 	}
+	if task.IdempotencyKey != "" {
+		task.ActiveKey = &task.IdempotencyKey
+	}
+	return task, nil
+}
 
+// enqueue persists a task, or returns the active task holding its idempotency key, created tells
+// which. taskFunc is kept for tasks owned by this instance.
+func (tm *TaskManager) enqueue(task *Task, taskFunc TaskFunc) (string, bool, error) {
 	ctx := context.Background()
-	id, err := tm.TaskRepo.Create(ctx, task)
-	if err != nil {
-		tm.Logger.Error("Error creating task", "error", err)
-		return "", err
-	}
 
-	go func() {
-		// Update the task status to running
-		_, err := tm.TaskRepo.Update(ctx, map[string]interface{}{"_id": id}, map[string]interface{}{
-			"status":     TaskStatusRunning,
-			"updated_at": time.Now().Format(time.RFC3339),
-		})
-		if err != nil {
-			tm.Logger.Error("Error updating task status to running", "error", err)
-			return
+	if task.IdempotencyKey != "" {
+		if existing, err := tm.activeTask(ctx, task.IdempotencyKey); err == nil {
+			tm.Logger.Info("Task already queued", "task", existing.ID, "idempotencyKey", task.IdempotencyKey)
+			return existing.ID, false, nil
 		}
+	}
 
-		// Run the task function with the provided parameters
-		result, err := taskFunc(params)
-		updateFields := map[string]interface{}{
-			"updated_at": time.Now().Format(time.RFC3339),
-		}
+	// Holding the lock until the function is stored keeps workers from running the task without it
+	tm.mu.Lock()
+	if tm.stopping {
+		tm.mu.Unlock()
+		return "", false, ErrShuttingDown
+	}
 
-		if err != nil {
-			updateFields["status"] = TaskStatusFailed
-			updateFields["error"] = err.Error()
-		} else {
-			updateFields["status"] = TaskStatusCompleted
-			serializedResult, err := json.Marshal(result)
-			if err != nil {
-				tm.Logger.Error("Error serializing task result", "error", err)
-				return
+	id, err := tm.TaskRepo.Create(ctx, task)
+	if err != nil {
+		tm.mu.Unlock()
+
+		// Another request may have queued the same work concurrently
+		if task.IdempotencyKey != "" {
+			if existing, findErr := tm.activeTask(ctx, task.IdempotencyKey); findErr == nil {
+				return existing.ID, false, nil
 			}
-			updateFields["result"] = string(serializedResult)
 		}
+		tm.Logger.Error("Error creating task", "error", err)
+		return "", false, err
+	}
+	if taskFunc != nil {
+		tm.funcs[id] = taskFunc
+	}
+	tm.mu.Unlock()
 
-		// Update final status and result
-		_, err = tm.TaskRepo.Update(ctx, map[string]interface{}{"_id": id}, updateFields)
-		if err != nil {
-			tm.Logger.Error("Error updating task status and result", "error", err)
-			return
-		}
-	}()
+	tm.notify()
+	return id, true, nil
+}
 
-	return id, nil
+// activeTask returns the pending or running task holding an idempotency key
+func (tm *TaskManager) activeTask(ctx context.Context, key string) (*Task, error) {
+	return tm.TaskRepo.FindOne(ctx, map[string]interface{}{"active_key": key})
 }
 
 // GetTaskByID fetches a task by its ID
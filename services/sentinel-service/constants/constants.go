@@ -26,10 +26,6 @@ const (
 	API_TIMEOUT    = ":Timeout"
 )
 
-const (
-	EXPORT_JOB_CODE = "export_job_code"
-)
-
 func GetBaseURLKey(apiCode string) string {
 	return apiCode + BASE_URL
 }
@@ -61,7 +57,3 @@ func GetApiRateLimitKey(apiCode, endpointCode string) string {
 func GetApiTimeoutKey(apiCode, endpointCode string) string {
 	return apiCode + ":" + endpointCode + API_TIMEOUT
 }
-
-func GetUnicomExportJobCode() string {
-	return UNICOM_API_CODE + ":" + EXPORT_JOB_CODE
-}
@@ -1,10 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
 	"github.com/himdhiman/dashboard-backend/libs/task"
 )
 
@@ -20,6 +25,50 @@ func NewController(logger logger.ILogger, taskManager *task.TaskManager) *Contro
 	}
 }
 
+// ListTasks lists tasks, newest first, filtered by type, status, parent and creation time. Pages are
+// fetched by passing the returned next_cursor as cursor.
+func (uc *Controller) ListTasks(c *gin.Context) {
+	filter := task.TaskFilter{
+		TaskType: c.Query("type"),
+		Status:   task.TaskStatus(c.Query("status")),
+		ParentID: c.Query("parent_id"),
+	}
+
+	var err error
+	if from := c.Query("from"); from != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, from); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC3339 time"})
+			return
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, to); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC3339 time"})
+			return
+		}
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	tasks, next, err := uc.TaskManager.ListTasks(c.Request.Context(), filter, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, task.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
+		return
+	}
+
+	if tasks == nil {
+		tasks = []*task.Task{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tasks, "next_cursor": next})
+}
+
 // GetTaskStatus fetches the status of a task by its ID
 func (uc *Controller) GetTaskStatus(c *gin.Context) {
 	taskID := c.Param("task_id")
@@ -32,6 +81,56 @@ func (uc *Controller) GetTaskStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, task)
 }
 
+// StreamTask streams the status and progress of a task as Server-Sent Events until it finishes
+func (uc *Controller) StreamTask(c *gin.Context) {
+	taskID := c.Param("task_id")
+	updates, err := uc.TaskManager.WatchTask(c.Request.Context(), taskID)
+	switch {
+	case err == nil:
+	case errors.Is(err, mongo_errors.ErrDocumentNotFound), errors.Is(err, mongo_errors.ErrInvalidObjectID):
+		c.JSON(http.StatusNotFound, gin.H{"error": task.ErrTaskNotFound.Error()})
+		return
+	default:
+		uc.Logger.Error("Error fetching task", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		update, ok := <-updates
+		if !ok {
+			return false
+		}
+		event := "progress"
+		if update.Status.Finished() {
+			event = "done"
+		}
+		c.SSEvent(event, update)
+		return true
+	})
+}
+
+// CancelTask cancels a pending or running task, the task is then recorded as cancelled
+func (uc *Controller) CancelTask(c *gin.Context) {
+	taskID := c.Param("task_id")
+	err := uc.TaskManager.CancelTask(taskID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Task cancelled", "task_id": taskID})
+	case errors.Is(err, task.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, task.ErrTaskFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		uc.Logger.Error("Error cancelling task", "task", taskID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel task"})
+	}
+}
+
 // create a health check endpoint
 func (uc *Controller) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"github.com/himdhiman/dashboard-backend/libs/task"
+)
+
+// taskStates serves FindByID with the given states of a single task in turn, the last one is kept
+type taskStates struct {
+	repository.IRepository[task.Task]
+	mu     sync.Mutex
+	states []*task.Task
+}
+
+func (r *taskStates) FindByID(ctx context.Context, id string) (*task.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.states) == 0 {
+		return nil, mongo_errors.ErrDocumentNotFound
+	}
+	state := *r.states[0]
+	if len(r.states) > 1 {
+		r.states = r.states[1:]
+	}
+	return &state, nil
+}
+
+func TestStreamTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name     string
+		states   []*task.Task
+		status   int
+		expected []string
+	}{
+		{"unknown task", nil, http.StatusNotFound, nil},
+		{"finished task", []*task.Task{{Status: task.TaskStatusCompleted}}, http.StatusOK, []string{"event:done"}},
+		{
+			"task finishing while streamed",
+			[]*task.Task{
+				{Status: task.TaskStatusRunning},
+				{Status: task.TaskStatusRunning, Progress: &task.Progress{Percent: 50}},
+				{Status: task.TaskStatusCompleted, Progress: &task.Progress{Percent: 100}},
+			},
+			http.StatusOK,
+			[]string{"event:progress", "event:progress", "event:done"},
+		},
+	}
+
+	for _, tc := range cases {
+		log := logger.New(logger.DefaultConfig("controllers"))
+		taskManager := task.NewTaskManager(task.TaskManagerConfig{Logger: log})
+		taskManager.TaskRepo = &taskStates{states: tc.states}
+
+		router := gin.New()
+		router.GET("/tasks/:task_id/events", NewController(log, taskManager).StreamTask)
+		server := httptest.NewServer(router)
+
+		// The request only completes when the stream ends
+		client := &http.Client{Timeout: 10 * time.Second}
+		response, err := client.Get(server.URL + "/tasks/1/events")
+		if err != nil {
+			server.Close()
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: expected the stream to end, got %v", tc.name, err)
+		}
+
+		if response.StatusCode != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, response.StatusCode)
+			continue
+		}
+		var events []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if strings.HasPrefix(line, "event:") {
+				events = append(events, line)
+			}
+		}
+		if strings.Join(events, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%s: expected events %v, got %v", tc.name, tc.expected, events)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_models "github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
+)
+
+type SchedulerController struct {
+	Logger    logger.ILogger
+	Scheduler *scheduler.Scheduler
+}
+
+func NewSchedulerController(logger logger.ILogger, jobScheduler *scheduler.Scheduler) *SchedulerController {
+	return &SchedulerController{
+		Logger:    logger,
+		Scheduler: jobScheduler,
+	}
+}
+
+// ListJobs lists every scheduled job with its last and next run
+func (sc *SchedulerController) ListJobs(c *gin.Context) {
+	jobs, err := sc.Scheduler.ListJobs(c.Request.Context())
+	if err != nil {
+		sc.Logger.Error("Error listing scheduled jobs", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+
+	if jobs == nil {
+		jobs = []*scheduler.Job{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// GetJob fetches a single scheduled job by its name
+func (sc *SchedulerController) GetJob(c *gin.Context) {
+	job, err := sc.Scheduler.GetJob(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		sc.respondError(c, "Failed to fetch job", err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// PauseJob stops triggering a job until it is resumed
+func (sc *SchedulerController) PauseJob(c *gin.Context) {
+	name := c.Param("name")
+	if err := sc.Scheduler.PauseJob(c.Request.Context(), name); err != nil {
+		sc.respondError(c, "Failed to pause job", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job paused", "job": name})
+}
+
+// ResumeJob starts triggering a paused job again
+func (sc *SchedulerController) ResumeJob(c *gin.Context) {
+	name := c.Param("name")
+	if err := sc.Scheduler.ResumeJob(c.Request.Context(), name); err != nil {
+		sc.respondError(c, "Failed to resume job", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job resumed", "job": name})
+}
+
+// TriggerJob runs a job immediately in the background
+func (sc *SchedulerController) TriggerJob(c *gin.Context) {
+	name := c.Param("name")
+	if err := sc.Scheduler.TriggerJob(name); err != nil {
+		sc.respondError(c, "Failed to trigger job", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job triggered", "job": name})
+}
+
+// CancelJob cancels the running executions of a job
+func (sc *SchedulerController) CancelJob(c *gin.Context) {
+	name := c.Param("name")
+	if err := sc.Scheduler.CancelJob(name); err != nil {
+		sc.respondError(c, "Failed to cancel job", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job cancelled", "job": name})
+}
+
+// RescheduleJob changes the cron expression and timezone of a job at runtime
+func (sc *SchedulerController) RescheduleJob(c *gin.Context) {
+	name := c.Param("name")
+
+	var request struct {
+		CronExpr string `json:"cron_expr" binding:"required"`
+		Timezone string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		sc.Logger.Error("Error binding JSON", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+		return
+	}
+
+	if err := sc.Scheduler.RescheduleJob(c.Request.Context(), name, request.CronExpr, request.Timezone); err != nil {
+		sc.respondError(c, "Failed to reschedule job", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job rescheduled", "job": name, "cron_expr": request.CronExpr, "timezone": request.Timezone})
+}
+
+// PreviewSchedule lists the next fire times of a cron expression in an optional timezone
+func (sc *SchedulerController) PreviewSchedule(c *gin.Context) {
+	cronExpr := c.Query("cron_expr")
+	if cronExpr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cron_expr is required"})
+		return
+	}
+	timezone := c.Query("timezone")
+
+	count, err := strconv.Atoi(c.DefaultQuery("count", "5"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count", "details": err.Error()})
+		return
+	}
+
+	runs, err := scheduler.NextRunTimes(cronExpr, timezone, time.Now(), count)
+	if err != nil {
+		sc.respondError(c, "Failed to preview schedule", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cron_expr": cronExpr, "timezone": timezone, "next_runs": runs})
+}
+
+// GetJobRuns lists the run history of a job, newest first
+func (sc *SchedulerController) GetJobRuns(c *gin.Context) {
+	name := c.Param("name")
+
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	runs, total, err := sc.Scheduler.GetJobRuns(c.Request.Context(), name, mongo_models.PaginationOptions{
+		Page:     page,
+		PageSize: limit,
+	})
+	if err != nil {
+		sc.respondError(c, "Failed to fetch job runs", err)
+		return
+	}
+
+	if runs == nil {
+		runs = []*scheduler.JobRun{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": runs, "total": total, "page": page, "limit": limit})
+}
+
+// StartWorkflow starts a run of a registered workflow with the params in the request body
+func (sc *SchedulerController) StartWorkflow(c *gin.Context) {
+	var request struct {
+		Params map[string]interface{} `json:"params"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			sc.Logger.Error("Error binding JSON", "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+			return
+		}
+	}
+
+	run, err := sc.Scheduler.StartWorkflow(c.Request.Context(), c.Param("name"), request.Params)
+	if err != nil {
+		sc.respondError(c, "Failed to start workflow", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetWorkflowRuns lists workflow runs, newest first, optionally filtered by workflow and status
+func (sc *SchedulerController) GetWorkflowRuns(c *gin.Context) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	runs, total, err := sc.Scheduler.GetWorkflowRuns(c.Request.Context(), c.Query("workflow"), scheduler.WorkflowStatus(c.Query("status")), mongo_models.PaginationOptions{
+		Page:     page,
+		PageSize: limit,
+	})
+	if err != nil {
+		sc.respondError(c, "Failed to fetch workflow runs", err)
+		return
+	}
+
+	if runs == nil {
+		runs = []*scheduler.WorkflowRun{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": runs, "total": total, "page": page, "limit": limit})
+}
+
+// GetWorkflowRun fetches a single workflow run with the state of every step
+func (sc *SchedulerController) GetWorkflowRun(c *gin.Context) {
+	run, err := sc.Scheduler.GetWorkflowRun(c.Request.Context(), c.Param("run_id"))
+	if err != nil {
+		sc.respondError(c, "Failed to fetch workflow run", err)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// respondError maps scheduler errors to HTTP status codes
+func (sc *SchedulerController) respondError(c *gin.Context, message string, err error) {
+	sc.Logger.Error(message, "job", c.Param("name"), "error", err)
+
+	status := http.StatusInternalServerError
+	switch {
+	case scheduler.HasErrorCode(err, scheduler.ErrCodeJobNotFound), scheduler.HasErrorCode(err, scheduler.ErrCodeWorkflowNotFound):
+		status = http.StatusNotFound
+	case scheduler.HasErrorCode(err, scheduler.ErrCodeInvalidConfig):
+		status = http.StatusBadRequest
+	case scheduler.HasErrorCode(err, scheduler.ErrCodeJobNotRunning):
+		status = http.StatusConflict
+	case scheduler.HasErrorCode(err, scheduler.ErrCodeShutdown):
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{"error": message, "details": err.Error()})
+}
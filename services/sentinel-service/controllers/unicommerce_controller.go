@@ -2,16 +2,25 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/himdhiman/dashboard-backend/libs/logger"
+	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	mongo_models "github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
 	"github.com/himdhiman/dashboard-backend/libs/task"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/constants"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/dto"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/mappers"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/models"
+	"github.com/himdhiman/dashboard-backend/services/sentinel-service/schedulers"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/services"
 	"github.com/mitchellh/mapstructure"
 )
@@ -20,21 +29,25 @@ type UnicommerceController struct {
 	Logger      logger.ILogger
 	Service     *services.UnicommerceService
 	TaskManager *task.TaskManager
+	Scheduler   *scheduler.Scheduler
 }
 
-func NewUnicommerceController(logger logger.ILogger, service *services.UnicommerceService, taskManager *task.TaskManager) *UnicommerceController {
+func NewUnicommerceController(logger logger.ILogger, service *services.UnicommerceService, taskManager *task.TaskManager, jobScheduler *scheduler.Scheduler) *UnicommerceController {
 	return &UnicommerceController{
 		Logger:      logger,
 		Service:     service,
 		TaskManager: taskManager,
+		Scheduler:   jobScheduler,
 	}
 }
 
 type GetProductsResponse struct {
-	Data  []models.Product `json:"data"`
-	Total int              `json:"total"`
-	Page  int              `json:"page"`
-	Limit int              `json:"limit"`
+	Data       []models.Product `json:"data"`
+	Total      int              `json:"total"`
+	Page       int              `json:"page"`
+	Limit      int              `json:"limit"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	PrevCursor string           `json:"prev_cursor,omitempty"`
 }
 
 func (uc *UnicommerceController) GetProducts(c *gin.Context) {
@@ -54,44 +67,44 @@ func (uc *UnicommerceController) GetProducts(c *gin.Context) {
 		limit = 10
 	}
 
-	/// Fetch products
+	/// Fetch products, a cursor from a previous page takes precedence over the page number
 	ctx := c.Request.Context()
-	productsPtr, total, err := uc.Service.GetProducts(ctx, skuCode, pageNumber, limit)
+	page, err := uc.Service.GetProducts(ctx, skuCode, mongo_models.PaginationOptions{
+		Page:     int64(pageNumber),
+		PageSize: int64(limit),
+		Cursor:   c.Query("cursor"),
+	})
 	if err != nil {
+		if errors.Is(err, mongo_errors.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		uc.Logger.Error("Error fetching products", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 		return
 	}
 
-	products := make([]models.Product, len(productsPtr))
-	for i, p := range productsPtr {
+	products := make([]models.Product, len(page.Items))
+	for i, p := range page.Items {
 		products[i] = *p
 	}
 
 	response := GetProductsResponse{
-		Data:  products,
-		Total: int(total),
-		Page:  pageNumber,
-		Limit: limit,
+		Data:       products,
+		Total:      int(page.Total),
+		Page:       pageNumber,
+		Limit:      limit,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// FetchProducts fetches products from the Unicommerce API, runs the task in the background and returns the task ID
+// FetchProducts fetches products from the Unicommerce API, runs the task in the background and returns the task ID.
+// A fetch already pending or running is returned instead of queuing another one.
 func (uc *UnicommerceController) FetchProducts(c *gin.Context) {
-	fetchProductsTask := func(params map[string]interface{}) (interface{}, error) {
-		ctx := context.Background()
-		err := uc.Service.FetchProducts(ctx)
-		if err != nil {
-			uc.Logger.Error("Error fetching products", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
-			return nil, err
-		}
-		return nil, nil
-	}
-
-	taskID, err := uc.TaskManager.RunTask("FetchProducts", nil, fetchProductsTask)
+	taskID, err := uc.TaskManager.Enqueue(services.FetchProductsTask, struct{}{}, task.WithIdempotencyKey("fetch-products"))
 	if err != nil {
 		uc.Logger.Error("Error running fetch products task", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run fetch products task"})
@@ -101,24 +114,34 @@ func (uc *UnicommerceController) FetchProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"task_id": taskID})
 }
 
-// CreateExportJob creates an export job in the Unicommerce API, runs the task in the background and returns the task ID
+// CreateExportJob starts the product export workflow unless one is already running and returns its run ID.
+// The export workflow is exclusive, so concurrent requests cannot start two runs.
 func (uc *UnicommerceController) CreateExportJob(c *gin.Context) {
-	ctx := context.Background()
-	var jobCode string
-	cacheErr := uc.Service.TokenManager.Cache.Get(ctx, constants.GetUnicomExportJobCode(), &jobCode)
-	if cacheErr == nil && jobCode != "" {
-		c.JSON(http.StatusOK, gin.H{"message": "A job is already running"})
+	ctx := c.Request.Context()
+
+	run, err := uc.Scheduler.StartWorkflow(ctx, schedulers.ExportWorkflowName, nil)
+	if scheduler.HasErrorCode(err, scheduler.ErrCodeWorkflowRunning) {
+		running, total, err := uc.Scheduler.GetWorkflowRuns(ctx, schedulers.ExportWorkflowName, scheduler.WorkflowStatusRunning, mongo_models.PaginationOptions{Page: 1, PageSize: 1})
+		if err != nil {
+			uc.Logger.Error("Error fetching running export workflows", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create export job"})
+			return
+		}
+		response := gin.H{"message": "A job is already running"}
+		if total > 0 {
+			response["run_id"] = running[0].ID
+		}
+		c.JSON(http.StatusOK, response)
 		return
 	}
-
-	job, err := uc.Service.CreateExportJob(ctx)
 	if err != nil {
-		uc.Logger.Error("Error creating export job", "error", err)
+		uc.Logger.Error("Error starting export workflow", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create export job"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"job_code": job.JobCode})
+	// job_code is kept for the clients written before exports ran as a workflow
+	c.JSON(http.StatusCreated, gin.H{"run_id": run.ID, "job_code": run.ID})
 }
 
 func (uc *UnicommerceController) SearchProduct(c *gin.Context) {
@@ -136,15 +159,19 @@ func (uc *UnicommerceController) SearchProduct(c *gin.Context) {
 		return
 	}
 
-	products, err := uc.Service.SearchProduct(ctx, request.SKUCode, request.Name)
-	if err != nil {
-		uc.Logger.Error("Error searching products", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
-		return
-	}
+	// Matches are written as they are read so the response never holds the whole result set
+	started := false
+	encoder := json.NewEncoder(c.Writer)
+	for product, err := range uc.Service.SearchProduct(ctx, request.SKUCode, request.Name) {
+		if err != nil {
+			uc.Logger.Error("Error searching products", "error", err)
+			if !started {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+			}
+			// The status is already sent, the truncated body tells the client the search failed
+			return
+		}
 
-	response := make([]map[string]interface{}, len(products))
-	for i, product := range products {
 		productMap := make(map[string]interface{})
 		for _, field := range request.Fields {
 			switch field {
@@ -166,10 +193,28 @@ func (uc *UnicommerceController) SearchProduct(c *gin.Context) {
 				uc.Logger.Warn("Unknown field requested", "field", field)
 			}
 		}
-		response[i] = productMap
+
+		separator := ","
+		if !started {
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			c.Status(http.StatusOK)
+			separator = `{"products":[`
+			started = true
+		}
+		if _, err := io.WriteString(c.Writer, separator); err != nil {
+			return
+		}
+		if err := encoder.Encode(productMap); err != nil {
+			uc.Logger.Error("Error writing product", "error", err)
+			return
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"products": response})
+	if !started {
+		c.JSON(http.StatusOK, gin.H{"products": []map[string]interface{}{}})
+		return
+	}
+	io.WriteString(c.Writer, "]}")
 }
 
 func (uc *UnicommerceController) CreatePurchaseOrder(c *gin.Context) {
@@ -213,11 +258,16 @@ func (uc *UnicommerceController) CreatePurchaseOrder(c *gin.Context) {
 	err = uc.Service.CreatePurchaseOrder(ctx, &purchaseOrder)
 	if err != nil {
 		uc.Logger.Error("Error creating purchase order", "error", err, "correlationID", correlationID)
+		if errors.Is(err, mongo_errors.ErrValidationFailed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase order", "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase order"})
 		return
 	}
 
 	uc.Logger.Info("Purchase order created successfully", "orderNumber", purchaseOrder.PONumber, "correlationID", correlationID)
+	c.Header("ETag", etag(purchaseOrder.Version))
 	c.JSON(http.StatusCreated, gin.H{"message": "Purchase order created successfully", "orderNumber": purchaseOrder.PONumber})
 }
 
@@ -236,6 +286,14 @@ func (uc *UnicommerceController) UpdatePurchaseOrder(c *gin.Context) {
 		return
 	}
 
+	// If-Match carries the version the client last read, without it the update is only protected
+	// against concurrent writes while it is applied
+	version, err := parseIfMatch(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid If-Match header"})
+		return
+	}
+
 	var updates map[string]interface{}
 	if err := c.ShouldBindJSON(&updates); err != nil {
 		uc.Logger.Error("Error binding JSON", "error", err, "correlationID", correlationID)
@@ -243,14 +301,109 @@ func (uc *UnicommerceController) UpdatePurchaseOrder(c *gin.Context) {
 		return
 	}
 
-	err := uc.Service.UpdatePurchaseOrder(ctx, poNumber, updates)
+	purchaseOrder, err := uc.Service.UpdatePurchaseOrder(ctx, poNumber, version, updates)
 	if err != nil {
 		uc.Logger.Error("Error updating purchase order", "error", err, "correlationID", correlationID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update purchase order" + err.Error()})
+		switch {
+		case errors.Is(err, mongo_errors.ErrVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "Purchase order was modified by another request, reload it and retry"})
+		case errors.Is(err, mongo_errors.ErrDocumentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to update purchase order", "details": err.Error()})
+		}
+		return
+	}
+
+	c.Header("ETag", etag(purchaseOrder.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase order updated successfully", "version": purchaseOrder.Version})
+}
+
+// StreamPurchaseOrders pushes every change to a purchase order as a server-sent event named after
+// the operation
+func (uc *UnicommerceController) StreamPurchaseOrders(c *gin.Context) {
+	events, err := uc.Service.WatchPurchaseOrders(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watch purchase orders"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Purchase order updated successfully"})
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		event, ok := <-events
+		if !ok {
+			return false
+		}
+		if event.Err != nil {
+			uc.Logger.Warn("Error watching purchase orders", "error", event.Err)
+			return true
+		}
+		c.SSEvent(string(event.Operation), gin.H{"id": event.ID, "purchaseOrder": event.Document, "at": event.At})
+		return true
+	})
+}
+
+// DeletePurchaseOrder soft deletes a purchase order, it can be restored until it is purged
+func (uc *UnicommerceController) DeletePurchaseOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+	correlationID := c.GetHeader("X-Correlation-ID")
+	if correlationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing correlation ID"})
+		return
+	}
+	ctx = context.WithValue(ctx, constants.CorrelationID, correlationID)
+
+	poNumber := c.DefaultQuery("poNumber", "")
+	if poNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing purchase order number"})
+		return
+	}
+
+	err := uc.Service.DeletePurchaseOrder(ctx, poNumber)
+	if err != nil {
+		if errors.Is(err, mongo_errors.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+			return
+		}
+		uc.Logger.Error("Error deleting purchase order", "error", err, "correlationID", correlationID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete purchase order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase order deleted successfully"})
+}
+
+// RestorePurchaseOrder restores a deleted purchase order
+func (uc *UnicommerceController) RestorePurchaseOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+	correlationID := c.GetHeader("X-Correlation-ID")
+	if correlationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing correlation ID"})
+		return
+	}
+	ctx = context.WithValue(ctx, constants.CorrelationID, correlationID)
+
+	poNumber := c.DefaultQuery("poNumber", "")
+	if poNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing purchase order number"})
+		return
+	}
+
+	err := uc.Service.RestorePurchaseOrder(ctx, poNumber)
+	if err != nil {
+		if errors.Is(err, mongo_errors.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No deleted purchase order found"})
+			return
+		}
+		uc.Logger.Error("Error restoring purchase order", "error", err, "correlationID", correlationID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore purchase order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase order restored successfully"})
 }
 
 func (uc *UnicommerceController) GetPurchaseOrders(c *gin.Context) {
@@ -279,37 +432,117 @@ func (uc *UnicommerceController) GetPurchaseOrders(c *gin.Context) {
 		limit = 10
 	}
 
-	// Fetch purchase orders
-	purchaseOrdersPtr, total, err := uc.Service.GetPurchaseOrders(ctx, orderNumber, pageNumber, limit)
+	// Fetch purchase orders, a cursor from a previous page takes precedence over the page number
+	page, err := uc.Service.GetPurchaseOrders(ctx, orderNumber, mongo_models.PaginationOptions{
+		Page:     int64(pageNumber),
+		PageSize: int64(limit),
+		Cursor:   c.Query("cursor"),
+	})
 	if err != nil {
+		if errors.Is(err, mongo_errors.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		uc.Logger.Error("Error fetching purchase orders", "error", err, "correlationID", correlationID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase orders"})
 		return
 	}
 
-	if len(purchaseOrdersPtr) == 0 {
+	if len(page.Items) == 0 {
 		uc.Logger.Info("No purchase orders found", "orderNumber", orderNumber, "correlationID", correlationID)
-		c.JSON(http.StatusOK, gin.H{"data": []models.PurchaseOrder{}, "total": 0, "page": pageNumber, "limit": limit})
+		c.JSON(http.StatusOK, gin.H{"data": []models.PurchaseOrder{}, "total": page.Total, "page": pageNumber, "limit": limit})
 		return
 	}
 
-	purchaseOrders := make([]models.PurchaseOrder, len(purchaseOrdersPtr))
-	for i, p := range purchaseOrdersPtr {
+	purchaseOrders := make([]models.PurchaseOrder, len(page.Items))
+	for i, p := range page.Items {
 		purchaseOrders[i] = *p
 	}
 
+	// A single order read by its number carries the version to send back in If-Match
+	if orderNumber != "" && len(purchaseOrders) == 1 {
+		c.Header("ETag", etag(purchaseOrders[0].Version))
+	}
+
 	response := struct {
-		Data  []models.PurchaseOrder `json:"data"`
-		Total int                    `json:"total"`
-		Page  int                    `json:"page"`
-		Limit int                    `json:"limit"`
+		Data       []models.PurchaseOrder `json:"data"`
+		Total      int                    `json:"total"`
+		Page       int                    `json:"page"`
+		Limit      int                    `json:"limit"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+		PrevCursor string                 `json:"prev_cursor,omitempty"`
 	}{
-		Data:  purchaseOrders,
-		Total: int(total),
-		Page:  pageNumber,
-		Limit: limit,
+		Data:       purchaseOrders,
+		Total:      int(page.Total),
+		Page:       pageNumber,
+		Limit:      limit,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
 	}
 
-	uc.Logger.Info("Successfully fetched purchase orders", "total", total, "page", pageNumber, "limit", limit, "correlationID", correlationID)
+	uc.Logger.Info("Successfully fetched purchase orders", "total", page.Total, "page", pageNumber, "limit", limit, "correlationID", correlationID)
 	c.JSON(http.StatusOK, response)
 }
+
+// GetPurchaseOrderTotals returns purchase order totals per vendor and month, optionally limited to
+// the orders placed between the from and to RFC3339 times
+func (uc *UnicommerceController) GetPurchaseOrderTotals(c *gin.Context) {
+	var from, to time.Time
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC3339 time"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC3339 time"})
+			return
+		}
+	}
+
+	totals, err := uc.Service.GetPurchaseOrderTotals(c.Request.Context(), from, to)
+	if err != nil {
+		uc.Logger.Error("Error fetching purchase order totals", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase order totals"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": totals})
+}
+
+// GetProductsPerVendor returns the number of products of every primary vendor
+func (uc *UnicommerceController) GetProductsPerVendor(c *gin.Context) {
+	counts, err := uc.Service.GetProductsPerVendor(c.Request.Context())
+	if err != nil {
+		uc.Logger.Error("Error fetching products per vendor", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products per vendor"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": counts})
+}
+
+// etag formats a document version as a strong entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the version of an If-Match header, or nil when it is missing or matches any version
+func parseIfMatch(header string) (*int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	// An entity tag is an opaque string in double quotes, the tags of etag hold a version
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return nil, errors.New("entity tag must be a double quoted string")
+	}
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 63)
+	if err != nil {
+		return nil, err
+	}
+	parsed := int64(version)
+	return &parsed, nil
+}
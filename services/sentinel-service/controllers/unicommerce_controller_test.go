@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		header   string
+		expected *int64
+		invalid  bool
+	}{
+		{"missing", "", nil, false},
+		{"any version", "*", nil, false},
+		{"version", `"3"`, ptr(int64(3)), false},
+		{"weak tag", ` W/"7" `, ptr(int64(7)), false},
+		{"unquoted", "3", nil, true},
+		{"not a version", `"abc"`, nil, true},
+		{"backquoted", "`3`", nil, true},
+		{"escaped", `"\x33"`, nil, true},
+		{"signed", `"-3"`, nil, true},
+		{"unterminated", `"3`, nil, true},
+		{"quote only", `"`, nil, true},
+	}
+
+	for _, tc := range cases {
+		version, err := parseIfMatch(tc.header)
+		if (err != nil) != tc.invalid {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if (version == nil) != (tc.expected == nil) || (version != nil && *version != *tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, version)
+		}
+	}
+}
+
+func TestETagRoundTrip(t *testing.T) {
+	for _, version := range []int64{0, 1, 42} {
+		parsed, err := parseIfMatch(etag(version))
+		if err != nil || parsed == nil || *parsed != version {
+			t.Errorf("version %d: expected it back from %s, got %v (%v)", version, etag(version), parsed, err)
+		}
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
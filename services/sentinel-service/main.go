@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/himdhiman/dashboard-backend/libs/cache"
 	"github.com/himdhiman/dashboard-backend/libs/crypto"
 	"github.com/himdhiman/dashboard-backend/libs/logger"
 	"github.com/himdhiman/dashboard-backend/libs/mongo"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
 	"github.com/himdhiman/dashboard-backend/libs/task"
 	"github.com/joho/godotenv"
 
@@ -104,6 +108,13 @@ func main() {
 		return
 	}
 
+	// reload configs edited in the database while the service runs
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	if err := worker.WatchConfig(watchCtx, collection, cache, logger); err != nil {
+		logger.Warn("Config changes will only be picked up on restart", "error", err)
+	}
+
 	worker.StartConfigSync(collection, cache, logger)
 
 	googleSheetsService := services.NewGoogleSheetsService(spreadsheetID, sheetName, credentials, logger)
@@ -123,7 +134,17 @@ func main() {
 		logger.Fatal("Failed to connect to Collection", "error", err)
 	}
 
-	unicommerceService := services.NewUnicommerceService(tokenManager, googleSheetsService, logger, collection, po_collection)
+	collectionName = "sentinel_counters"
+	countersCollection, err := mongoClient.GetCollection(context.Background(), collectionName)
+	if err != nil {
+		logger.Fatal("Failed to connect to Collection", "error", err)
+	}
+
+	unicommerceService := services.NewUnicommerceService(tokenManager, googleSheetsService, logger, mongoClient, collection, po_collection, countersCollection)
+	if err := unicommerceService.InitPurchaseOrders(ctx); err != nil {
+		logger.Fatal("Failed to initialize purchase orders", "error", err)
+	}
+	unicommerceService.RegisterTasks()
 
 	taskCollectionName := "sentinel_tasks"
 	collection, err = mongoClient.GetCollection(context.Background(), taskCollectionName)
@@ -131,7 +152,13 @@ func main() {
 		logger.Fatal("Failed to connect to Collection", "error", err)
 	}
 
-	taskManager := task.NewTaskManager(collection, logger)
+	taskManager := task.NewTaskManager(task.TaskManagerConfig{
+		Collection:      collection,
+		Workers:         4,
+		RetentionPeriod: 30 * 24 * time.Hour,
+		Logger:          logger,
+	})
+	taskManager.Start()
 
 	exportJobSchedulerCollectionName := "sentinel_schedulers"
 	collection, err = mongoClient.GetCollection(context.Background(), exportJobSchedulerCollectionName)
@@ -139,15 +166,48 @@ func main() {
 		logger.Fatal("Failed to connect to Collection", "error", err)
 	}
 
-	exportJobScheduler := schedulers.NewExportJobScheduler(collection, unicommerceService, logger)
-	exportJobScheduler.Start(ctx)
+	schedulerRunsCollectionName := "sentinel_scheduler_runs"
+	runsCollection, err := mongoClient.GetCollection(context.Background(), schedulerRunsCollectionName)
+	if err != nil {
+		logger.Fatal("Failed to connect to Collection", "error", err)
+	}
+
+	workflowRunsCollectionName := "sentinel_workflow_runs"
+	workflowRunsCollection, err := mongoClient.GetCollection(context.Background(), workflowRunsCollectionName)
+	if err != nil {
+		logger.Fatal("Failed to connect to Collection", "error", err)
+	}
 
-	// start invetory snapshot scheduler
-	inventorySnapShotScheduler := schedulers.NewInventorySnapShotScheduler(collection, unicommerceService, logger)
-	inventorySnapShotScheduler.Start(ctx)
+	// A single scheduler owns every job in the collection so orphaned jobs can be detected
+	jobScheduler := scheduler.NewScheduler(scheduler.SchedulerConfig{
+		RetentionPeriod:    7 * 24 * time.Hour,
+		Collection:         collection,
+		RunCollection:      runsCollection,
+		WorkflowCollection: workflowRunsCollection,
+		DeadLetterHook: func(ctx context.Context, job *scheduler.Job, err error) {
+			logger.Error("Scheduled job exhausted its retries", "job", job.Name, "retries", job.MaxRetries, "error", err)
+		},
+		Logger: logger,
+	})
+
+	// register the export workflow before Start so its interrupted runs are resumed
+	exportWorkflow := schedulers.NewExportWorkflow(jobScheduler, unicommerceService, logger)
+	exportWorkflow.Register()
+
+	// register invetory snapshot job
+	inventorySnapShotScheduler := schedulers.NewInventorySnapShotScheduler(jobScheduler, unicommerceService, logger)
+	inventorySnapShotScheduler.Register(ctx)
+
+	// deleted products and purchase orders can be restored for 30 days
+	purgeDeletedScheduler := schedulers.NewPurgeDeletedScheduler(jobScheduler, unicommerceService, logger, 30*24*time.Hour)
+	purgeDeletedScheduler.Register(ctx)
+
+	// jobs defined in the database are loaded on start and kept in sync afterwards
+	jobScheduler.Start()
+	jobScheduler.ReportOrphanedJobs(ctx)
 
 	// Set up router
-	router := routes.SetupRouter(logger, unicommerceService, taskManager)
+	router := routes.SetupRouter(logger, unicommerceService, taskManager, jobScheduler)
 
 	// Start the server
 	srv := &http.Server{
@@ -155,7 +215,31 @@ func main() {
 		Handler: router,
 	}
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Fatal("listen: ", err)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("listen: ", err)
+		}
+	}()
+
+	// Wait for a termination signal, then stop serving and drain running jobs
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-signalCtx.Done()
+
+	logger.Info("Shutting down Sentinel-Service")
+	stopWatching()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down HTTP server gracefully", "error", err)
+	}
+
+	if err := jobScheduler.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down scheduler gracefully", "error", err)
+	}
+
+	if err := taskManager.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down task manager gracefully", "error", err)
 	}
 }
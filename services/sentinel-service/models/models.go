@@ -50,13 +50,14 @@ type TokenResponse struct {
 }
 
 type Product struct {
-	SKUCode              string    `json:"skuCode" bson:"skuCode" validate:"required"`
-	Name                 string    `json:"name" bson:"name" validate:"required"`
-	ImageURL             string    `json:"imageUrl" bson:"imageUrl" validate:"required,url"`
-	PrimaryVendor        string    `json:"primaryVendor" bson:"primaryVendor" validate:"required"`
-	LastProcuredRmbPrice float64   `json:"lastProcuredRmbPrice" bson:"lastProcuredRmbPrice" validate:"required,min=0"`
-	CreatedAt            time.Time `json:"createdAt" bson:"createdAt" validate:"required"`
-	UpdatedAt            time.Time `json:"updatedAt" bson:"updatedAt" validate:"required"`
+	SKUCode              string     `json:"skuCode" bson:"skuCode" validate:"required"`
+	Name                 string     `json:"name" bson:"name" validate:"required"`
+	ImageURL             string     `json:"imageUrl" bson:"imageUrl" validate:"required,url"`
+	PrimaryVendor        string     `json:"primaryVendor" bson:"primaryVendor" validate:"required"`
+	LastProcuredRmbPrice float64    `json:"lastProcuredRmbPrice" bson:"lastProcuredRmbPrice" validate:"required,min=0"`
+	CreatedAt            time.Time  `json:"createdAt" bson:"createdAt" validate:"required"`
+	UpdatedAt            time.Time  `json:"updatedAt" bson:"updatedAt" validate:"required"`
+	DeletedAt            *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
 
 type PurchaseOrderProducts struct {
@@ -82,4 +83,23 @@ type PurchaseOrder struct {
 	OrderType             string                  `json:"orderType" bson:"orderType" validate:"required,oneof=new repeat"`
 	Remarks               string                  `json:"remarks" bson:"remarks"`
 	UpdatedAt             time.Time               `json:"updatedAt" bson:"updatedAt" validate:"required"`
+	DeletedAt             *time.Time              `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	// Version is incremented by every update, clients send it back in If-Match
+	Version int64 `json:"version" bson:"version"`
+}
+
+// VendorMonthlyTotal is the purchase order total of a vendor for one month
+type VendorMonthlyTotal struct {
+	Vendor      string  `json:"vendor" bson:"vendor"`
+	Year        int     `json:"year" bson:"year"`
+	Month       int     `json:"month" bson:"month"`
+	Orders      int     `json:"orders" bson:"orders"`
+	TotalAmount float64 `json:"totalAmount" bson:"totalAmount"`
+	Deposits    float64 `json:"deposits" bson:"deposits"`
+}
+
+// VendorProductCount is the number of products of a primary vendor
+type VendorProductCount struct {
+	Vendor   string `json:"vendor" bson:"vendor"`
+	Products int    `json:"products" bson:"products"`
 }
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/himdhiman/dashboard-backend/libs/logger"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
 	"github.com/himdhiman/dashboard-backend/libs/task"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/controllers"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/services"
@@ -12,7 +13,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Correlation-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Correlation-ID, If-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -24,7 +26,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-func SetupRouter(logger logger.ILogger, unicommerceService *services.UnicommerceService, taskManager *task.TaskManager) *gin.Engine {
+func SetupRouter(logger logger.ILogger, unicommerceService *services.UnicommerceService, taskManager *task.TaskManager, jobScheduler *scheduler.Scheduler) *gin.Engine {
 	router := gin.Default()
 
 	// Add CORS middleware
@@ -33,9 +35,12 @@ func SetupRouter(logger logger.ILogger, unicommerceService *services.Unicommerce
 	controller := controllers.NewController(logger, taskManager)
 
 	router.GET("/health", controller.HealthCheck)
+	router.GET("/tasks", controller.ListTasks)
 	router.GET("/tasks/:task_id", controller.GetTaskStatus)
+	router.GET("/tasks/:task_id/events", controller.StreamTask)
+	router.DELETE("/tasks/:task_id", controller.CancelTask)
 
-	unicommerceController := controllers.NewUnicommerceController(logger, unicommerceService, taskManager)
+	unicommerceController := controllers.NewUnicommerceController(logger, unicommerceService, taskManager, jobScheduler)
 
 	router.GET("/unicommerce/products", unicommerceController.GetProducts)
 	// router.POST("/unicommerce/products/fetch", unicommerceController.FetchProducts)
@@ -47,6 +52,14 @@ func SetupRouter(logger logger.ILogger, unicommerceService *services.Unicommerce
 	router.GET("/purchase-order", unicommerceController.GetPurchaseOrders)
 	router.POST("/purchase-order", unicommerceController.CreatePurchaseOrder)
 	router.PUT("/purchase-orders", unicommerceController.UpdatePurchaseOrder)
+	router.GET("/purchase-orders/events", unicommerceController.StreamPurchaseOrders)
+	router.DELETE("/purchase-orders", unicommerceController.DeletePurchaseOrder)
+	router.POST("/purchase-orders/restore", unicommerceController.RestorePurchaseOrder)
+
+	router.GET("/dashboard/purchase-orders/totals", unicommerceController.GetPurchaseOrderTotals)
+	router.GET("/dashboard/products/vendors", unicommerceController.GetProductsPerVendor)
+
+	SetupSchedulerRoutes(router.Group("/admin/scheduler"), logger, jobScheduler)
 
 	return router
 }
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
+	"github.com/himdhiman/dashboard-backend/services/sentinel-service/controllers"
+)
+
+// SetupSchedulerRoutes mounts the scheduler admin endpoints on the given router group
+func SetupSchedulerRoutes(group *gin.RouterGroup, logger logger.ILogger, jobScheduler *scheduler.Scheduler) {
+	controller := controllers.NewSchedulerController(logger, jobScheduler)
+
+	group.GET("/preview", controller.PreviewSchedule)
+	group.GET("/jobs", controller.ListJobs)
+	group.GET("/jobs/:name", controller.GetJob)
+	group.GET("/jobs/:name/runs", controller.GetJobRuns)
+	group.POST("/jobs/:name/pause", controller.PauseJob)
+	group.POST("/jobs/:name/resume", controller.ResumeJob)
+	group.POST("/jobs/:name/trigger", controller.TriggerJob)
+	group.POST("/jobs/:name/cancel", controller.CancelJob)
+	group.PUT("/jobs/:name/schedule", controller.RescheduleJob)
+
+	group.GET("/workflows/runs", controller.GetWorkflowRuns)
+	group.GET("/workflows/runs/:run_id", controller.GetWorkflowRun)
+	group.POST("/workflows/:name/runs", controller.StartWorkflow)
+}
@@ -0,0 +1,114 @@
+package schedulers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
+	"github.com/himdhiman/dashboard-backend/services/sentinel-service/services"
+)
+
+// ExportWorkflowName is the workflow that imports the Unicommerce item master into mongo
+const ExportWorkflowName = "product-export"
+
+type ExportWorkflow struct {
+	scheduler *scheduler.Scheduler
+	service   *services.UnicommerceService
+	logger    logger.ILogger
+}
+
+func NewExportWorkflow(jobScheduler *scheduler.Scheduler, service *services.UnicommerceService, logger logger.ILogger) *ExportWorkflow {
+	return &ExportWorkflow{
+		scheduler: jobScheduler,
+		service:   service,
+		logger:    logger,
+	}
+}
+
+// Register declares the export pipeline: create the export job, wait for it to complete, then import the CSV
+func (e *ExportWorkflow) Register() error {
+	workflow := scheduler.Workflow{
+		Name:      ExportWorkflowName,
+		Exclusive: true,
+		Steps: []scheduler.WorkflowStep{
+			{
+				Name:       "create-export-job",
+				Run:        e.createExportJob,
+				MaxRetries: 3,
+				RetryPolicy: &scheduler.RetryPolicy{
+					Strategy:        scheduler.RetryStrategyFixed,
+					InitialInterval: 30 * time.Second,
+				},
+				Timeout: 2 * time.Minute,
+			},
+			{
+				Name:       "await-export",
+				Run:        e.awaitExport,
+				DependsOn:  []string{"create-export-job"},
+				MaxRetries: 3,
+				Timeout:    2 * time.Minute,
+				MaxWait:    2 * time.Hour,
+			},
+			{
+				Name:       "import-products",
+				Run:        e.importProducts,
+				DependsOn:  []string{"await-export"},
+				MaxRetries: 2,
+				Timeout:    10 * time.Minute,
+			},
+		},
+	}
+
+	if err := e.scheduler.RegisterWorkflow(workflow); err != nil {
+		e.logger.Error("Failed to register export workflow", "error", err)
+		return err
+	}
+	return nil
+}
+
+// createExportJob asks Unicommerce to export the item master
+func (e *ExportWorkflow) createExportJob(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	job, err := e.service.CreateExportJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e.logger.Info("Created export job", "jobCode", job.JobCode)
+	return map[string]interface{}{"job_code": job.JobCode}, nil
+}
+
+// awaitExport polls the export job until its file is ready
+func (e *ExportWorkflow) awaitExport(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	jobCode, _ := params["job_code"].(string)
+	if jobCode == "" {
+		return nil, errors.New("export job code is missing")
+	}
+
+	status, err := e.service.GetExportJobStatus(ctx, jobCode)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Successful {
+		return nil, errors.New("fetching export job status failed: " + status.Message)
+	}
+	if status.Status != "COMPLETE" {
+		return nil, scheduler.RetryAfter(time.Minute, "export job "+jobCode+" is "+status.Status)
+	}
+
+	return map[string]interface{}{"file_path": status.FilePath}, nil
+}
+
+// importProducts saves the exported products in mongo
+func (e *ExportWorkflow) importProducts(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	filePath, _ := params["file_path"].(string)
+	if filePath == "" {
+		return nil, errors.New("export file path is missing")
+	}
+
+	if err := e.service.ImportProductsFromExport(ctx, filePath); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
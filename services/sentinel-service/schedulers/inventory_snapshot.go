@@ -6,7 +6,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/himdhiman/dashboard-backend/libs/logger"
-	"github.com/himdhiman/dashboard-backend/libs/mongo/models"
 	"github.com/himdhiman/dashboard-backend/libs/scheduler"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/constants"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/services"
@@ -18,58 +17,63 @@ type InventorySnapShotScheduler struct {
 	logger    logger.ILogger
 }
 
-func NewInventorySnapShotScheduler(collection *models.MongoCollection, service *services.UnicommerceService, logger logger.ILogger) *InventorySnapShotScheduler {
-	config := scheduler.SchedulerConfig{
-		RetentionPeriod: 24 * time.Hour,
-		Collection:      collection,
-		Logger:          logger,
-	}
-
+func NewInventorySnapShotScheduler(jobScheduler *scheduler.Scheduler, service *services.UnicommerceService, logger logger.ILogger) *InventorySnapShotScheduler {
 	return &InventorySnapShotScheduler{
-		scheduler: scheduler.NewScheduler(config),
+		scheduler: jobScheduler,
 		service:   service,
 		logger:    logger,
 	}
 }
 
-func (e *InventorySnapShotScheduler) Start(ctx context.Context) error {
+// Register makes the inventory snapshot available to the scheduler and seeds its definition.
+// Once defined, the cadence is read from the sentinel_schedulers collection.
+func (e *InventorySnapShotScheduler) Register(ctx context.Context) error {
+	scheduler.RegisterHandler("snapshot-inventory", e.snapshot)
+
 	config := scheduler.JobConfig{
-		Name:        "snapshot-inventory",
-		CronExpr:    "0 */30 * * * *", // For every 30 minutes
-		Params:      map[string]interface{}{},
-		MaxRetries:  3,
+		Name:       "snapshot-inventory",
+		Handler:    "snapshot-inventory",
+		CronExpr:   "0 */30 * * * *", // For every 30 minutes
+		Params:     map[string]interface{}{},
+		MaxRetries: 3,
+		RetryPolicy: &scheduler.RetryPolicy{
+			Strategy:        scheduler.RetryStrategyJittered,
+			InitialInterval: 15 * time.Second,
+			MaxInterval:     2 * time.Minute,
+			Multiplier:      2,
+		},
+		Timeout:     10 * time.Minute,
 		IsRecurring: true,
-	}
 
-	e.logger.Info("Configuring scheduled job", "jobName", config.Name, "cronExpr", config.CronExpr)
+		ConcurrencyPolicy: scheduler.ConcurrencyPolicySkipIfRunning,
+		MisfirePolicy:     scheduler.MisfirePolicyRunOnce,
+	}
 
-	err := e.scheduler.Schedule(ctx, config, func(ctx context.Context, params map[string]interface{}) error {
-		correlationID := uuid.New().String()
-		e.logger.Info("Starting scheduled job", "correlationID", correlationID)
-		ctx = context.WithValue(ctx, constants.CorrelationID, correlationID)
+	e.logger.Info("Defining scheduled job", "jobName", config.Name, "cronExpr", config.CronExpr)
 
-		e.logger.Info("Calling UpdateInventoryFromGoogleSheet", "correlationID", correlationID)
-		err := e.service.UpdateInventoryFromGoogleSheet(ctx)
-		if err != nil {
-			e.logger.Error("UpdateInventoryFromGoogleSheet failed", "correlationID", correlationID, "error", err)
-			return err
-		}
+	if err := e.scheduler.DefineJob(ctx, config); err != nil {
+		e.logger.Error("Failed to define inventory snapshot job", "error", err)
+		return err
+	}
+	return nil
+}
 
-		e.logger.Info("UpdateInventoryFromGoogleSheet succeeded", "correlationID", correlationID)
-		return nil
-	})
+// snapshot refreshes the inventory from the Google sheet
+func (e *InventorySnapShotScheduler) snapshot(ctx context.Context, params map[string]interface{}) error {
+	correlationID, ok := scheduler.CorrelationIDFromContext(ctx)
+	if !ok {
+		correlationID = uuid.New().String()
+	}
+	e.logger.Info("Starting scheduled job", "correlationID", correlationID)
+	ctx = context.WithValue(ctx, constants.CorrelationID, correlationID)
 
+	e.logger.Info("Calling UpdateInventoryFromGoogleSheet", "correlationID", correlationID)
+	err := e.service.UpdateInventoryFromGoogleSheet(ctx)
 	if err != nil {
-		e.logger.Error("Failed to schedule export job", "error", err)
+		e.logger.Error("UpdateInventoryFromGoogleSheet failed", "correlationID", correlationID, "error", err)
 		return err
 	}
 
-	e.logger.Info("Starting scheduler")
-	e.scheduler.Start()
-	e.logger.Info("Scheduler started successfully")
+	e.logger.Info("UpdateInventoryFromGoogleSheet succeeded", "correlationID", correlationID)
 	return nil
 }
-
-func (e *InventorySnapShotScheduler) Stop() {
-	e.scheduler.Stop()
-}
@@ -0,0 +1,59 @@
+package schedulers
+
+import (
+	"context"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/logger"
+	"github.com/himdhiman/dashboard-backend/libs/scheduler"
+	"github.com/himdhiman/dashboard-backend/services/sentinel-service/services"
+)
+
+type PurgeDeletedScheduler struct {
+	scheduler *scheduler.Scheduler
+	service   *services.UnicommerceService
+	logger    logger.ILogger
+	retention time.Duration
+}
+
+// NewPurgeDeletedScheduler returns a scheduler removing products and purchase orders deleted more
+// than retention ago
+func NewPurgeDeletedScheduler(jobScheduler *scheduler.Scheduler, service *services.UnicommerceService, logger logger.ILogger, retention time.Duration) *PurgeDeletedScheduler {
+	return &PurgeDeletedScheduler{
+		scheduler: jobScheduler,
+		service:   service,
+		logger:    logger,
+		retention: retention,
+	}
+}
+
+// Register makes the purge available to the scheduler and seeds its definition.
+// Once defined, the cadence is read from the sentinel_schedulers collection.
+func (e *PurgeDeletedScheduler) Register(ctx context.Context) error {
+	scheduler.RegisterHandler("purge-deleted", e.purge)
+
+	config := scheduler.JobConfig{
+		Name:        "purge-deleted",
+		Handler:     "purge-deleted",
+		CronExpr:    "0 0 3 * * *", // Every day at 3 AM
+		Params:      map[string]interface{}{},
+		MaxRetries:  2,
+		Timeout:     30 * time.Minute,
+		IsRecurring: true,
+
+		ConcurrencyPolicy: scheduler.ConcurrencyPolicySkipIfRunning,
+		MisfirePolicy:     scheduler.MisfirePolicyRunOnce,
+	}
+
+	e.logger.Info("Defining scheduled job", "jobName", config.Name, "cronExpr", config.CronExpr)
+
+	if err := e.scheduler.DefineJob(ctx, config); err != nil {
+		e.logger.Error("Failed to define purge job", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (e *PurgeDeletedScheduler) purge(ctx context.Context, params map[string]interface{}) error {
+	return e.service.PurgeDeleted(ctx, e.retention)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/himdhiman/dashboard-backend/libs/mongo/aggregate"
+	q "github.com/himdhiman/dashboard-backend/libs/mongo/query"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"github.com/himdhiman/dashboard-backend/services/sentinel-service/models"
+)
+
+// GetPurchaseOrderTotals sums purchase orders per vendor and month, newest month first. Zero from
+// or to leave the order date range open.
+func (s *UnicommerceService) GetPurchaseOrderTotals(ctx context.Context, from, to time.Time) ([]models.VendorMonthlyTotal, error) {
+	var fromBound, toBound interface{}
+	if !from.IsZero() {
+		fromBound = from
+	}
+	if !to.IsZero() {
+		toBound = to
+	}
+
+	pipeline := aggregate.New().
+		Match(q.Range("orderDate", fromBound, toBound)).
+		Group(map[string]interface{}{
+			"vendor": aggregate.Field("vendor"),
+			"year":   map[string]interface{}{"$year": aggregate.Field("orderDate")},
+			"month":  map[string]interface{}{"$month": aggregate.Field("orderDate")},
+		}, map[string]interface{}{
+			"orders":      aggregate.Sum(1),
+			"totalAmount": aggregate.Sum(aggregate.Field("totalAmount")),
+			"deposits":    aggregate.Sum(aggregate.Field("deposits")),
+		}).
+		Project(map[string]interface{}{
+			"_id":         0,
+			"vendor":      aggregate.Field("_id.vendor"),
+			"year":        aggregate.Field("_id.year"),
+			"month":       aggregate.Field("_id.month"),
+			"orders":      1,
+			"totalAmount": 1,
+			"deposits":    1,
+		}).
+		Sort(aggregate.Desc("year"), aggregate.Desc("month"), aggregate.Asc("vendor"))
+
+	totals, err := repository.Aggregate[models.VendorMonthlyTotal](ctx, s.PurchaseOrderRepository, pipeline)
+	if err != nil {
+		s.Logger.Error("Error aggregating purchase order totals", "error", err)
+		return nil, err
+	}
+	return totals, nil
+}
+
+// GetProductsPerVendor counts the products of every primary vendor, largest first
+func (s *UnicommerceService) GetProductsPerVendor(ctx context.Context) ([]models.VendorProductCount, error) {
+	pipeline := aggregate.New().
+		Group(aggregate.Field("primaryVendor"), map[string]interface{}{
+			"products": aggregate.Sum(1),
+		}).
+		Project(map[string]interface{}{
+			"_id":      0,
+			"vendor":   aggregate.Field("_id"),
+			"products": 1,
+		}).
+		Sort(aggregate.Desc("products"), aggregate.Asc("vendor"))
+
+	counts, err := repository.Aggregate[models.VendorProductCount](ctx, s.ProductsRepository, pipeline)
+	if err != nil {
+		s.Logger.Error("Error aggregating products per vendor", "error", err)
+		return nil, err
+	}
+	return counts, nil
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"iter"
 	"net/http"
 	"reflect"
 	"regexp"
@@ -15,15 +16,19 @@ import (
 	"strings"
 	"time"
 
-	validator "github.com/go-playground/validator/v10"
 	"github.com/himdhiman/dashboard-backend/libs/cache"
 	"github.com/himdhiman/dashboard-backend/libs/logger"
+	"github.com/himdhiman/dashboard-backend/libs/mongo"
 	mongo_errors "github.com/himdhiman/dashboard-backend/libs/mongo/errors"
+	"github.com/himdhiman/dashboard-backend/libs/mongo/hooks"
 	mongo_models "github.com/himdhiman/dashboard-backend/libs/mongo/models"
+	q "github.com/himdhiman/dashboard-backend/libs/mongo/query"
 	"github.com/himdhiman/dashboard-backend/libs/mongo/repository"
+	"github.com/himdhiman/dashboard-backend/libs/task"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/auth"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/constants"
 	"github.com/himdhiman/dashboard-backend/services/sentinel-service/models"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type UnicommerceService struct {
@@ -31,23 +36,30 @@ type UnicommerceService struct {
 	Logger                  logger.ILogger
 	TokenManager            *auth.TokenManager
 	GoogleSheetService      *GoogleSheetsService
+	MongoClient             mongo.IMongoClient
 	ProductsRepository      *repository.Repository[models.Product]
 	PurchaseOrderRepository *repository.Repository[models.PurchaseOrder]
+	PONumbers               *repository.Sequence
 }
 
-func NewUnicommerceService(tokenManager *auth.TokenManager, sheetService *GoogleSheetsService, logger logger.ILogger, productsCollection *mongo_models.MongoCollection, po_collections *mongo_models.MongoCollection) *UnicommerceService {
+func NewUnicommerceService(tokenManager *auth.TokenManager, sheetService *GoogleSheetsService, logger logger.ILogger, mongoClient mongo.IMongoClient, productsCollection *mongo_models.MongoCollection, po_collections *mongo_models.MongoCollection, countersCollection *mongo_models.MongoCollection) *UnicommerceService {
 
-	productsRepo := repository.Repository[models.Product]{Collection: productsCollection}
+	// Imported products carry no price yet, only purchase orders are validated on write
+	productsRepo := repository.Repository[models.Product]{Collection: productsCollection, SoftDelete: true}
+	productsRepo.RegisterHooks(hooks.NewTimestampHook("updatedAt"))
 
-	purchaseOrderRepo := repository.Repository[models.PurchaseOrder]{Collection: po_collections}
+	purchaseOrderRepo := repository.Repository[models.PurchaseOrder]{Collection: po_collections, SoftDelete: true}
+	purchaseOrderRepo.RegisterHooks(hooks.NewTimestampHook("updatedAt"), hooks.NewValidationHook())
 
 	return &UnicommerceService{
 		ServiceCode:             constants.UNICOM_API_CODE,
 		TokenManager:            tokenManager,
 		GoogleSheetService:      sheetService,
 		Logger:                  logger,
+		MongoClient:             mongoClient,
 		ProductsRepository:      &productsRepo,
 		PurchaseOrderRepository: &purchaseOrderRepo,
+		PONumbers:               repository.NewSequence(countersCollection, "poNumber"),
 	}
 }
 
@@ -157,7 +169,7 @@ func (s *UnicommerceService) CreateExportJob(ctx context.Context) (*ExportJobRes
 
 	if resp.StatusCode != http.StatusOK {
 		s.Logger.Error("Error creating export job", "status", resp.StatusCode)
-		return nil, err
+		return nil, fmt.Errorf("creating export job failed with status %d", resp.StatusCode)
 	}
 
 	respBody, err := ioutil.ReadAll(resp.Body)
@@ -174,127 +186,99 @@ func (s *UnicommerceService) CreateExportJob(ctx context.Context) (*ExportJobRes
 
 	if !exportJobResponse.Successful {
 		s.Logger.Error("Error creating export job", "message", exportJobResponse.Message)
-		return nil, err
-	}
-
-	err = s.TokenManager.Cache.Set(ctx, s.ServiceCode+":"+constants.EXPORT_JOB_CODE, exportJobResponse.JobCode, 0)
-	if err != nil {
-		s.Logger.Error("Error setting export job code in cache", "error", err)
-		return nil, err
+		return nil, errors.New("creating export job failed: " + exportJobResponse.Message)
 	}
 
 	return &exportJobResponse, nil
 }
 
-// check the job status and spin the task to read the data from csv and save it in mongo
-func (s *UnicommerceService) CheckExportJobStatus(ctx context.Context) error {
-	jobCode, err := s.fetchFromCache(ctx, constants.EXPORT_JOB_CODE, "")
+// ImportProductsFromExport downloads the CSV produced by a completed export job and saves its simple products in mongo
+func (s *UnicommerceService) ImportProductsFromExport(ctx context.Context, fileURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
 	if err != nil {
-		s.Logger.Error("Error fetching export job code from cache", "error", err)
+		s.Logger.Error("Error creating request for export file", "error", err)
 		return err
 	}
 
-	s.Logger.Info("Checking export job status", "jobCode", jobCode)
-	exportJobStatusResponse, cacheError := s.getExportJobStatus(ctx, jobCode)
-	if cacheError != nil {
-		s.Logger.Error("Error fetching export job status", "error", err)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.Logger.Error("Error downloading file from URL", "error", err)
 		return err
 	}
-	if !exportJobStatusResponse.Successful {
-		s.Logger.Error("Error fetching export job status", "message", exportJobStatusResponse.Message)
-		return err
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		s.Logger.Error("Error downloading file", "status", resp.StatusCode)
+		return fmt.Errorf("downloading export file failed with status %d", resp.StatusCode)
 	}
 
-	if exportJobStatusResponse.Status == "COMPLETE" {
-		// we have the file url, we can now read the file iterate over each row and save it in mongo
-		// we can use the file path to read the file
+	fileBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		s.Logger.Error("Error reading file content", "error", err)
+		return err
+	}
 
-		// we have the aws file path, we can now read the file and save it in mongo
-		fileURL := exportJobStatusResponse.FilePath
-		resp, err := http.Get(fileURL)
-		if err != nil {
-			s.Logger.Error("Error downloading file from URL", "error", err)
-			return err
-		}
-		defer resp.Body.Close()
+	// Assuming the file is a CSV, we can parse it
+	r := csv.NewReader(bytes.NewReader(fileBytes))
+	records, err := r.ReadAll()
+	if err != nil {
+		s.Logger.Error("Error parsing CSV file", "error", err)
+		return err
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			s.Logger.Error("Error downloading file", "status", resp.StatusCode)
-			return err
-		}
+	return s.importProductRecords(ctx, records)
+}
 
-		fileBytes, err := ioutil.ReadAll(resp.Body)
+// importBatchSize is the number of products written by a single bulk write of an import
+const importBatchSize = 500
+
+// importProductRecords creates or updates the simple products of an export in bulk writes of
+// importBatchSize products, products are identified by their SKU code and primary vendor and deleted
+// products are restored. The upserts are idempotent, an import that fails part way is completed by
+// importing the export again.
+func (s *UnicommerceService) importProductRecords(ctx context.Context, records [][]string) error {
+	var created, updated int64
+	flush := func(bulk *repository.BulkWrite[models.Product]) error {
+		result, err := s.ProductsRepository.BulkWrite(ctx, bulk)
 		if err != nil {
-			s.Logger.Error("Error reading file content", "error", err)
+			s.Logger.Error("Error importing products", "error", err, "created", created, "updated", updated)
 			return err
 		}
+		created += result.UpsertedCount
+		updated += result.ModifiedCount
+		return nil
+	}
 
-		// Assuming the file is a CSV, we can parse it
-		r := csv.NewReader(bytes.NewReader(fileBytes))
-		records, err := r.ReadAll()
-		if err != nil {
-			s.Logger.Error("Error parsing CSV file", "error", err)
-			return err
+	bulk := repository.NewBulkWrite[models.Product]().Unordered()
+	for _, record := range records {
+		if record[3] != "SIMPLE" {
+			continue
 		}
 
-		for _, record := range records {
-			if record[3] != "SIMPLE" {
-				continue
-			}
-
-			var skuCode, name, imageURL, primaryVendor string
+		now := time.Now()
+		bulk.Upsert(
+			map[string]interface{}{"skuCode": record[0], "primaryVendor": record[5]},
+			map[string]interface{}{"name": record[1], "imageUrl": record[2], "updatedAt": now},
+			map[string]interface{}{"createdAt": now},
+		)
 
-			skuCode = record[0]
-			name = record[1]
-			imageURL = record[2]
-			primaryVendor = record[5]
-
-			// check if already exists, the update the product
-			// we can use the skuCode and primary vendor to check if the product already exists
-
-			products, err := s.ProductsRepository.Find(ctx, map[string]interface{}{"skuCode": skuCode, "primaryVendor": primaryVendor})
-			if err != nil {
-				s.Logger.Error("Error fetching products", "error", err)
-				return err
-			}
-			if len(products) > 0 {
-				// if the product already exists, we update the product
-				// we can use the skuCode and primary vendor to update the product
-				_, err = s.ProductsRepository.Update(ctx, map[string]interface{}{"name": name, "imageUrl": imageURL, "updatedAt": time.Now()}, products[0])
-				if err != nil {
-					s.Logger.Error("Error updating product", "error", err)
-					return err
-				}
-				continue
-			}
-
-			product := models.Product{
-				SKUCode:       skuCode,
-				Name:          name,
-				ImageURL:      imageURL,
-				PrimaryVendor: primaryVendor,
-				CreatedAt:     time.Now(),
-				UpdatedAt:     time.Now(),
-			}
-
-			_, err = s.ProductsRepository.Create(ctx, &product)
-			if err != nil {
-				s.Logger.Error("Error saving product to MongoDB", "error", err)
+		if bulk.Len() == importBatchSize {
+			if err := flush(bulk); err != nil {
 				return err
 			}
-		}
-
-		// we can now remove the job id from cache
-		err = s.TokenManager.Cache.Delete(ctx, s.ServiceCode+":"+constants.EXPORT_JOB_CODE)
-		if err != nil {
-			s.Logger.Error("Error deleting export job code from cache", "error", err)
-			return err
+			bulk = repository.NewBulkWrite[models.Product]().Unordered()
 		}
 	}
+	if err := flush(bulk); err != nil {
+		return err
+	}
+
+	s.Logger.Info("Imported products", "created", created, "updated", updated)
 	return nil
 }
 
-func (s *UnicommerceService) getExportJobStatus(ctx context.Context, exportJobCode string) (*ExportJobStatusResponse, error) {
+// GetExportJobStatus fetches the status of an export job, the file path is set once it is COMPLETE
+func (s *UnicommerceService) GetExportJobStatus(ctx context.Context, exportJobCode string) (*ExportJobStatusResponse, error) {
 	method, baseURL, path, timeout, err := s.fetchConfig(ctx, constants.API_CODE_UNICOM_EXPORT_JOB_STATUS)
 	if err != nil {
 		return nil, err
@@ -333,7 +317,7 @@ func (s *UnicommerceService) getExportJobStatus(ctx context.Context, exportJobCo
 
 	if resp.StatusCode != http.StatusOK {
 		s.Logger.Error("Error fetching export job status", "status", resp.StatusCode)
-		return nil, err
+		return nil, fmt.Errorf("fetching export job status failed with status %d", resp.StatusCode)
 	}
 
 	respBody, err := ioutil.ReadAll(resp.Body)
@@ -494,6 +478,16 @@ func (s *UnicommerceService) UpdateInventoryFromGoogleSheet(ctx context.Context)
 	return nil
 }
 
+// FetchProductsTask is the task type importing the product catalogue from Unicommerce
+const FetchProductsTask = "FetchProducts"
+
+// RegisterTasks registers the task types run by the service, they survive restarts of the process
+func (s *UnicommerceService) RegisterTasks() {
+	task.Register(FetchProductsTask, func(ctx context.Context, _ struct{}) (struct{}, error) {
+		return struct{}{}, s.FetchProducts(ctx)
+	}, task.WithMaxRetries(2, nil))
+}
+
 func (s *UnicommerceService) FetchProducts(ctx context.Context) error {
 	method, baseURL, path, timeout, err := s.fetchConfig(ctx, constants.API_CODE_UNICOM_FETCH_PRODUCTS)
 	if err != nil {
@@ -548,7 +542,10 @@ func (s *UnicommerceService) FetchProducts(ctx context.Context) error {
 		return err
 	}
 
-	for _, p := range responseData.Products {
+	total := int64(len(responseData.Products))
+	for i, p := range responseData.Products {
+		task.ReportProgress(ctx, task.Progress{Step: "import-products", Current: int64(i), Total: total})
+
 		product := models.Product{
 			SKUCode:  p.SKUCode,
 			Name:     p.Name,
@@ -561,7 +558,7 @@ func (s *UnicommerceService) FetchProducts(ctx context.Context) error {
 			}
 		}
 
-		products, err := s.ProductsRepository.Find(ctx, map[string]interface{}{"skuCode": product.SKUCode, "primaryVendor": product.PrimaryVendor})
+		products, err := s.ProductsRepository.FindWithDeleted(ctx, map[string]interface{}{"skuCode": product.SKUCode, "primaryVendor": product.PrimaryVendor})
 
 		if err != nil {
 			s.Logger.Error("Error fetching products", "error", err)
@@ -569,16 +566,17 @@ func (s *UnicommerceService) FetchProducts(ctx context.Context) error {
 		}
 
 		if len(products) == 0 {
-			product.CreatedAt = time.Now()
-			product.UpdatedAt = time.Now()
 			_, err = s.ProductsRepository.Create(ctx, &product)
 			if err != nil {
 				s.Logger.Error("Error creating product in DB", "error", err)
 				return err
 			}
 		} else {
-			// if the product already exists, we update the product
-			_, err = s.ProductsRepository.Update(ctx, map[string]interface{}{"name": product.Name, "imageUrl": product.ImageURL, "updatedAt": time.Now()}, product)
+			// if the product already exists, we update the product and restore it if it was deleted
+			_, err = s.ProductsRepository.Upsert(ctx,
+				map[string]interface{}{"skuCode": product.SKUCode, "primaryVendor": product.PrimaryVendor},
+				map[string]interface{}{"name": product.Name, "imageUrl": product.ImageURL},
+			)
 			if err != nil {
 				s.Logger.Error("Error updating product", "error", err)
 				return err
@@ -587,54 +585,36 @@ func (s *UnicommerceService) FetchProducts(ctx context.Context) error {
 
 	}
 
+	task.ReportProgress(ctx, task.Progress{Step: "import-products", Current: total, Total: total})
 	return nil
 }
 
-func (s *UnicommerceService) GetProducts(ctx context.Context, skuCode string, pageNumber int, fieldsPerPage int) ([]*models.Product, int64, error) {
+// GetProducts returns a page of products, pages are fetched by number or by the cursors of a previous page
+func (s *UnicommerceService) GetProducts(ctx context.Context, skuCode string, pagination mongo_models.PaginationOptions) (*mongo_models.Page[models.Product], error) {
 	filter := map[string]interface{}{}
 	if skuCode != "" {
 		filter["skuCode"] = skuCode
 	}
 
-	// Ensure pageNumber is at least 1
-	if pageNumber < 1 {
-		pageNumber = 1
-	}
-
-	products, err := s.ProductsRepository.Find(ctx, filter, &mongo_models.FindOptions{
-		Limit: int64(fieldsPerPage),
-		Skip:  int64((pageNumber - 1) * fieldsPerPage),
-	})
-
+	pagination.IncludeTotal = true
+	page, err := s.ProductsRepository.Paginate(ctx, filter, pagination)
 	if err != nil {
 		s.Logger.Error("Error fetching products", "error", err)
-		return nil, 0, err
-	}
-
-	cnt, err := s.ProductsRepository.Count(ctx, filter)
-	if err != nil {
-		s.Logger.Error("Error fetching products Count", "error", err)
-		return nil, 0, err
+		return nil, err
 	}
 
-	return products, cnt, nil
+	return page, nil
 }
 
 // Create a function to fetch the product by SKU code or by name with partial matching
-func (s *UnicommerceService) SearchProduct(ctx context.Context, skuCode string, name string) ([]*models.Product, error) {
-	filter := map[string]interface{}{}
-	if skuCode != "" {
-		filter["skuCode"] = map[string]interface{}{"$regex": skuCode, "$options": "i"}
-	}
-	if name != "" {
-		filter["name"] = map[string]interface{}{"$regex": name, "$options": "i"}
-	}
-	products, err := s.ProductsRepository.Find(ctx, filter)
-	if err != nil {
-		s.Logger.Error("Error fetching products", "error", err)
-		return nil, err
-	}
-	return products, nil
+// SearchProduct streams the products whose SKU code and name match the given patterns, an empty
+// pattern matches every product
+func (s *UnicommerceService) SearchProduct(ctx context.Context, skuCode string, name string) iter.Seq2[*models.Product, error] {
+	filter := q.And(
+		q.Regex("skuCode", skuCode, "i"),
+		q.Regex("name", name, "i"),
+	)
+	return s.ProductsRepository.All(ctx, filter, &mongo_models.FindOptions{BatchSize: 500})
 }
 
 // fetchFromCache retrieves the value from the cache
@@ -653,30 +633,46 @@ func (s *UnicommerceService) fetchFromCache(ctx context.Context, apiCode, key st
 	return strings.Trim(value, "\""), nil
 }
 
-// CreatePurchaseOrder creates a new purchase order with an incremental order number
-func (s *UnicommerceService) CreatePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
-	// Fetch the last purchase order to determine the next order number
-	lastOrder, err := s.PurchaseOrderRepository.FindOne(ctx, nil, &mongo_models.FindOptions{
-		Sort: map[string]interface{}{"poNumber": -1},
+// InitPurchaseOrders prepares the purchase order collection: order numbers are unique and the
+// sequence allocating them continues after the last existing order
+func (s *UnicommerceService) InitPurchaseOrders(ctx context.Context) error {
+	if err := s.PurchaseOrderRepository.CreateIndex(ctx, bson.D{{Key: "poNumber", Value: 1}}, true); err != nil {
+		s.Logger.Error("Error creating purchase order number index", "error", err)
+		return err
+	}
+
+	// Orders are numbered in creation order, deleted orders keep their number until they are purged
+	lastOrders, err := s.PurchaseOrderRepository.FindWithDeleted(ctx, nil, &mongo_models.FindOptions{
+		Sort:  map[string]interface{}{"_id": -1},
+		Limit: 1,
 	})
-	if err != nil && err != mongo_errors.ErrDocumentNotFound {
+	if err != nil {
 		s.Logger.Error("Error fetching last purchase order", "error", err)
 		return err
 	}
+	if len(lastOrders) == 0 {
+		return nil
+	}
 
-	// Determine the next order number
-	var nextOrderNumber int
-	if lastOrder != nil {
-		re := regexp.MustCompile(`\d+$`)
-		lastOrderNumberStr := re.FindString(lastOrder.PONumber)
-		lastOrderNumber, err := strconv.Atoi(lastOrderNumberStr)
-		if err != nil {
-			s.Logger.Error("Error converting last order number to integer", "error", err)
-			return err
-		}
-		nextOrderNumber = lastOrderNumber + 1
-	} else {
-		nextOrderNumber = 1
+	lastOrderNumber, err := strconv.ParseInt(regexp.MustCompile(`\d+$`).FindString(lastOrders[0].PONumber), 10, 64)
+	if err != nil {
+		s.Logger.Error("Error converting last order number to integer", "error", err)
+		return err
+	}
+	if err := s.PONumbers.AtLeast(ctx, lastOrderNumber); err != nil {
+		s.Logger.Error("Error seeding purchase order numbers", "error", err)
+		return err
+	}
+	return nil
+}
+
+// CreatePurchaseOrder creates a new purchase order with an incremental order number
+func (s *UnicommerceService) CreatePurchaseOrder(ctx context.Context, purchaseOrder *models.PurchaseOrder) error {
+	// The number is allocated atomically, concurrent orders never share a number
+	nextOrderNumber, err := s.PONumbers.Next(ctx)
+	if err != nil {
+		s.Logger.Error("Error allocating purchase order number", "error", err)
+		return err
 	}
 
 	// Format the PO number as PO/(vendor)/(date)01
@@ -686,7 +682,6 @@ func (s *UnicommerceService) CreatePurchaseOrder(ctx context.Context, purchaseOr
 
 	// Set the order date
 	purchaseOrder.OrderDate = time.Now()
-	purchaseOrder.UpdatedAt = time.Now()
 
 	// Save the purchase order to the database
 	_, err = s.PurchaseOrderRepository.Create(ctx, purchaseOrder)
@@ -698,46 +693,95 @@ func (s *UnicommerceService) CreatePurchaseOrder(ctx context.Context, purchaseOr
 	return nil
 }
 
-func (s *UnicommerceService) UpdatePurchaseOrder(ctx context.Context, poNumber string, updates map[string]interface{}) error {
+// UpdatePurchaseOrder applies updates to a purchase order and returns it as updated. A non-nil
+// version is the version the caller last read, ErrVersionConflict is returned when the purchase
+// order changed since, including while the updates are applied.
+func (s *UnicommerceService) UpdatePurchaseOrder(ctx context.Context, poNumber string, version *int64, updates map[string]interface{}) (*models.PurchaseOrder, error) {
 	// Fetch the purchase order
 	purchaseOrder, err := s.PurchaseOrderRepository.FindOne(ctx, map[string]interface{}{"poNumber": poNumber}, nil)
 	if err != nil {
 		s.Logger.Error("Error fetching purchase order", "error", err)
-		return err
+		return nil, err
+	}
+	if version != nil && *version != purchaseOrder.Version {
+		return nil, mongo_errors.ErrVersionConflict
 	}
 
 	// Update the fields
 	for fieldPath, value := range updates {
 		if !isAllowedField(fieldPath) {
-			return fmt.Errorf("field %s is not allowed to be updated", fieldPath)
+			return nil, fmt.Errorf("field %s is not allowed to be updated", fieldPath)
 		}
 
 		err := setField(purchaseOrder, fieldPath, value)
 		if err != nil {
 			s.Logger.Error("Error setting field", "fieldPath", fieldPath, "error", err)
-			return err
+			return nil, err
 		}
 	}
 
-	// Update the updatedAt field
-	purchaseOrder.UpdatedAt = time.Now()
+	// Save the updated purchase order unless it changed since it was read, the repository hooks set
+	// updatedAt and validate it
+	updated, err := s.PurchaseOrderRepository.UpdateVersion(ctx, map[string]interface{}{"poNumber": poNumber}, purchaseOrder.Version, purchaseOrder)
+	if err != nil {
+		s.Logger.Error("Error updating purchase order in DB", "error", err)
+		return nil, err
+	}
+
+	return updated, nil
+}
 
-	// validate the purchase order use validator v10
+// WatchPurchaseOrders streams the changes to purchase orders until ctx is done
+func (s *UnicommerceService) WatchPurchaseOrders(ctx context.Context) (<-chan mongo_models.ChangeEvent[models.PurchaseOrder], error) {
+	events, err := s.PurchaseOrderRepository.Watch(ctx, nil, &mongo_models.WatchOptions{PollField: "updatedAt"})
+	if err != nil {
+		s.Logger.Error("Error watching purchase orders", "error", err)
+		return nil, err
+	}
+	return events, nil
+}
 
-	validator := validator.New()
-	err = validator.Struct(purchaseOrder)
+// DeletePurchaseOrder soft deletes a purchase order, it can be restored until it is purged
+func (s *UnicommerceService) DeletePurchaseOrder(ctx context.Context, poNumber string) error {
+	deleted, err := s.PurchaseOrderRepository.Delete(ctx, map[string]interface{}{"poNumber": poNumber})
 	if err != nil {
-		s.Logger.Error("Error validating purchase order", "error", err)
+		s.Logger.Error("Error deleting purchase order", "poNumber", poNumber, "error", err)
 		return err
 	}
+	if deleted == 0 {
+		return mongo_errors.ErrDocumentNotFound
+	}
+	return nil
+}
 
-	// Save the updated purchase order
-	_, err = s.PurchaseOrderRepository.Update(ctx, map[string]interface{}{"poNumber": poNumber}, purchaseOrder)
+// RestorePurchaseOrder restores a deleted purchase order
+func (s *UnicommerceService) RestorePurchaseOrder(ctx context.Context, poNumber string) error {
+	restored, err := s.PurchaseOrderRepository.Restore(ctx, map[string]interface{}{"poNumber": poNumber})
 	if err != nil {
-		s.Logger.Error("Error updating purchase order in DB", "error", err)
+		s.Logger.Error("Error restoring purchase order", "poNumber", poNumber, "error", err)
+		return err
+	}
+	if restored == 0 {
+		return mongo_errors.ErrDocumentNotFound
+	}
+	return nil
+}
+
+// PurgeDeleted permanently removes the products and purchase orders deleted more than retention ago
+func (s *UnicommerceService) PurgeDeleted(ctx context.Context, retention time.Duration) error {
+	products, err := s.ProductsRepository.Purge(ctx, retention)
+	if err != nil {
+		s.Logger.Error("Error purging deleted products", "error", err)
+		return err
+	}
+
+	purchaseOrders, err := s.PurchaseOrderRepository.Purge(ctx, retention)
+	if err != nil {
+		s.Logger.Error("Error purging deleted purchase orders", "error", err)
 		return err
 	}
 
+	s.Logger.Info("Purged deleted documents", "products", products, "purchaseOrders", purchaseOrders)
 	return nil
 }
 
@@ -856,32 +900,18 @@ func setField(obj interface{}, fieldPath string, value interface{}) error {
 	return nil
 }
 
-func (s *UnicommerceService) GetPurchaseOrders(ctx context.Context, poNumber string, pageNumber int, fieldsPerPage int) ([]*models.PurchaseOrder, int64, error) {
+func (s *UnicommerceService) GetPurchaseOrders(ctx context.Context, poNumber string, pagination mongo_models.PaginationOptions) (*mongo_models.Page[models.PurchaseOrder], error) {
 	filter := map[string]interface{}{}
 	if poNumber != "" {
 		filter["poNumber"] = poNumber
 	}
 
-	// Ensure pageNumber is at least 1
-	if pageNumber < 1 {
-		pageNumber = 1
-	}
-
-	purchaseOrders, err := s.PurchaseOrderRepository.Find(ctx, filter, &mongo_models.FindOptions{
-		Limit: int64(fieldsPerPage),
-		Skip:  int64((pageNumber - 1) * fieldsPerPage),
-	})
-
+	pagination.IncludeTotal = true
+	page, err := s.PurchaseOrderRepository.Paginate(ctx, filter, pagination)
 	if err != nil {
 		s.Logger.Error("Error fetching purchase orders", "error", err)
-		return nil, 0, err
-	}
-
-	cnt, err := s.PurchaseOrderRepository.Count(ctx, filter)
-	if err != nil {
-		s.Logger.Error("Error fetching purchase orders count", "error", err)
-		return nil, 0, err
+		return nil, err
 	}
 
-	return purchaseOrders, cnt, nil
+	return page, nil
 }
@@ -22,6 +22,33 @@ func StartConfigSync(mongoCollection *mongo_models.MongoCollection, cache cache.
 	cancel()
 }
 
+// WatchConfig reloads the API configs into the cache whenever they change, until ctx is done. It is
+// started before the initial sync so no change made in between is missed.
+func WatchConfig(ctx context.Context, mongoCollection *mongo_models.MongoCollection, cache cache.Cacher, logger logger.ILogger) error {
+	mongoRepo := repository.Repository[models.APIConfig]{Collection: mongoCollection}
+
+	events, err := mongoRepo.Watch(ctx, nil, &mongo_models.WatchOptions{PollInterval: 30 * time.Second})
+	if err != nil {
+		logger.Error("Error watching configs", "error", err)
+		return err
+	}
+
+	go func() {
+		for event := range events {
+			if event.Err != nil {
+				logger.Warn("Error watching configs", "error", event.Err)
+				continue
+			}
+
+			logger.Info("Config changed, reloading", "operation", event.Operation, "id", event.ID)
+			syncCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			configSync(syncCtx, &mongoRepo, cache, logger)
+			cancel()
+		}
+	}()
+	return nil
+}
+
 func configSync(ctx context.Context, mongoRepo repository.IRepository[models.APIConfig], cache cache.Cacher, logger logger.ILogger) {
 	filter := map[string]interface{}{}
 	configs, err := mongoRepo.Find(ctx, filter)